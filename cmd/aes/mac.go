@@ -0,0 +1,78 @@
+/*
+	mac.go
+
+	The mac subcommand of the AES CLI interface, which writes or verifies the
+	AES-CMAC tag of a file.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	mac.go Daniel Havir, 2018
+*/
+
+package main
+
+import (
+	"crypto/subtle"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/danielhavir/go-ciphers/goaes"
+	"github.com/danielhavir/go-ciphers/internal/cli"
+)
+
+// runMAC runs the mac subcommand with its arguments, it streams the input
+// through CMAC and writes the 16-byte tag, or compares it with the tag
+// read from -verify and fails if they differ
+func runMAC(args []string) {
+	flags := flag.NewFlagSet(os.Args[0]+" mac", flag.ExitOnError)
+	inputPath := flags.String("in", "file.txt", "Path to input file, - for the standard input.")
+	outputPath := flags.String("out", "out.tag", "Path to the tag file, - for the standard output.")
+	verifyPath := flags.String("verify", "", "Path to a tag file to verify instead of writing the tag.")
+	keyString := flags.String("key", "0102030405060708090a0b0c0d0e0f10", "MAC key, 16, 24 or 32 bytes / characters long.")
+	impl := flags.String("impl", "stdlib", "AES implementation. Native, Bitsliced or Stdlib.")
+	useHex := flags.Bool("hex", false, "Encode the tag to/from hex.")
+	flags.Parse(args)
+
+	key := []byte(*keyString)
+	if !(len(key) == 16 || len(key) == 24 || len(key) == 32) {
+		panic("Key must be either 16, 24, or 32 bytes to select AES-128, AES-192, or AES-256." +
+			"Got: " + strconv.Itoa(len(key)))
+	}
+	block, err := cipherFunc(*impl)(key)
+	cli.Check(err)
+	mac, err := goaes.NewCMAC(block)
+	cli.Check(err)
+
+	_, err = io.Copy(mac, cli.OpenInput(*inputPath, false, cli.BufferSize))
+	cli.Check(err)
+	tag := mac.Sum(nil)
+
+	if *verifyPath != "" {
+		expected, err := io.ReadAll(cli.OpenInput(*verifyPath, *useHex, cli.BufferSize))
+		cli.Check(err)
+		if subtle.ConstantTimeCompare(tag, expected) != 1 {
+			panic("MAC verification failed")
+		}
+		fmt.Println("MAC verified")
+		return
+	}
+
+	out := cli.CreateOutput(*outputPath, *useHex)
+	defer out.Abort()
+	_, err = out.Write(tag)
+	cli.Check(err)
+	out.Commit()
+}
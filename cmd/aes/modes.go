@@ -0,0 +1,338 @@
+/*
+	modes.go
+
+	Encryption and decryption of the whole input for every mode of operation
+	supported by the AES CLI interface.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	modes.go Daniel Havir, 2018
+*/
+
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/danielhavir/go-ciphers/goaes"
+	"github.com/danielhavir/go-ciphers/gocontainer"
+	"github.com/danielhavir/go-ciphers/internal/cli"
+)
+
+// newHeader returns the header for encryption with the settings of the flags
+func newHeader(modeName string, paddingName string, counterBits int, sectorSize int, chunkSize int, deterministic bool) *gocontainer.Header {
+	mode, err := gocontainer.ParseMode(modeName)
+	cli.Check(err)
+	header := &gocontainer.Header{Cipher: gocontainer.AES, Mode: mode}
+
+	switch mode {
+	case gocontainer.ECB, gocontainer.CBC:
+		header.Padding, err = gocontainer.ParsePadding(paddingName)
+		cli.Check(err)
+	case gocontainer.CTR:
+		if counterBits < 8 || counterBits > 8*aes.BlockSize || counterBits%8 != 0 {
+			panic(goaes.ErrCounterSize)
+		}
+		header.Param = uint32(counterBits)
+	case gocontainer.XTS:
+		if sectorSize < aes.BlockSize {
+			panic("Sector size must be at least " + strconv.Itoa(aes.BlockSize) + " bytes")
+		}
+		header.Param = uint32(sectorSize)
+	case gocontainer.GCM, gocontainer.EAX, gocontainer.OCB:
+		header.TagSize = 16
+	case gocontainer.GCMStream:
+		if chunkSize < 1 || chunkSize > gocontainer.MaxChunkSize {
+			panic("Chunk size must be between 1 and " + strconv.Itoa(gocontainer.MaxChunkSize) + " bytes")
+		}
+		header.TagSize = 16
+		header.Param = uint32(chunkSize)
+	case gocontainer.SIV:
+		header.TagSize = 16
+	}
+	if deterministic && mode != gocontainer.SIV {
+		panic("Only SIV encrypts without a nonce")
+	}
+	if !deterministic {
+		header.InputVec = newInputVec(mode, counterBits)
+	}
+	return header
+}
+
+// newInputVec returns a random input vector, initial counter block or
+// nonce for the mode, nil for the modes without one
+func newInputVec(mode gocontainer.Mode, counterBits int) []byte {
+	var inputVec []byte
+	switch mode {
+	case gocontainer.ECB, gocontainer.XTS:
+		return nil
+	case gocontainer.GCM, gocontainer.OCB:
+		inputVec = make([]byte, 12)
+	case gocontainer.GCMStream:
+		inputVec = make([]byte, 7)
+	default:
+		inputVec = make([]byte, aes.BlockSize)
+	}
+	_, err := rand.Read(inputVec)
+	cli.Check(err)
+
+	// Narrower counters start at 1 after the random nonce, see RFC3686
+	if mode == gocontainer.CTR && counterBits >= 8 && counterBits < 8*aes.BlockSize {
+		counter := inputVec[aes.BlockSize-counterBits/8:]
+		for i := range counter {
+			counter[i] = 0
+		}
+		counter[len(counter)-1] = 1
+	}
+	return inputVec
+}
+
+// cipherFunc returns the constructor of the AES implementation selected by
+// the -impl flag, the choice is not recorded in the header
+func cipherFunc(impl string) func([]byte) (cipher.Block, error) {
+	switch strings.ToLower(impl) {
+	case "stdlib":
+		return aes.NewCipher
+	case "native":
+		return goaes.NewCipher
+	case "bitsliced":
+		return goaes.NewBitslicedCipher
+	default:
+		panic("Unknown AES implementation \"" + impl + "\", use native, bitsliced or stdlib")
+	}
+}
+
+// paddingScheme returns the padding scheme recorded in the header
+func paddingScheme(padding gocontainer.Padding) goaes.Padding {
+	switch padding {
+	case gocontainer.PKCS7:
+		return goaes.PKCS7{}
+	case gocontainer.ANSIX923:
+		return goaes.ANSIX923{}
+	case gocontainer.ISO7816:
+		return goaes.ISO7816{}
+	case gocontainer.ISO10126:
+		return goaes.ISO10126{}
+	case gocontainer.ZeroPadding:
+		return goaes.ZeroPadding{}
+	default:
+		return goaes.NoPadding{}
+	}
+}
+
+// checkDecrypt reports every decryption failure of the padded modes in the
+// same way, so that a padding oracle cannot tell a malformed length from
+// malformed padding, and removes the partial output before exiting
+func checkDecrypt(err error, out *cli.Output) {
+	if err != nil {
+		out.Abort()
+		fmt.Fprintln(os.Stderr, "Decryption failed")
+		os.Exit(1)
+	}
+}
+
+// checkLength reports an input whose length the mode cannot process and
+// removes the partial output before exiting, other errors are left to cli.Check
+func checkLength(err error, out *cli.Output) {
+	var msg string
+	switch err {
+	case goaes.ErrXTSTooShort:
+		msg = "XTS needs the last sector to be at least " + strconv.Itoa(aes.BlockSize) +
+			" bytes long, the input is shorter or ends with a shorter sector"
+	case goaes.ErrCSTooShort:
+		msg = "Ciphertext stealing needs at least " + strconv.Itoa(aes.BlockSize) + " bytes of input"
+	default:
+		return
+	}
+	out.Abort()
+	fmt.Fprintln(os.Stderr, msg)
+	os.Exit(1)
+}
+
+// newCipherWriter returns the writer that encrypts or decrypts into w in
+// the mode of the header, every mode but the authenticated ones processes
+// the input in chunks
+// ECB, CBC decryption and CTR split the chunks across the workers
+func newCipherWriter(header *gocontainer.Header, block cipher.Block, newCipher func([]byte) (cipher.Block, error), key []byte, encrypt bool, concurrency goaes.Concurrency, w io.Writer) io.WriteCloser {
+	inputVec, param := header.InputVec, int(header.Param)
+	switch header.Mode {
+	case gocontainer.ECB:
+		return runECB(block, encrypt, paddingScheme(header.Padding), concurrency, w)
+	case gocontainer.CBC:
+		return runCBC(block, encrypt, inputVec, paddingScheme(header.Padding), concurrency, w)
+	case gocontainer.CBCCS1:
+		return runCBCCS(block, encrypt, inputVec, goaes.CS1, w)
+	case gocontainer.CBCCS2:
+		return runCBCCS(block, encrypt, inputVec, goaes.CS2, w)
+	case gocontainer.CBCCS3:
+		return runCBCCS(block, encrypt, inputVec, goaes.CS3, w)
+	case gocontainer.CTR:
+		return runCTR(block, inputVec, param, concurrency, w)
+	case gocontainer.CFB1:
+		return runCFB(block, encrypt, inputVec, goaes.CFB1, w)
+	case gocontainer.CFB8:
+		return runCFB(block, encrypt, inputVec, goaes.CFB8, w)
+	case gocontainer.CFB128:
+		return runCFB(block, encrypt, inputVec, goaes.CFB128, w)
+	case gocontainer.OFB:
+		return runOFB(block, inputVec, w)
+	case gocontainer.XTS:
+		return runXTS(newCipher, key, encrypt, param, w)
+	}
+	panic("Mode " + header.Mode.String() + " cannot be streamed")
+}
+
+// runECB pads the final block on encryption and removes the padding on
+// decryption
+func runECB(block cipher.Block, encrypt bool, padding goaes.Padding, concurrency goaes.Concurrency, w io.Writer) io.WriteCloser {
+	if encrypt {
+		enc, err := goaes.NewECBEncrypter(block)
+		cli.Check(err)
+		enc.SetConcurrency(concurrency)
+		return goaes.NewEncryptWriter(enc, padding, w)
+	}
+	dec, err := goaes.NewECBDecrypter(block)
+	cli.Check(err)
+	dec.SetConcurrency(concurrency)
+	return goaes.NewDecryptWriter(dec, padding, w)
+}
+
+// runCBC pads the final block on encryption and removes the padding on
+// decryption, only decryption runs in parallel
+func runCBC(block cipher.Block, encrypt bool, inputVec []byte, padding goaes.Padding, concurrency goaes.Concurrency, w io.Writer) io.WriteCloser {
+	if encrypt {
+		enc, err := goaes.NewCBCEncrypter(block, inputVec)
+		cli.Check(err)
+		return goaes.NewEncryptWriter(enc, padding, w)
+	}
+	dec, err := goaes.NewCBCDecrypter(block, inputVec)
+	cli.Check(err)
+	dec.SetConcurrency(concurrency)
+	return goaes.NewDecryptWriter(dec, padding, w)
+}
+
+// runCBCCS needs no padding, the ciphertext is as long as the plaintext
+// as long as the plaintext fills at least one block
+func runCBCCS(block cipher.Block, encrypt bool, inputVec []byte, variant int, w io.Writer) io.WriteCloser {
+	cs, err := goaes.NewCBCCS(block, inputVec, variant)
+	cli.Check(err)
+	if encrypt {
+		return goaes.NewCBCCSEncryptWriter(cs, w)
+	}
+	return goaes.NewCBCCSDecryptWriter(cs, w)
+}
+
+// runCTR needs no padding, the ciphertext is as long as the plaintext
+func runCTR(block cipher.Block, inputVec []byte, counterBits int, concurrency goaes.Concurrency, w io.Writer) io.WriteCloser {
+	stream, err := goaes.NewCTR(block, inputVec, counterBits)
+	cli.Check(err)
+	stream.SetConcurrency(concurrency)
+	return cipher.StreamWriter{S: stream, W: w}
+}
+
+// runCFB needs no padding, the ciphertext is as long as the plaintext
+func runCFB(block cipher.Block, encrypt bool, inputVec []byte, segmentBits int, w io.Writer) io.WriteCloser {
+	var stream cipher.Stream
+	var err error
+	if encrypt {
+		stream, err = goaes.NewCFBEncrypter(block, inputVec, segmentBits)
+	} else {
+		stream, err = goaes.NewCFBDecrypter(block, inputVec, segmentBits)
+	}
+	cli.Check(err)
+	return cipher.StreamWriter{S: stream, W: w}
+}
+
+// runOFB needs no padding, the ciphertext is as long as the plaintext
+func runOFB(block cipher.Block, inputVec []byte, w io.Writer) io.WriteCloser {
+	stream, err := goaes.NewOFB(block, inputVec)
+	cli.Check(err)
+	return cipher.StreamWriter{S: stream, W: w}
+}
+
+// newAEAD returns the one-shot authenticated mode of the header, GCM, SIV,
+// EAX or OCB
+func newAEAD(header *gocontainer.Header, block cipher.Block, newCipher func([]byte) (cipher.Block, error), key []byte) cipher.AEAD {
+	var aead cipher.AEAD
+	var err error
+	switch header.Mode {
+	case gocontainer.SIV:
+		aead, err = goaes.NewSIV(newCipher, key)
+	case gocontainer.EAX:
+		aead, err = goaes.NewEAX(block, header.TagSize, len(header.InputVec))
+	case gocontainer.OCB:
+		aead, err = goaes.NewOCB(block, header.TagSize, len(header.InputVec))
+	default:
+		aead, err = goaes.NewGCMWithTagSize(block, header.TagSize)
+	}
+	cli.Check(err)
+	return aead
+}
+
+// runAEAD reads the whole input, since the authenticated modes only release
+// the plaintext once the tag over all of it is verified, and writes the
+// sealed output, IV || ciphertext for SIV and ciphertext || tag otherwise
+// Decryption panics before any output is written if the tag does not match
+func runAEAD(aead cipher.AEAD, in io.Reader, encrypt bool, nonce []byte, aad []byte) []byte {
+	intext, err := io.ReadAll(in)
+	cli.Check(err)
+
+	if encrypt {
+		return aead.Seal(intext[:0], nonce, intext, aad)
+	}
+	outtext, err := aead.Open(intext[:0], nonce, intext, aad)
+	cli.Check(err)
+	return outtext
+}
+
+// runGCMStream seals the input chunk by chunk with the STREAM construction,
+// decryption only writes authenticated chunks and fails if any chunk is
+// modified, reordered or missing
+func runGCMStream(block cipher.Block, in io.Reader, encrypt bool, prefix []byte, tagSize int, chunkSize int, aad []byte, out *cli.Output) {
+	aead, err := goaes.NewGCMWithTagSize(block, tagSize)
+	cli.Check(err)
+
+	if encrypt {
+		w, err := goaes.NewChunkWriter(aead, prefix, chunkSize, aad, out)
+		cli.Check(err)
+		_, err = io.Copy(w, in)
+		cli.Check(err)
+		cli.Check(w.Close())
+		return
+	}
+	r, err := goaes.NewChunkReader(aead, prefix, chunkSize, aad, in)
+	cli.Check(err)
+	_, err = io.Copy(out, r)
+	checkDecrypt(err, out)
+}
+
+// runXTS encrypts the input sector by sector, numbering the sectors from
+// zero, the ciphertext is as long as the plaintext
+func runXTS(newCipher func([]byte) (cipher.Block, error), key []byte, encrypt bool, sectorSize int, w io.Writer) io.WriteCloser {
+	if sectorSize < aes.BlockSize {
+		panic("Sector size must be at least " + strconv.Itoa(aes.BlockSize) + " bytes")
+	}
+	xts, err := goaes.NewXTS(newCipher, key)
+	cli.Check(err)
+	if encrypt {
+		return goaes.NewXTSEncryptWriter(xts, sectorSize, w)
+	}
+	return goaes.NewXTSDecryptWriter(xts, sectorSize, w)
+}
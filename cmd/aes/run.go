@@ -0,0 +1,183 @@
+/*
+	run.go
+
+	Main function for the AES CLI interface.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	run.go Daniel Havir, 2018
+*/
+
+package main
+
+import (
+	"crypto/cipher"
+	"flag"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/danielhavir/go-ciphers/goaes"
+	"github.com/danielhavir/go-ciphers/gocontainer"
+	"github.com/danielhavir/go-ciphers/gokdf"
+	"github.com/danielhavir/go-ciphers/internal/cli"
+)
+
+func main() {
+	// Key wrapping and MACs are subcommands with flags of their own
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "wrap", "unwrap":
+			runWrap(os.Args[1], os.Args[2:])
+			return
+		case "mac":
+			runMAC(os.Args[2:])
+			return
+		}
+	}
+
+	encrypt := flag.Bool("en", false, "Encrypt")
+	decrypt := flag.Bool("de", false, "Decrypt")
+	mode := flag.String("mode", "cbc", "AES mode of operation for encryption. ECB, CBC, CBC-CS1, CBC-CS2, CBC-CS3, CTR, CFB1, CFB8, CFB128, OFB, GCM, GCM-STREAM, XTS, SIV, EAX or OCB.")
+	counterBits := flag.Int("counter", goaes.CounterFull, "CTR counter width in bits. 128, or 32 for a 96-bit nonce.")
+	padding := flag.String("padding", "pkcs7", "Padding for ECB and CBC encryption. PKCS7, X923, ISO7816, ISO10126, Zero or None.")
+	sectorSize := flag.Int("sector-size", 4096, "XTS data unit size in bytes. Each sector is encrypted with its index as the tweak.")
+	chunkSize := flag.Int("chunk-size", 64*1024, "GCM-STREAM plaintext chunk size in bytes. Each chunk is authenticated on its own.")
+	aad := flag.String("aad", "", "Additional authenticated data for GCM, GCM-STREAM, SIV, EAX and OCB. Must match on decryption.")
+	deterministic := flag.Bool("deterministic", false, "Encrypt SIV without a nonce, equal plaintexts under the same key and additional data give equal outputs.")
+	inputPath := flag.String("in", "file.txt", "Path to input file, - for the standard input.")
+	outputPath := flag.String("out", "out", "Path to output file, - for the standard output.")
+	keyString := flag.String("key", "0102030405060708090a0b0c0d0e0f10", "Encryption/decryption key. For encryption, choose a string between 5 and 32 characters.")
+	password := flag.String("pass", "", "Passphrase to derive an AES-256 key from instead of -key. The salt and parameters are stored in the output header.")
+	kdfName := flag.String("kdf", "pbkdf2", "Key derivation function for -pass. PBKDF2 (HMAC-SHA256), Scrypt or Argon2id.")
+	cost := flag.Uint("iter", 0, "PBKDF2 iterations, scrypt log2(N) or Argon2id passes. 0 selects the default.")
+	workers := flag.Int("workers", 1, "Number of goroutines for ECB, CBC decryption and CTR, which process independent blocks.")
+	impl := flag.String("impl", "stdlib", "AES implementation. Native (pure Go, table-based), Bitsliced (pure Go, constant-time) or Stdlib (crypto/aes). Not stored in the header.")
+	useHex := flag.Bool("hex", false, "Encode to/from hex.")
+	flag.Parse()
+
+	if !(*encrypt || *decrypt) {
+		panic("You must specify either either encrypt \"-en\" or decrypt \"-de\"")
+	}
+
+	// The key derivation function is recorded in the header on encryption,
+	// checked before any output is created
+	var kdf gokdf.KDF
+	if *encrypt && *password != "" {
+		kdf = cli.ParseKDF(*kdfName)
+	}
+	newCipher := cipherFunc(*impl)
+	in := cli.OpenInput(*inputPath, *decrypt && *useHex, *workers*goaes.DefaultChunkSize)
+	out := cli.CreateOutput(*outputPath, *encrypt && *useHex)
+	defer out.Abort()
+
+	key := []byte(*keyString)
+
+	// On encryption the header records the settings, on decryption they
+	// are read from it and the flags are ignored
+	var header *gocontainer.Header
+	var rawHeader []byte
+	if *encrypt {
+		header = newHeader(*mode, *padding, *counterBits, *sectorSize, *chunkSize, *deterministic)
+
+		// A passphrase derives AES-256 keys, doubled for XTS and SIV
+		if *password != "" {
+			keyLen := 32
+			if header.Mode == gocontainer.XTS || header.Mode == gocontainer.SIV {
+				keyLen = 64
+			}
+			header.KDF, key = cli.NewPasswordKey(*password, kdf, *cost, keyLen)
+		}
+		header.KeySize = len(key)
+	} else {
+		var err error
+		header, rawHeader, err = gocontainer.ReadHeader(in)
+		cli.Check(err)
+		if header.Cipher != gocontainer.AES {
+			panic("The input was not encrypted with AES")
+		}
+
+		if header.KDF != nil {
+			key = cli.PasswordKey(header.KDF, *password, header.KeySize)
+		} else if *password != "" {
+			panic("The input was encrypted with a key, use -key")
+		}
+	}
+
+	// XTS and SIV split a double-length key into two ciphers by themselves
+	var block cipher.Block
+	switch header.Mode {
+	case gocontainer.XTS:
+		if !(len(key) == 32 || len(key) == 64) {
+			panic("XTS key must be either 32 or 64 bytes to select AES-128 or AES-256." +
+				"Got: " + strconv.Itoa(len(key)))
+		}
+	case gocontainer.SIV:
+		if !(len(key) == 32 || len(key) == 48 || len(key) == 64) {
+			panic("SIV key must be either 32, 48 or 64 bytes to select AES-128, AES-192 or AES-256." +
+				"Got: " + strconv.Itoa(len(key)))
+		}
+	default:
+		if !(len(key) == 16 || len(key) == 24 || len(key) == 32) {
+			panic("Key must be either 16, 24, or 32 bytes to select AES-128, AES-192, or AES-256." +
+				"Got: " + strconv.Itoa(len(key)))
+		}
+
+		var err error
+		block, err = newCipher(key)
+		cli.Check(err)
+	}
+	if len(key) != header.KeySize {
+		panic("The input was encrypted with a " + strconv.Itoa(header.KeySize) + "-byte key." +
+			"Got: " + strconv.Itoa(len(key)))
+	}
+
+	// The header precedes the ciphertext
+	if *encrypt {
+		var err error
+		rawHeader, err = header.MarshalBinary()
+		cli.Check(err)
+		_, err = out.Write(rawHeader)
+		cli.Check(err)
+	}
+
+	switch header.Mode {
+	case gocontainer.GCM, gocontainer.SIV, gocontainer.EAX, gocontainer.OCB:
+		// The header is authenticated along with the additional data
+		outtext := runAEAD(newAEAD(header, block, newCipher, key), in, *encrypt, header.InputVec,
+			append(append([]byte(nil), rawHeader...), *aad...))
+		_, err := out.Write(outtext)
+		cli.Check(err)
+		out.Commit()
+		return
+	}
+
+	if header.Mode == gocontainer.GCMStream {
+		// Every chunk authenticates the header and the additional data
+		runGCMStream(block, in, *encrypt, header.InputVec, header.TagSize, int(header.Param),
+			append(append([]byte(nil), rawHeader...), *aad...), out)
+		out.Commit()
+		return
+	}
+
+	w := newCipherWriter(header, block, newCipher, key, *encrypt, goaes.Concurrency{Workers: *workers}, out)
+	_, err := io.Copy(w, in)
+	cli.Check(err)
+	err = w.Close()
+	if *decrypt && (header.Mode == gocontainer.ECB || header.Mode == gocontainer.CBC) {
+		checkDecrypt(err, out)
+	}
+	checkLength(err, out)
+	cli.Check(err)
+	out.Commit()
+}
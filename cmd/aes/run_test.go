@@ -0,0 +1,106 @@
+/*
+	run_test.go
+
+	Tests of the AES CLI interface, run as a child process of the test.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	run_test.go Daniel Havir, 2018
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/danielhavir/go-ciphers/goaes"
+	"github.com/danielhavir/go-ciphers/gocontainer"
+)
+
+// The test binary runs main instead of the tests when this is set
+const runMainEnv = "GOCIPHERS_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runCLI runs the command line interface with args and returns its
+// standard error and exit code
+func runCLI(t *testing.T, args ...string) (string, int) {
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), runMainEnv+"=1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return stderr.String(), exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return stderr.String(), 0
+}
+
+// TestDecryptionFailure checks that a CBC ciphertext of a wrong length and
+// one with a wrong padding fail alike, without any output
+func TestDecryptionFailure(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	inputVec := make([]byte, aes.BlockSize)
+	header := &gocontainer.Header{Cipher: gocontainer.AES, Mode: gocontainer.CBC,
+		Padding: gocontainer.PKCS7, KeySize: len(key), InputVec: inputVec}
+	rawHeader, err := header.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A zero block decrypts to a zero padding length
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cbc, err := goaes.NewCBC(block, inputVec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	badPadding, err := cbc.Encrypt(make([]byte, aes.BlockSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	inputs := map[string][]byte{
+		"length":  make([]byte, aes.BlockSize-1),
+		"padding": badPadding,
+	}
+	for name, ciphertext := range inputs {
+		in, out := filepath.Join(dir, name), filepath.Join(dir, name+".out")
+		if err := os.WriteFile(in, append(append([]byte(nil), rawHeader...), ciphertext...), 0664); err != nil {
+			t.Fatal(err)
+		}
+		stderr, code := runCLI(t, "-de", "-key="+string(key), "-in="+in, "-out="+out)
+		if stderr != "Decryption failed\n" || code != 1 {
+			t.Error("Wrong ", name, ": expected \"Decryption failed\" and exit code 1,got ", stderr, " exit code ", code)
+		}
+		if _, err := os.Stat(out); !os.IsNotExist(err) {
+			t.Error("Wrong ", name, ": the output was not removed")
+		}
+	}
+}
@@ -0,0 +1,82 @@
+/*
+	wrap.go
+
+	The wrap and unwrap subcommands of the AES CLI interface, which wrap a
+	key with a key-encryption key using AES Key Wrap (KW) or Key Wrap with
+	Padding (KWP) of NIST SP 800-38F.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	wrap.go Daniel Havir, 2018
+*/
+
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/danielhavir/go-ciphers/goaes"
+	"github.com/danielhavir/go-ciphers/internal/cli"
+)
+
+// runWrap runs the wrap or unwrap subcommand with its arguments
+// The output is the bare wrapped key of RFC 3394 or RFC 5649 without a
+// header, so that other implementations can unwrap it
+func runWrap(command string, args []string) {
+	flags := flag.NewFlagSet(os.Args[0]+" "+command, flag.ExitOnError)
+	inputPath := flags.String("in", "key.bin", "Path to the key to "+command+", - for the standard input.")
+	outputPath := flags.String("out", "out", "Path to output file, - for the standard output.")
+	keyString := flags.String("key", "0102030405060708090a0b0c0d0e0f10", "Key-encryption key, 16, 24 or 32 bytes / characters long.")
+	pad := flags.Bool("pad", false, "Use Key Wrap with Padding (KWP, RFC 5649), which takes keys of any length. Must match on unwrapping.")
+	impl := flags.String("impl", "stdlib", "AES implementation. Native, Bitsliced or Stdlib.")
+	useHex := flags.Bool("hex", false, "Encode to/from hex.")
+	flags.Parse(args)
+
+	wrap := command == "wrap"
+	kek := []byte(*keyString)
+	if !(len(kek) == 16 || len(kek) == 24 || len(kek) == 32) {
+		panic("Key must be either 16, 24, or 32 bytes to select AES-128, AES-192, or AES-256." +
+			"Got: " + strconv.Itoa(len(kek)))
+	}
+	block, err := cipherFunc(*impl)(kek)
+	cli.Check(err)
+
+	in := cli.OpenInput(*inputPath, !wrap && *useHex, cli.BufferSize)
+	out := cli.CreateOutput(*outputPath, wrap && *useHex)
+	defer out.Abort()
+	intext, err := io.ReadAll(in)
+	cli.Check(err)
+
+	var outtext []byte
+	switch {
+	case wrap && *pad:
+		outtext, err = goaes.KeyWrapPad(block, intext)
+	case wrap:
+		outtext, err = goaes.KeyWrap(block, intext)
+	case *pad:
+		outtext, err = goaes.KeyUnwrapPad(block, intext)
+	default:
+		outtext, err = goaes.KeyUnwrap(block, intext)
+	}
+	if err == goaes.ErrWrapLength && wrap && !*pad {
+		panic("KW wraps keys of a multiple of 8 bytes, at least 16 bytes long, use -pad for other lengths")
+	}
+	cli.Check(err)
+
+	_, err = out.Write(outtext)
+	cli.Check(err)
+	out.Commit()
+}
@@ -0,0 +1,168 @@
+/*
+	main.go
+
+	Renders the output of go test -bench as markdown tables for the README,
+	one row per benchmark and one column per input size, in MB/s where the
+	benchmark sets the bytes per operation and in ns/op otherwise.
+
+	  go test ./goaes ./gorc4 -run XXX -bench . | go run ./cmd/benchtable
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	main.go Daniel Havir, 2018
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// result is the mean of the runs of one benchmark
+type result struct {
+	sum   float64
+	runs  int
+	speed bool
+}
+
+// table holds the benchmarks that share the same columns
+type table struct {
+	columns []string
+	rows    []string
+	results map[string]map[string]*result
+}
+
+func check(e error) {
+	if e != nil {
+		panic(e)
+	}
+}
+
+// parseLine splits a result line such as
+//
+//	BenchmarkECBEncrypt/AES-128/16B-8  1000000  45.1 ns/op  354.61 MB/s
+//
+// into the row "ECBEncrypt/AES-128", the column "16B" and the value
+func parseLine(line string) (row, column string, value float64, speed bool, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+		return
+	}
+	name := strings.TrimPrefix(fields[0], "Benchmark")
+	// The GOMAXPROCS suffix
+	if i := strings.LastIndexByte(name, '-'); i > 0 {
+		if _, err := strconv.Atoi(name[i+1:]); err == nil {
+			name = name[:i]
+		}
+	}
+	row = name
+	if i := strings.LastIndexByte(name, '/'); i > 0 {
+		row, column = name[:i], name[i+1:]
+	}
+
+	for i := 2; i+1 < len(fields); i += 2 {
+		v, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			continue
+		}
+		switch fields[i+1] {
+		case "MB/s":
+			return row, column, v, true, true
+		case "ns/op":
+			value, ok = v, true
+		}
+	}
+	return row, column, value, false, ok
+}
+
+// parse groups the benchmarks into tables by their columns, in the order
+// of appearance
+func parse(r io.Reader) []*table {
+	columns := map[string][]string{}
+	values := map[string]map[string]*result{}
+	var rows []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		row, column, value, speed, ok := parseLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		if values[row] == nil {
+			values[row] = map[string]*result{}
+			rows = append(rows, row)
+		}
+		res := values[row][column]
+		if res == nil {
+			res = &result{speed: speed}
+			values[row][column] = res
+			columns[row] = append(columns[row], column)
+		}
+		res.sum += value
+		res.runs++
+	}
+	check(scanner.Err())
+
+	var tables []*table
+	bySignature := map[string]*table{}
+	for _, row := range rows {
+		signature := strings.Join(columns[row], "\x00")
+		t := bySignature[signature]
+		if t == nil {
+			t = &table{columns: columns[row], results: map[string]map[string]*result{}}
+			bySignature[signature] = t
+			tables = append(tables, t)
+		}
+		t.rows = append(t.rows, row)
+		t.results[row] = values[row]
+	}
+	return tables
+}
+
+func (res *result) String() string {
+	mean := res.sum / float64(res.runs)
+	if res.speed {
+		return strconv.FormatFloat(mean, 'f', 2, 64) + " MB/s"
+	}
+	return strconv.FormatFloat(mean, 'f', 0, 64) + " ns/op"
+}
+
+func write(w io.Writer, tables []*table) {
+	for i, t := range tables {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		header := strings.Join(t.columns, " | ")
+		if header == "" {
+			header = "Result"
+		}
+		fmt.Fprintln(w, "| Benchmark | "+header+" |")
+		fmt.Fprintln(w, "| --- |"+strings.Repeat(" ---: |", len(t.columns)))
+		for _, row := range t.rows {
+			cells := make([]string, len(t.columns))
+			for j, column := range t.columns {
+				cells[j] = t.results[row][column].String()
+			}
+			fmt.Fprintln(w, "| "+row+" | "+strings.Join(cells, " | ")+" |")
+		}
+	}
+}
+
+func main() {
+	write(os.Stdout, parse(os.Stdin))
+}
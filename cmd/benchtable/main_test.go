@@ -0,0 +1,92 @@
+/*
+	main_test.go
+
+	Tests of the benchmark parsing and the markdown tables.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	main_test.go Daniel Havir, 2018
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line   string
+		row    string
+		column string
+		value  float64
+		speed  bool
+		ok     bool
+	}{
+		{"BenchmarkECBEncrypt/AES-128/16B-8  1000000  45.1 ns/op  354.61 MB/s",
+			"ECBEncrypt/AES-128", "16B", 354.61, true, true},
+		{"BenchmarkKSA/128bit-4  1000000  1206 ns/op  0 B/op  0 allocs/op",
+			"KSA", "128bit", 1206, false, true},
+		// No GOMAXPROCS suffix and no column
+		{"BenchmarkStdlibKSA  1000000  1404 ns/op", "StdlibKSA", "", 1404, false, true},
+		{"goos: linux", "", "", 0, false, false},
+		{"PASS", "", "", 0, false, false},
+		{"ok  	github.com/danielhavir/go-ciphers/goaes	12.3s", "", "", 0, false, false},
+		{"BenchmarkBroken/16B-8  1000000  fast", "", "", 0, false, false},
+	}
+	for _, test := range tests {
+		row, column, value, speed, ok := parseLine(test.line)
+		if ok != test.ok || (ok && (row != test.row || column != test.column ||
+			value != test.value || speed != test.speed)) {
+			t.Error("Line ", test.line, " expected ", test.row, " ", test.column, " ", test.value,
+				" ", test.speed, " ", test.ok, ",got ", row, " ", column, " ", value, " ", speed, " ", ok)
+		}
+	}
+}
+
+func TestTables(t *testing.T) {
+	input := `goos: linux
+goarch: amd64
+pkg: github.com/danielhavir/go-ciphers/goaes
+BenchmarkECBEncrypt/16B-8     1000000   45.0 ns/op   350.00 MB/s
+BenchmarkECBEncrypt/1KB-8      100000  900.0 ns/op  1100.00 MB/s
+BenchmarkECBEncrypt/16B-8     1000000   47.0 ns/op   340.00 MB/s
+BenchmarkECBEncrypt/1KB-8      100000  910.0 ns/op  1120.00 MB/s
+BenchmarkCBCDecrypt/16B-8     1000000   50.0 ns/op   320.00 MB/s
+BenchmarkCBCDecrypt/1KB-8      100000 1000.0 ns/op  1000.00 MB/s
+BenchmarkKSA/128bit-8         1000000 1206.4 ns/op
+BenchmarkKSA/256bit-8         1000000 1266.6 ns/op
+BenchmarkSetup-8              1000000   99.0 ns/op
+PASS
+ok  	github.com/danielhavir/go-ciphers/goaes	3.2s
+`
+	expected := `| Benchmark | 16B | 1KB |
+| --- | ---: | ---: |
+| ECBEncrypt | 345.00 MB/s | 1110.00 MB/s |
+| CBCDecrypt | 320.00 MB/s | 1000.00 MB/s |
+
+| Benchmark | 128bit | 256bit |
+| --- | ---: | ---: |
+| KSA | 1206 ns/op | 1267 ns/op |
+
+| Benchmark | Result |
+| --- | ---: |
+| Setup | 99 ns/op |
+`
+	var out strings.Builder
+	write(&out, parse(strings.NewReader(input)))
+	if out.String() != expected {
+		t.Error("Expected\n", expected, "got\n", out.String())
+	}
+}
@@ -0,0 +1,115 @@
+/*
+	run.go
+
+	Main function for the RC4 CLI interface.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	run.go Daniel Havir, 2018
+*/
+
+package main
+
+import (
+	"crypto/cipher"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/danielhavir/go-ciphers/gocontainer"
+	"github.com/danielhavir/go-ciphers/gokdf"
+	"github.com/danielhavir/go-ciphers/gorc4"
+	"github.com/danielhavir/go-ciphers/internal/cli"
+)
+
+func main() {
+	encrypt := flag.Bool("en", false, "Encrypt")
+	decrypt := flag.Bool("de", false, "Decrypt")
+	inputPath := flag.String("in", "file.txt", "Path to input file, - for the standard input.")
+	outputPath := flag.String("out", "out", "Path to output file, - for the standard output.")
+	keyString := flag.String("key", "\x01\x02\x03\x04\x05", "Encryption/decryption key. For encryption, choose a string between 5 and 32 characters.")
+	password := flag.String("pass", "", "Passphrase to derive a 128-bit key from instead of -key. The salt and parameters are stored in the output header.")
+	kdfName := flag.String("kdf", "pbkdf2", "Key derivation function for -pass. PBKDF2 (HMAC-SHA256), Scrypt or Argon2id.")
+	cost := flag.Uint("iter", 0, "PBKDF2 iterations, scrypt log2(N) or Argon2id passes. 0 selects the default.")
+	offset := flag.Int("offset", 1536, "Number of bytes to discard before encryption, recorded in the header")
+	useHex := flag.Bool("hex", false, "Encode to/from hex.")
+	flag.Parse()
+
+	if !(*encrypt || *decrypt) {
+		fmt.Println("You must specify either either encrypt \"-en\" or decrypt \"-de\"")
+		return
+	}
+
+	// The key derivation function is recorded in the header on encryption,
+	// checked before any output is created
+	var kdf gokdf.KDF
+	if *encrypt && *password != "" {
+		kdf = cli.ParseKDF(*kdfName)
+	}
+	in := cli.OpenInput(*inputPath, *decrypt && *useHex, cli.BufferSize)
+	out := cli.CreateOutput(*outputPath, *encrypt && *useHex)
+	defer out.Abort()
+
+	key := []byte(*keyString)
+
+	// On encryption the header records the key size and the offset, on
+	// decryption they are read from it
+	var header *gocontainer.Header
+	var err error
+	if *encrypt {
+		if *offset < 0 || *offset > gocontainer.MaxRC4Offset {
+			panic("Offset must be between 0 and " + strconv.Itoa(gocontainer.MaxRC4Offset) + " bytes")
+		}
+		header = &gocontainer.Header{Cipher: gocontainer.RC4, Param: uint32(*offset)}
+		if *password != "" {
+			header.KDF, key = cli.NewPasswordKey(*password, kdf, *cost, 16)
+		}
+		header.KeySize = len(key)
+		rawHeader, err := header.MarshalBinary()
+		cli.Check(err)
+		_, err = out.Write(rawHeader)
+		cli.Check(err)
+	} else {
+		header, _, err = gocontainer.ReadHeader(in)
+		cli.Check(err)
+		if header.Cipher != gocontainer.RC4 {
+			panic("The input was not encrypted with RC4")
+		}
+
+		if header.KDF != nil {
+			key = cli.PasswordKey(header.KDF, *password, header.KeySize)
+		} else if *password != "" {
+			panic("The input was encrypted with a key, use -key")
+		}
+		if len(key) != header.KeySize {
+			panic("The input was encrypted with a " + strconv.Itoa(header.KeySize) + "-byte key." +
+				"Got: " + strconv.Itoa(len(key)))
+		}
+	}
+
+	rc4, err := gorc4.KSA(key)
+	cli.Check(err)
+	defer rc4.Reset()
+	// The offset comes from the header, discard it through a small buffer
+	discard := make([]byte, 4096)
+	for left := int(header.Param); left > 0; left -= len(discard) {
+		n := min(left, len(discard))
+		rc4.XORKeyStream(discard[:n], discard[:n])
+	}
+
+	// The key stream is applied to the input chunk by chunk
+	_, err = io.Copy(cipher.StreamWriter{S: rc4, W: out}, in)
+	cli.Check(err)
+	out.Commit()
+}
@@ -1,7 +1,8 @@
 /*
 	aes.go
 
-	Implementation of ECB and CBC modes of operation.
+	Implementation of ECB and CBC modes of operation. The ECB and CBC classes
+	allocate their output, see blockmode.go for the cipher.BlockMode variants.
 
 	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
 	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
@@ -18,108 +19,124 @@
 	aes.go Daniel Havir, 2018
 */
 
-package main
+package goaes
 
 import (
 	"crypto/cipher"
-	"strconv"
+	"errors"
+	"fmt"
 )
 
+// ErrNotFullBlocks is returned when the input does not fill the blocks
+var ErrNotFullBlocks = errors.New("goaes: input does not fill the blocks")
+
+// ErrIVSize is returned when the input vector does not match the block size
+var ErrIVSize = errors.New("goaes: input vector length must equal block size")
+
+// ErrNilBlock is returned when a constructor receives a nil cipher.Block
+var ErrNilBlock = errors.New("goaes: block cipher must not be nil")
+
 // ECB is the class for Electronic Code Book mode of operation
 type ECB struct {
-	aes       cipher.Block
-	blockSize int
+	aes         cipher.Block
+	blockSize   int
+	concurrency Concurrency
 }
 
 // CBC is the class for the Cipher Block Chaining mode of operation
 type CBC struct {
-	aes       cipher.Block
-	blockSize int
-	inputVec  []byte
+	aes         cipher.Block
+	blockSize   int
+	inputVec    []byte
+	concurrency Concurrency
 }
 
 // NewECB is a constructor for the ECB class
-func NewECB(b cipher.Block) *ECB {
+func NewECB(b cipher.Block) (*ECB, error) {
+	if b == nil {
+		return nil, ErrNilBlock
+	}
 	return &ECB{
 		aes:       b,
 		blockSize: b.BlockSize(),
-	}
+	}, nil
 }
 
 // Encrypt is an ECB method for encryption
-func (ecb *ECB) Encrypt(in []byte) []byte {
-	out := make([]byte, len(in))
-
-	for i := 0; i < len(in); i += ecb.blockSize {
-		ecb.aes.Encrypt(out[i:i+ecb.blockSize], in[i:i+ecb.blockSize])
+func (ecb *ECB) Encrypt(in []byte) ([]byte, error) {
+	if err := checkFullBlocks(in, ecb.blockSize); err != nil {
+		return nil, err
 	}
 
-	return out
+	out := make([]byte, len(in))
+	enc := ECBEncrypter{aes: ecb.aes, blockSize: ecb.blockSize, concurrency: ecb.concurrency}
+	enc.CryptBlocks(out, in)
+
+	return out, nil
 }
 
-// Decrypt is an ECB method for encryption
-func (ecb *ECB) Decrypt(in []byte) []byte {
-	if len(in)%ecb.blockSize != 0 {
-		panic("The ciphertext does not fill the blocks. Remainder is " +
-			strconv.Itoa(len(in)%ecb.blockSize) + " for block size " +
-			strconv.Itoa(ecb.blockSize))
+// Decrypt is an ECB method for decryption
+func (ecb *ECB) Decrypt(in []byte) ([]byte, error) {
+	if err := checkFullBlocks(in, ecb.blockSize); err != nil {
+		return nil, err
 	}
 
 	out := make([]byte, len(in))
+	dec := ECBDecrypter{aes: ecb.aes, blockSize: ecb.blockSize, concurrency: ecb.concurrency}
+	dec.CryptBlocks(out, in)
 
-	for i := 0; i < len(in); i += ecb.blockSize {
-		ecb.aes.Decrypt(out[i:i+ecb.blockSize], in[i:i+ecb.blockSize])
-	}
-
-	return out
+	return out, nil
 }
 
 // NewCBC is a constructor for the CBC class
-func NewCBC(b cipher.Block, inputVec []byte) *CBC {
+// The input vector is copied, the caller's slice is never modified
+func NewCBC(b cipher.Block, inputVec []byte) (*CBC, error) {
+	if b == nil {
+		return nil, ErrNilBlock
+	}
+	if len(inputVec) != b.BlockSize() {
+		return nil, ErrIVSize
+	}
 	return &CBC{
 		aes:       b,
 		blockSize: b.BlockSize(),
-		inputVec:  inputVec,
-	}
+		inputVec:  append([]byte(nil), inputVec...),
+	}, nil
 }
 
 // Encrypt is a CBC method for encryption
-func (cbc *CBC) Encrypt(in []byte) []byte {
-	out := make([]byte, len(in))
-
-	for i := 0; i < len(in); i += cbc.blockSize {
-		xor(out[i:i+cbc.blockSize], in[i:i+cbc.blockSize], cbc.inputVec)
-		cbc.aes.Encrypt(out[i:i+cbc.blockSize], out[i:i+cbc.blockSize])
-		cbc.inputVec = out[i : i+cbc.blockSize]
+func (cbc *CBC) Encrypt(in []byte) ([]byte, error) {
+	if err := checkFullBlocks(in, cbc.blockSize); err != nil {
+		return nil, err
 	}
 
-	return out
+	out := make([]byte, len(in))
+	// The encrypter shares the input vector, so the chaining state carries over
+	enc := CBCEncrypter{aes: cbc.aes, blockSize: cbc.blockSize, inputVec: cbc.inputVec}
+	enc.CryptBlocks(out, in)
+
+	return out, nil
 }
 
 // Decrypt is a CBC method for decryption
-func (cbc *CBC) Decrypt(in []byte) []byte {
-	if len(in)%cbc.blockSize != 0 {
-		panic("The ciphertext does not fill the blocks. Remainder is " +
-			strconv.Itoa(len(in)%cbc.blockSize) + " for block size " +
-			strconv.Itoa(cbc.blockSize))
+func (cbc *CBC) Decrypt(in []byte) ([]byte, error) {
+	if err := checkFullBlocks(in, cbc.blockSize); err != nil {
+		return nil, err
 	}
 
 	out := make([]byte, len(in))
+	// The decrypter shares the input vector, so the chaining state carries over
+	dec := CBCDecrypter{aes: cbc.aes, blockSize: cbc.blockSize, inputVec: cbc.inputVec, concurrency: cbc.concurrency}
+	dec.CryptBlocks(out, in)
 
-	// Temporarily store the last `blockSize` bytes that will
-	// serve as input vector for further encryption afterwards
-	lastInputVec := make([]byte, len(cbc.inputVec))
-	copy(lastInputVec, in[len(in)-cbc.blockSize:])
+	return out, nil
+}
 
-	for i := len(in) - cbc.blockSize; i > 0; i -= cbc.blockSize {
-		cbc.aes.Decrypt(in[i:i+cbc.blockSize], in[i:i+cbc.blockSize])
-		xor(out[i:i+cbc.blockSize], in[i:i+cbc.blockSize], in[i-cbc.blockSize:i])
+// checkFullBlocks returns an error if the input is not a multiple of the block size
+func checkFullBlocks(in []byte, blockSize int) error {
+	if len(in)%blockSize != 0 {
+		return fmt.Errorf("%w: remainder is %d for block size %d",
+			ErrNotFullBlocks, len(in)%blockSize, blockSize)
 	}
-
-	cbc.aes.Decrypt(in[:cbc.blockSize], in[:cbc.blockSize])
-	xor(out[:cbc.blockSize], in[:cbc.blockSize], cbc.inputVec)
-
-	cbc.inputVec = lastInputVec
-
-	return out
+	return nil
 }
@@ -24,13 +24,15 @@
 	aes_test.go Daniel Havir, 2018
 */
 
-package main
+package goaes
 
 import (
 	"bytes"
 	"crypto/aes"
+	hex "encoding/hex"
 	"encoding/json"
 	"fmt"
+	"os"
 	"testing"
 )
 
@@ -46,21 +48,64 @@ type testfile struct {
 	Decrypt []teststruct
 }
 
+// katCipher creates the block cipher for the known answer tests,
+// TestNativeKAT runs them again with NewCipher
+var katCipher = aes.NewCipher
+
+func check(e error) {
+	if e != nil {
+		panic(e)
+	}
+}
+
+func decodehex(src []byte) []byte {
+	dst := make([]byte, hex.DecodedLen(len(src)))
+	hex.Decode(dst, src)
+	return dst
+}
+
+func encodehex(src []byte) []byte {
+	dst := make([]byte, hex.EncodedLen(len(src)))
+	hex.Encode(dst, src)
+	return dst
+}
+
+// readtestfile reads a parsed KAT file, skipping the test if the
+// KAT files have not been extracted with setup/aes-tests.sh
+func readtestfile(t *testing.T, path string) []byte {
+	dat, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Skip("KAT file ", path, " not found, run setup/aes-tests.sh first")
+	}
+	check(err)
+	return dat
+}
+
 func cbcEncTestrun(t *testing.T, tests []teststruct) int {
 	numTests := 0
 	for _, test := range tests {
 		key := decodehex([]byte(test.Key))
-		block, err := aes.NewCipher(key)
+		block, err := katCipher(key)
 		check(err)
 		inputVec := decodehex([]byte(test.Iv))
-		cipher := NewCBC(block, inputVec)
+		cipher, err := NewCBC(block, inputVec)
+		check(err)
 		plaintext := decodehex([]byte(test.Plaintext))
 		expected := decodehex([]byte(test.Ciphertext))
-		encrypted := cipher.Encrypt(plaintext)
+		encrypted, err := cipher.Encrypt(plaintext)
+		check(err)
 		if !(bytes.Equal(encrypted, expected)) {
 			t.Error("Expected ", string(encodehex(expected)),
 				",got ", string(encodehex(encrypted)))
 		}
+		mode, err := NewCBCEncrypter(block, inputVec)
+		check(err)
+		inplace := append([]byte(nil), plaintext...)
+		mode.CryptBlocks(inplace, inplace)
+		if !(bytes.Equal(inplace, expected)) {
+			t.Error("CBCEncrypter expected ", string(encodehex(expected)),
+				",got ", string(encodehex(inplace)))
+		}
 		numTests++
 	}
 	return numTests
@@ -70,17 +115,27 @@ func cbcDecTestrun(t *testing.T, tests []teststruct) int {
 	numTests := 0
 	for _, test := range tests {
 		key := decodehex([]byte(test.Key))
-		block, err := aes.NewCipher(key)
+		block, err := katCipher(key)
 		check(err)
 		inputVec := decodehex([]byte(test.Iv))
-		cipher := NewCBC(block, inputVec)
+		cipher, err := NewCBC(block, inputVec)
+		check(err)
 		ciphertext := decodehex([]byte(test.Ciphertext))
 		expected := decodehex([]byte(test.Plaintext))
-		decrypted := cipher.Decrypt(ciphertext)
+		decrypted, err := cipher.Decrypt(ciphertext)
+		check(err)
 		if !(bytes.Equal(decrypted, expected)) {
 			t.Error("Expected ", string(encodehex(expected)),
 				",got ", string(encodehex(decrypted)))
 		}
+		mode, err := NewCBCDecrypter(block, inputVec)
+		check(err)
+		inplace := append([]byte(nil), ciphertext...)
+		mode.CryptBlocks(inplace, inplace)
+		if !(bytes.Equal(inplace, expected)) {
+			t.Error("CBCDecrypter expected ", string(encodehex(expected)),
+				",got ", string(encodehex(inplace)))
+		}
 		numTests++
 	}
 	return numTests
@@ -90,16 +145,26 @@ func ecbEncTestrun(t *testing.T, tests []teststruct) int {
 	numTests := 0
 	for _, test := range tests {
 		key := decodehex([]byte(test.Key))
-		block, err := aes.NewCipher(key)
+		block, err := katCipher(key)
+		check(err)
+		cipher, err := NewECB(block)
 		check(err)
-		cipher := NewECB(block)
 		plaintext := decodehex([]byte(test.Plaintext))
 		expected := decodehex([]byte(test.Ciphertext))
-		encrypted := cipher.Encrypt(plaintext)
+		encrypted, err := cipher.Encrypt(plaintext)
+		check(err)
 		if !(bytes.Equal(encrypted, expected)) {
 			t.Error("Expected ", string(encodehex(expected)),
 				",got ", string(encodehex(encrypted)))
 		}
+		mode, err := NewECBEncrypter(block)
+		check(err)
+		inplace := append([]byte(nil), plaintext...)
+		mode.CryptBlocks(inplace, inplace)
+		if !(bytes.Equal(inplace, expected)) {
+			t.Error("ECBEncrypter expected ", string(encodehex(expected)),
+				",got ", string(encodehex(inplace)))
+		}
 		numTests++
 	}
 	return numTests
@@ -109,16 +174,26 @@ func ecbDecTestrun(t *testing.T, tests []teststruct) int {
 	numTests := 0
 	for _, test := range tests {
 		key := decodehex([]byte(test.Key))
-		block, err := aes.NewCipher(key)
+		block, err := katCipher(key)
+		check(err)
+		cipher, err := NewECB(block)
 		check(err)
-		cipher := NewECB(block)
 		ciphertext := decodehex([]byte(test.Ciphertext))
 		expected := decodehex([]byte(test.Plaintext))
-		decrypted := cipher.Decrypt(ciphertext)
+		decrypted, err := cipher.Decrypt(ciphertext)
+		check(err)
 		if !(bytes.Equal(decrypted, expected)) {
 			t.Error("Expected ", string(encodehex(expected)),
 				",got ", string(encodehex(decrypted)))
 		}
+		mode, err := NewECBDecrypter(block)
+		check(err)
+		inplace := append([]byte(nil), ciphertext...)
+		mode.CryptBlocks(inplace, inplace)
+		if !(bytes.Equal(inplace, expected)) {
+			t.Error("ECBDecrypter expected ", string(encodehex(expected)),
+				",got ", string(encodehex(inplace)))
+		}
 		numTests++
 	}
 	return numTests
@@ -126,7 +201,7 @@ func ecbDecTestrun(t *testing.T, tests []teststruct) int {
 
 func TestCBCGFSbox128(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/CBCGFSbox128.json")
+	testJSON := readtestfile(t, "jsontests/CBCGFSbox128.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := cbcEncTestrun(t, file.Encrypt)
 	numDecTests := cbcDecTestrun(t, file.Decrypt)
@@ -135,7 +210,7 @@ func TestCBCGFSbox128(t *testing.T) {
 
 func TestCBCGFSbox192(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/CBCGFSbox192.json")
+	testJSON := readtestfile(t, "jsontests/CBCGFSbox192.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := cbcEncTestrun(t, file.Encrypt)
 	numDecTests := cbcDecTestrun(t, file.Decrypt)
@@ -144,7 +219,7 @@ func TestCBCGFSbox192(t *testing.T) {
 
 func TestCBCGFSbox256(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/CBCGFSbox256.json")
+	testJSON := readtestfile(t, "jsontests/CBCGFSbox256.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := cbcEncTestrun(t, file.Encrypt)
 	numDecTests := cbcDecTestrun(t, file.Decrypt)
@@ -153,7 +228,7 @@ func TestCBCGFSbox256(t *testing.T) {
 
 func TestCBCKeySbox128(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/CBCKeySbox128.json")
+	testJSON := readtestfile(t, "jsontests/CBCKeySbox128.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := cbcEncTestrun(t, file.Encrypt)
 	numDecTests := cbcDecTestrun(t, file.Decrypt)
@@ -162,7 +237,7 @@ func TestCBCKeySbox128(t *testing.T) {
 
 func TestCBCKeySbox192(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/CBCKeySbox192.json")
+	testJSON := readtestfile(t, "jsontests/CBCKeySbox192.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := cbcEncTestrun(t, file.Encrypt)
 	numDecTests := cbcDecTestrun(t, file.Decrypt)
@@ -171,7 +246,7 @@ func TestCBCKeySbox192(t *testing.T) {
 
 func TestCBCKeySbox256(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/CBCKeySbox256.json")
+	testJSON := readtestfile(t, "jsontests/CBCKeySbox256.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := cbcEncTestrun(t, file.Encrypt)
 	numDecTests := cbcDecTestrun(t, file.Decrypt)
@@ -180,7 +255,7 @@ func TestCBCKeySbox256(t *testing.T) {
 
 func TestCBCVarKey128(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/CBCVarKey128.json")
+	testJSON := readtestfile(t, "jsontests/CBCVarKey128.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := cbcEncTestrun(t, file.Encrypt)
 	numDecTests := cbcDecTestrun(t, file.Decrypt)
@@ -189,7 +264,7 @@ func TestCBCVarKey128(t *testing.T) {
 
 func TestCBCVarKey192(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/CBCVarKey192.json")
+	testJSON := readtestfile(t, "jsontests/CBCVarKey192.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := cbcEncTestrun(t, file.Encrypt)
 	numDecTests := cbcDecTestrun(t, file.Decrypt)
@@ -198,7 +273,7 @@ func TestCBCVarKey192(t *testing.T) {
 
 func TestCBCVarKey256(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/CBCVarKey256.json")
+	testJSON := readtestfile(t, "jsontests/CBCVarKey256.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := cbcEncTestrun(t, file.Encrypt)
 	numDecTests := cbcDecTestrun(t, file.Decrypt)
@@ -207,7 +282,7 @@ func TestCBCVarKey256(t *testing.T) {
 
 func TestCBCVarTxt128(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/CBCVarTxt128.json")
+	testJSON := readtestfile(t, "jsontests/CBCVarTxt128.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := cbcEncTestrun(t, file.Encrypt)
 	numDecTests := cbcDecTestrun(t, file.Decrypt)
@@ -216,7 +291,7 @@ func TestCBCVarTxt128(t *testing.T) {
 
 func TestCBCVarTxt192(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/CBCVarTxt192.json")
+	testJSON := readtestfile(t, "jsontests/CBCVarTxt192.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := cbcEncTestrun(t, file.Encrypt)
 	numDecTests := cbcDecTestrun(t, file.Decrypt)
@@ -225,7 +300,7 @@ func TestCBCVarTxt192(t *testing.T) {
 
 func TestCBCVarTxt256(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/CBCVarTxt256.json")
+	testJSON := readtestfile(t, "jsontests/CBCVarTxt256.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := cbcEncTestrun(t, file.Encrypt)
 	numDecTests := cbcDecTestrun(t, file.Decrypt)
@@ -234,7 +309,7 @@ func TestCBCVarTxt256(t *testing.T) {
 
 func TestECBGFSbox128(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/ECBGFSbox128.json")
+	testJSON := readtestfile(t, "jsontests/ECBGFSbox128.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := ecbEncTestrun(t, file.Encrypt)
 	numDecTests := ecbDecTestrun(t, file.Decrypt)
@@ -243,7 +318,7 @@ func TestECBGFSbox128(t *testing.T) {
 
 func TestECBGFSbox192(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/ECBGFSbox192.json")
+	testJSON := readtestfile(t, "jsontests/ECBGFSbox192.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := ecbEncTestrun(t, file.Encrypt)
 	numDecTests := ecbDecTestrun(t, file.Decrypt)
@@ -252,7 +327,7 @@ func TestECBGFSbox192(t *testing.T) {
 
 func TestECBGFSbox256(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/ECBGFSbox256.json")
+	testJSON := readtestfile(t, "jsontests/ECBGFSbox256.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := ecbEncTestrun(t, file.Encrypt)
 	numDecTests := ecbDecTestrun(t, file.Decrypt)
@@ -261,7 +336,7 @@ func TestECBGFSbox256(t *testing.T) {
 
 func TestECBKeySbox128(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/ECBKeySbox128.json")
+	testJSON := readtestfile(t, "jsontests/ECBKeySbox128.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := ecbEncTestrun(t, file.Encrypt)
 	numDecTests := ecbDecTestrun(t, file.Decrypt)
@@ -270,7 +345,7 @@ func TestECBKeySbox128(t *testing.T) {
 
 func TestECBKeySbox192(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/ECBKeySbox192.json")
+	testJSON := readtestfile(t, "jsontests/ECBKeySbox192.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := ecbEncTestrun(t, file.Encrypt)
 	numDecTests := ecbDecTestrun(t, file.Decrypt)
@@ -279,7 +354,7 @@ func TestECBKeySbox192(t *testing.T) {
 
 func TestECBKeySbox256(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/ECBKeySbox256.json")
+	testJSON := readtestfile(t, "jsontests/ECBKeySbox256.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := ecbEncTestrun(t, file.Encrypt)
 	numDecTests := ecbDecTestrun(t, file.Decrypt)
@@ -288,7 +363,7 @@ func TestECBKeySbox256(t *testing.T) {
 
 func TestECBVarKey128(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/ECBVarKey128.json")
+	testJSON := readtestfile(t, "jsontests/ECBVarKey128.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := ecbEncTestrun(t, file.Encrypt)
 	numDecTests := ecbDecTestrun(t, file.Decrypt)
@@ -297,7 +372,7 @@ func TestECBVarKey128(t *testing.T) {
 
 func TestECBVarKey192(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/ECBVarKey192.json")
+	testJSON := readtestfile(t, "jsontests/ECBVarKey192.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := ecbEncTestrun(t, file.Encrypt)
 	numDecTests := ecbDecTestrun(t, file.Decrypt)
@@ -306,7 +381,7 @@ func TestECBVarKey192(t *testing.T) {
 
 func TestECBVarKey256(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/ECBVarKey256.json")
+	testJSON := readtestfile(t, "jsontests/ECBVarKey256.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := ecbEncTestrun(t, file.Encrypt)
 	numDecTests := ecbDecTestrun(t, file.Decrypt)
@@ -315,7 +390,7 @@ func TestECBVarKey256(t *testing.T) {
 
 func TestECBVarTxt128(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/ECBVarTxt128.json")
+	testJSON := readtestfile(t, "jsontests/ECBVarTxt128.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := ecbEncTestrun(t, file.Encrypt)
 	numDecTests := ecbDecTestrun(t, file.Decrypt)
@@ -324,7 +399,7 @@ func TestECBVarTxt128(t *testing.T) {
 
 func TestECBVarTxt192(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/ECBVarTxt192.json")
+	testJSON := readtestfile(t, "jsontests/ECBVarTxt192.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := ecbEncTestrun(t, file.Encrypt)
 	numDecTests := ecbDecTestrun(t, file.Decrypt)
@@ -333,9 +408,169 @@ func TestECBVarTxt192(t *testing.T) {
 
 func TestECBVarTxt256(t *testing.T) {
 	var file testfile
-	testJSON := readfile("jsontests/ECBVarTxt256.json")
+	testJSON := readtestfile(t, "jsontests/ECBVarTxt256.json")
 	json.Unmarshal(testJSON, &file)
 	numEncTests := ecbEncTestrun(t, file.Encrypt)
 	numDecTests := ecbDecTestrun(t, file.Decrypt)
 	fmt.Println("Num ECBVarTxt256 tests: ", numEncTests+numDecTests)
 }
+
+// decodebits packs a CFB1 bit string, e.g. "0110", most significant bit first
+func decodebits(src string) []byte {
+	dst := make([]byte, (len(src)+7)/8)
+	for i, c := range src {
+		if c == '1' {
+			dst[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return dst
+}
+
+// equalbits compares the first n bits of two packed bit strings
+func equalbits(a, b []byte, n int) bool {
+	for i := 0; i < n; i++ {
+		mask := byte(0x80 >> uint(i%8))
+		if a[i/8]&mask != b[i/8]&mask {
+			return false
+		}
+	}
+	return true
+}
+
+// cfbdecode decodes KAT values, which are bit strings for CFB1
+func cfbdecode(src string, segmentBits int) ([]byte, int) {
+	if segmentBits == CFB1 {
+		return decodebits(src), len(src)
+	}
+	dst := decodehex([]byte(src))
+	return dst, 8 * len(dst)
+}
+
+func cfbEncTestrun(t *testing.T, tests []teststruct, segmentBits int) int {
+	numTests := 0
+	for _, test := range tests {
+		key := decodehex([]byte(test.Key))
+		block, err := katCipher(key)
+		check(err)
+		inputVec := decodehex([]byte(test.Iv))
+		cipher, err := NewCFB(block, inputVec, segmentBits)
+		check(err)
+		plaintext, _ := cfbdecode(test.Plaintext, segmentBits)
+		expected, numBits := cfbdecode(test.Ciphertext, segmentBits)
+		encrypted := cipher.Encrypt(plaintext)
+		if !equalbits(encrypted, expected, numBits) {
+			t.Error("Expected ", test.Ciphertext,
+				",got ", string(encodehex(encrypted)))
+		}
+		mode, err := NewCFBEncrypter(block, inputVec, segmentBits)
+		check(err)
+		mode.XORKeyStream(plaintext, plaintext)
+		if !equalbits(plaintext, expected, numBits) {
+			t.Error("CFBEncrypter expected ", test.Ciphertext,
+				",got ", string(encodehex(plaintext)))
+		}
+		numTests++
+	}
+	return numTests
+}
+
+func cfbDecTestrun(t *testing.T, tests []teststruct, segmentBits int) int {
+	numTests := 0
+	for _, test := range tests {
+		key := decodehex([]byte(test.Key))
+		block, err := katCipher(key)
+		check(err)
+		inputVec := decodehex([]byte(test.Iv))
+		cipher, err := NewCFB(block, inputVec, segmentBits)
+		check(err)
+		ciphertext, _ := cfbdecode(test.Ciphertext, segmentBits)
+		expected, numBits := cfbdecode(test.Plaintext, segmentBits)
+		decrypted := cipher.Decrypt(ciphertext)
+		if !equalbits(decrypted, expected, numBits) {
+			t.Error("Expected ", test.Plaintext,
+				",got ", string(encodehex(decrypted)))
+		}
+		mode, err := NewCFBDecrypter(block, inputVec, segmentBits)
+		check(err)
+		mode.XORKeyStream(ciphertext, ciphertext)
+		if !equalbits(ciphertext, expected, numBits) {
+			t.Error("CFBDecrypter expected ", test.Plaintext,
+				",got ", string(encodehex(ciphertext)))
+		}
+		numTests++
+	}
+	return numTests
+}
+
+// katNames lists the KAT files of a mode, e.g. CFB8GFSbox128 ... CFB8VarTxt256
+func katNames(mode string) []string {
+	var names []string
+	for _, kind := range []string{"GFSbox", "KeySbox", "VarKey", "VarTxt"} {
+		for _, keySize := range []string{"128", "192", "256"} {
+			names = append(names, mode+kind+keySize)
+		}
+	}
+	return names
+}
+
+func cfbKATrun(t *testing.T, mode string, segmentBits int) {
+	for _, name := range katNames(mode) {
+		t.Run(name, func(t *testing.T) {
+			var file testfile
+			testJSON := readtestfile(t, "jsontests/"+name+".json")
+			json.Unmarshal(testJSON, &file)
+			numEncTests := cfbEncTestrun(t, file.Encrypt, segmentBits)
+			numDecTests := cfbDecTestrun(t, file.Decrypt, segmentBits)
+			fmt.Println("Num "+name+" tests: ", numEncTests+numDecTests)
+		})
+	}
+}
+
+func TestCFB1KAT(t *testing.T) {
+	cfbKATrun(t, "CFB1", CFB1)
+}
+
+func TestCFB8KAT(t *testing.T) {
+	cfbKATrun(t, "CFB8", CFB8)
+}
+
+func TestCFB128KAT(t *testing.T) {
+	cfbKATrun(t, "CFB128", CFB128)
+}
+
+func ofbTestrun(t *testing.T, tests []teststruct, decrypt bool) int {
+	numTests := 0
+	for _, test := range tests {
+		key := decodehex([]byte(test.Key))
+		block, err := katCipher(key)
+		check(err)
+		inputVec := decodehex([]byte(test.Iv))
+		cipher, err := NewOFB(block, inputVec)
+		check(err)
+		intext := decodehex([]byte(test.Plaintext))
+		expected := decodehex([]byte(test.Ciphertext))
+		if decrypt {
+			intext, expected = expected, intext
+		}
+		outtext := cipher.Encrypt(intext)
+		if !(bytes.Equal(outtext, expected)) {
+			t.Error("Expected ", string(encodehex(expected)),
+				",got ", string(encodehex(outtext)))
+		}
+		numTests++
+	}
+	return numTests
+}
+
+func TestOFBKAT(t *testing.T) {
+	for _, name := range katNames("OFB") {
+		t.Run(name, func(t *testing.T) {
+			var file testfile
+			testJSON := readtestfile(t, "jsontests/"+name+".json")
+			json.Unmarshal(testJSON, &file)
+			numEncTests := ofbTestrun(t, file.Encrypt, false)
+			numDecTests := ofbTestrun(t, file.Decrypt, true)
+			fmt.Println("Num "+name+" tests: ", numEncTests+numDecTests)
+		})
+	}
+}
@@ -0,0 +1,201 @@
+/*
+	bench_test.go
+
+	Benchmarks of the ECB and CBC classes at every key size and at input sizes
+	from one block to the 100MB of bigfile.txt, next to the same operations
+	with crypto/aes and crypto/cipher.
+
+	  go test ./goaes -run XXX -bench . | go run ./cmd/benchtable
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	bench_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"testing"
+)
+
+// benchSizes run from one block to the size of bigfile.txt
+var benchSizes = []struct {
+	name string
+	size int
+}{
+	{"16B", 16},
+	{"1KB", 1 << 10},
+	{"64KB", 64 << 10},
+	{"1MB", 1 << 20},
+	{"100MB", bigFileSize},
+}
+
+// benchmarkSizes runs op for every key and input size, op is given the
+// cipher and the input, which it may overwrite
+func benchmarkSizes(b *testing.B, op func(b *testing.B, block cipher.Block, in []byte)) {
+	for _, keySize := range keySizes {
+		block, err := aes.NewCipher(make([]byte, keySize))
+		check(err)
+		for _, size := range benchSizes {
+			b.Run(fmt.Sprintf("AES-%d/%s", 8*keySize, size.name), func(b *testing.B) {
+				in := make([]byte, size.size)
+				b.SetBytes(int64(size.size))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					op(b, block, in)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkECBEncrypt(b *testing.B) {
+	benchmarkSizes(b, func(b *testing.B, block cipher.Block, in []byte) {
+		ecb, err := NewECB(block)
+		check(err)
+		_, err = ecb.Encrypt(in)
+		check(err)
+	})
+}
+
+func BenchmarkECBDecrypt(b *testing.B) {
+	benchmarkSizes(b, func(b *testing.B, block cipher.Block, in []byte) {
+		ecb, err := NewECB(block)
+		check(err)
+		_, err = ecb.Decrypt(in)
+		check(err)
+	})
+}
+
+func BenchmarkCBCEncrypt(b *testing.B) {
+	inputVec := make([]byte, aes.BlockSize)
+	benchmarkSizes(b, func(b *testing.B, block cipher.Block, in []byte) {
+		cbc, err := NewCBC(block, inputVec)
+		check(err)
+		_, err = cbc.Encrypt(in)
+		check(err)
+	})
+}
+
+func BenchmarkCBCDecrypt(b *testing.B) {
+	inputVec := make([]byte, aes.BlockSize)
+	benchmarkSizes(b, func(b *testing.B, block cipher.Block, in []byte) {
+		cbc, err := NewCBC(block, inputVec)
+		check(err)
+		_, err = cbc.Decrypt(in)
+		check(err)
+	})
+}
+
+// The standard library has no ECB mode, its counterpart is a loop over
+// the blocks of crypto/aes
+// The stdlib benchmarks allocate their output like the ECB and CBC classes
+
+func BenchmarkStdlibECBEncrypt(b *testing.B) {
+	benchmarkSizes(b, func(b *testing.B, block cipher.Block, in []byte) {
+		out := make([]byte, len(in))
+		for i := 0; i < len(in); i += aes.BlockSize {
+			block.Encrypt(out[i:], in[i:])
+		}
+	})
+}
+
+func BenchmarkStdlibECBDecrypt(b *testing.B) {
+	benchmarkSizes(b, func(b *testing.B, block cipher.Block, in []byte) {
+		out := make([]byte, len(in))
+		for i := 0; i < len(in); i += aes.BlockSize {
+			block.Decrypt(out[i:], in[i:])
+		}
+	})
+}
+
+func BenchmarkStdlibCBCEncrypt(b *testing.B) {
+	inputVec := make([]byte, aes.BlockSize)
+	benchmarkSizes(b, func(b *testing.B, block cipher.Block, in []byte) {
+		out := make([]byte, len(in))
+		cipher.NewCBCEncrypter(block, inputVec).CryptBlocks(out, in)
+	})
+}
+
+func BenchmarkStdlibCBCDecrypt(b *testing.B) {
+	inputVec := make([]byte, aes.BlockSize)
+	benchmarkSizes(b, func(b *testing.B, block cipher.Block, in []byte) {
+		out := make([]byte, len(in))
+		cipher.NewCBCDecrypter(block, inputVec).CryptBlocks(out, in)
+	})
+}
+
+// blockImpls are the AES implementations compared by the block benchmarks
+var blockImpls = []struct {
+	name      string
+	newCipher func([]byte) (cipher.Block, error)
+}{
+	{"Native", NewCipher},
+	{"Bitsliced", NewBitslicedCipher},
+	{"Stdlib", aes.NewCipher},
+}
+
+// BenchmarkBlock compares single block encryption and decryption of the
+// pure-Go NewCipher and NewBitslicedCipher with crypto/aes, by key size
+func BenchmarkBlock(b *testing.B) {
+	for _, impl := range blockImpls {
+		for _, keySize := range keySizes {
+			block, err := impl.newCipher(make([]byte, keySize))
+			check(err)
+			buf := make([]byte, BlockSize)
+			b.Run(fmt.Sprintf("%sEncrypt/%dbit", impl.name, 8*keySize), func(b *testing.B) {
+				b.SetBytes(BlockSize)
+				for i := 0; i < b.N; i++ {
+					block.Encrypt(buf, buf)
+				}
+			})
+			b.Run(fmt.Sprintf("%sDecrypt/%dbit", impl.name, 8*keySize), func(b *testing.B) {
+				b.SetBytes(BlockSize)
+				for i := 0; i < b.N; i++ {
+					block.Decrypt(buf, buf)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkBlockModes compares ECB and CTR on 64KB, where the bitsliced
+// AES encrypts four blocks at once
+func BenchmarkBlockModes(b *testing.B) {
+	buf := make([]byte, 64<<10)
+	for _, impl := range blockImpls {
+		for _, keySize := range keySizes {
+			block, err := impl.newCipher(make([]byte, keySize))
+			check(err)
+			b.Run(fmt.Sprintf("%sECB/%dbit", impl.name, 8*keySize), func(b *testing.B) {
+				enc, err := NewECBEncrypter(block)
+				check(err)
+				b.SetBytes(int64(len(buf)))
+				for i := 0; i < b.N; i++ {
+					enc.CryptBlocks(buf, buf)
+				}
+			})
+			b.Run(fmt.Sprintf("%sCTR/%dbit", impl.name, 8*keySize), func(b *testing.B) {
+				ctr, err := NewCTR(block, make([]byte, BlockSize), CounterFull)
+				check(err)
+				b.SetBytes(int64(len(buf)))
+				for i := 0; i < b.N; i++ {
+					ctr.XORKeyStream(buf, buf)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,423 @@
+/*
+	bitsliced.go
+
+	Constant-time bitsliced AES. Four blocks are transposed into eight 64-bit
+	bit planes and the round transformations are computed with logical
+	operations only, the S-box as the Boyar-Peralta circuit, so that neither
+	the memory accesses nor the branches depend on the key or the data.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	bitsliced.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+)
+
+// bitsliceBlocks is the number of blocks processed together
+const bitsliceBlocks = 4
+
+// bitsliceSize is the number of bytes processed together
+const bitsliceSize = bitsliceBlocks * BlockSize
+
+var (
+	_ cipher.Block = (*bitslicedCipher)(nil)
+	_ multiBlock   = (*bitslicedCipher)(nil)
+)
+
+// bitslicedState holds four blocks as eight bit planes, bit i of plane b
+// is bit b of byte i of the blocks laid out one after another
+type bitslicedState [8]uint64
+
+// bitslicedCipher is the class for the constant-time bitsliced AES
+type bitslicedCipher struct {
+	rounds int
+	// The round keys, each repeated in all four blocks of the state
+	roundKeys []bitslicedState
+}
+
+// NewBitslicedCipher is a constructor for the bitsliced AES block cipher,
+// the key selects AES-128, AES-192 or AES-256 by its length
+// It runs in constant time, including the key expansion, and encrypts four
+// blocks at the cost of one, which ECB and CTR take advantage of
+func NewBitslicedCipher(key []byte) (cipher.Block, error) {
+	if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+		return nil, ErrKeySize
+	}
+	c := &bitslicedCipher{rounds: len(key)/4 + 6}
+	schedule := expandKey(key, c.rounds, bitslicedSubWord)
+
+	c.roundKeys = make([]bitslicedState, c.rounds+1)
+	var buf [bitsliceSize]byte
+	for round := range c.roundKeys {
+		for i := 0; i < bitsliceSize; i += BlockSize {
+			copy(buf[i:], schedule[round*BlockSize:(round+1)*BlockSize])
+		}
+		c.roundKeys[round].load(buf[:])
+	}
+	return c, nil
+}
+
+// bitslicedSubWord applies the S-box circuit to a key schedule word
+func bitslicedSubWord(word *[4]byte) {
+	var buf [bitsliceSize]byte
+	copy(buf[:], word[:])
+	var q bitslicedState
+	q.load(buf[:])
+	q.subBytes()
+	q.store(buf[:])
+	copy(word[:], buf[:])
+}
+
+// BlockSize returns the AES block size
+func (c *bitslicedCipher) BlockSize() int { return BlockSize }
+
+// Encrypt encrypts the first block of src into dst, dst and src may overlap entirely
+// A single block costs as much as four, see EncryptBlocks
+func (c *bitslicedCipher) Encrypt(dst, src []byte) {
+	checkBlock(dst, src)
+	var buf [bitsliceSize]byte
+	copy(buf[:], src[:BlockSize])
+	c.encrypt(buf[:])
+	copy(dst, buf[:BlockSize])
+}
+
+// Decrypt decrypts the first block of src into dst, dst and src may overlap entirely
+func (c *bitslicedCipher) Decrypt(dst, src []byte) {
+	checkBlock(dst, src)
+	var buf [bitsliceSize]byte
+	copy(buf[:], src[:BlockSize])
+	c.decrypt(buf[:])
+	copy(dst, buf[:BlockSize])
+}
+
+// EncryptBlocks encrypts whole blocks of src into dst four at a time,
+// dst and src may be the same slice
+func (c *bitslicedCipher) EncryptBlocks(dst, src []byte) {
+	c.cryptBlocks(dst, src, c.encrypt)
+}
+
+// DecryptBlocks decrypts whole blocks of src into dst four at a time,
+// dst and src may be the same slice
+func (c *bitslicedCipher) DecryptBlocks(dst, src []byte) {
+	c.cryptBlocks(dst, src, c.decrypt)
+}
+
+func (c *bitslicedCipher) cryptBlocks(dst, src []byte, crypt func(buf []byte)) {
+	checkCryptBlocks(dst, src, BlockSize)
+	var buf [bitsliceSize]byte
+	for len(src) > 0 {
+		n := copy(buf[:], src)
+		crypt(buf[:])
+		copy(dst, buf[:n])
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+// encrypt encrypts the four blocks of buf in place
+func (c *bitslicedCipher) encrypt(buf []byte) {
+	var q bitslicedState
+	q.load(buf)
+
+	q.addRoundKey(&c.roundKeys[0])
+	for round := 1; round < c.rounds; round++ {
+		q.subBytes()
+		q.shiftRows()
+		q.mixColumns()
+		q.addRoundKey(&c.roundKeys[round])
+	}
+	q.subBytes()
+	q.shiftRows()
+	q.addRoundKey(&c.roundKeys[c.rounds])
+
+	q.store(buf)
+}
+
+// decrypt decrypts the four blocks of buf in place
+func (c *bitslicedCipher) decrypt(buf []byte) {
+	var q bitslicedState
+	q.load(buf)
+
+	q.addRoundKey(&c.roundKeys[c.rounds])
+	for round := c.rounds - 1; round > 0; round-- {
+		q.invShiftRows()
+		q.invSubBytes()
+		q.addRoundKey(&c.roundKeys[round])
+		q.invMixColumns()
+	}
+	q.invShiftRows()
+	q.invSubBytes()
+	q.addRoundKey(&c.roundKeys[0])
+
+	q.store(buf)
+}
+
+// load transposes the 64 bytes of src into bit planes
+func (q *bitslicedState) load(src []byte) {
+	*q = bitslicedState{}
+	for j := 0; j < 8; j++ {
+		x := transpose8(binary.LittleEndian.Uint64(src[8*j:]))
+		for b := range q {
+			q[b] |= (x >> (8 * b) & 0xff) << (8 * j)
+		}
+	}
+}
+
+// store transposes the bit planes back into the 64 bytes of dst
+func (q *bitslicedState) store(dst []byte) {
+	for j := 0; j < 8; j++ {
+		var x uint64
+		for b := range q {
+			x |= (q[b] >> (8 * j) & 0xff) << (8 * b)
+		}
+		binary.LittleEndian.PutUint64(dst[8*j:], transpose8(x))
+	}
+}
+
+// transpose8 transposes the 8x8 bit matrix whose row i is byte i of x,
+// see Hacker's Delight section 7-3
+func transpose8(x uint64) uint64 {
+	t := (x ^ x>>7) & 0x00aa00aa00aa00aa
+	x ^= t ^ t<<7
+	t = (x ^ x>>14) & 0x0000cccc0000cccc
+	x ^= t ^ t<<14
+	t = (x ^ x>>28) & 0x00000000f0f0f0f0
+	return x ^ t ^ t<<28
+}
+
+func (q *bitslicedState) addRoundKey(roundKey *bitslicedState) {
+	for b := range q {
+		q[b] ^= roundKey[b]
+	}
+}
+
+// Within each block, byte r+4c of the state is in row r and column c, so
+// each 16-bit lane of a plane holds one block and each nibble one column
+
+// shiftRows rotates row r of every block to the left by r bytes
+func (q *bitslicedState) shiftRows() {
+	for b, x := range q {
+		q[b] = x&0x1111111111111111 |
+			x>>4&0x0222022202220222 | x<<12&0x2000200020002000 |
+			x>>8&0x0044004400440044 | x<<8&0x4400440044004400 |
+			x>>12&0x0008000800080008 | x<<4&0x8880888088808880
+	}
+}
+
+// invShiftRows rotates row r of every block to the right by r bytes
+func (q *bitslicedState) invShiftRows() {
+	for b, x := range q {
+		q[b] = x&0x1111111111111111 |
+			x<<4&0x2220222022202220 | x>>12&0x0002000200020002 |
+			x<<8&0x4400440044004400 | x>>8&0x0044004400440044 |
+			x<<12&0x8000800080008000 | x>>4&0x0888088808880888
+	}
+}
+
+// rotateRows moves row r+n of every column to row r, modulo 4
+func rotateRows(x uint64, n uint) uint64 {
+	low := uint64(0x1111111111111111) * (1<<(4-n) - 1)
+	return x>>n&low | x<<(4-n)&^low
+}
+
+// mulX multiplies the bytes of the planes by x modulo x^8 + x^4 + x^3 + x + 1
+func (q *bitslicedState) mulX() {
+	hi := q[7]
+	q[7], q[6], q[5] = q[6], q[5], q[4]
+	q[4] = q[3] ^ hi
+	q[3] = q[2] ^ hi
+	q[2] = q[1]
+	q[1] = q[0] ^ hi
+	q[0] = hi
+}
+
+// mixColumns computes 2*a[r] ^ 3*a[r+1] ^ a[r+2] ^ a[r+3] for every row r
+// of every column, as 2*(a[r] ^ a[r+1]) ^ a[r+1] ^ a[r+2] ^ a[r+3]
+func (q *bitslicedState) mixColumns() {
+	var t bitslicedState
+	for b, x := range q {
+		t[b] = x ^ rotateRows(x, 1)
+	}
+	t.mulX()
+	for b, x := range q {
+		q[b] = t[b] ^ rotateRows(x, 1) ^ rotateRows(x, 2) ^ rotateRows(x, 3)
+	}
+}
+
+// invMixColumns multiplies by {04}x^2 + {05} before mixColumns, as the
+// table-based invMixColumns does
+func (q *bitslicedState) invMixColumns() {
+	var t bitslicedState
+	for b, x := range q {
+		t[b] = x ^ rotateRows(x, 2)
+	}
+	t.mulX()
+	t.mulX()
+	for b := range q {
+		q[b] ^= t[b]
+	}
+	q.mixColumns()
+}
+
+// invSubBytes computes the inverse S-box as L(S(L(x))), where L is the
+// inverse affine transformation A^-1(x ^ {63}) = A^-1(x) ^ {05}
+func (q *bitslicedState) invSubBytes() {
+	q.invAffine()
+	q.subBytes()
+	q.invAffine()
+}
+
+func (q *bitslicedState) invAffine() {
+	var t bitslicedState
+	for i := range t {
+		t[i] = q[(i+2)%8] ^ q[(i+5)%8] ^ q[(i+7)%8]
+	}
+	t[0] = ^t[0]
+	t[2] = ^t[2]
+	*q = t
+}
+
+// subBytes evaluates the S-box circuit of Boyar and Peralta (113 gates)
+// on the planes, x0 is the most significant bit
+func (q *bitslicedState) subBytes() {
+	x0, x1, x2, x3, x4, x5, x6, x7 := q[7], q[6], q[5], q[4], q[3], q[2], q[1], q[0]
+
+	// Top linear transformation
+	y14 := x3 ^ x5
+	y13 := x0 ^ x6
+	y9 := x0 ^ x3
+	y8 := x0 ^ x5
+	t0 := x1 ^ x2
+	y1 := t0 ^ x7
+	y4 := y1 ^ x3
+	y12 := y13 ^ y14
+	y2 := y1 ^ x0
+	y5 := y1 ^ x6
+	y3 := y5 ^ y8
+	t1 := x4 ^ y12
+	y15 := t1 ^ x5
+	y20 := t1 ^ x1
+	y6 := y15 ^ x7
+	y10 := y15 ^ t0
+	y11 := y20 ^ y9
+	y7 := x7 ^ y11
+	y17 := y10 ^ y11
+	y19 := y10 ^ y8
+	y16 := t0 ^ y11
+	y21 := y13 ^ y16
+	y18 := x0 ^ y16
+
+	// Non-linear section
+	t2 := y12 & y15
+	t3 := y3 & y6
+	t4 := t3 ^ t2
+	t5 := y4 & x7
+	t6 := t5 ^ t2
+	t7 := y13 & y16
+	t8 := y5 & y1
+	t9 := t8 ^ t7
+	t10 := y2 & y7
+	t11 := t10 ^ t7
+	t12 := y9 & y11
+	t13 := y14 & y17
+	t14 := t13 ^ t12
+	t15 := y8 & y10
+	t16 := t15 ^ t12
+	t17 := t4 ^ t14
+	t18 := t6 ^ t16
+	t19 := t9 ^ t14
+	t20 := t11 ^ t16
+	t21 := t17 ^ y20
+	t22 := t18 ^ y19
+	t23 := t19 ^ y21
+	t24 := t20 ^ y18
+
+	t25 := t21 ^ t22
+	t26 := t21 & t23
+	t27 := t24 ^ t26
+	t28 := t25 & t27
+	t29 := t28 ^ t22
+	t30 := t23 ^ t24
+	t31 := t22 ^ t26
+	t32 := t31 & t30
+	t33 := t32 ^ t24
+	t34 := t23 ^ t33
+	t35 := t27 ^ t33
+	t36 := t24 & t35
+	t37 := t36 ^ t34
+	t38 := t27 ^ t36
+	t39 := t29 & t38
+	t40 := t25 ^ t39
+
+	t41 := t40 ^ t37
+	t42 := t29 ^ t33
+	t43 := t29 ^ t40
+	t44 := t33 ^ t37
+	t45 := t42 ^ t41
+	z0 := t44 & y15
+	z1 := t37 & y6
+	z2 := t33 & x7
+	z3 := t43 & y16
+	z4 := t40 & y1
+	z5 := t29 & y7
+	z6 := t42 & y11
+	z7 := t45 & y17
+	z8 := t41 & y10
+	z9 := t44 & y12
+	z10 := t37 & y3
+	z11 := t33 & y4
+	z12 := t43 & y13
+	z13 := t40 & y5
+	z14 := t29 & y2
+	z15 := t42 & y9
+	z16 := t45 & y14
+	z17 := t41 & y8
+
+	// Bottom linear transformation
+	t46 := z15 ^ z16
+	t47 := z10 ^ z11
+	t48 := z5 ^ z13
+	t49 := z9 ^ z10
+	t50 := z2 ^ z12
+	t51 := z2 ^ z5
+	t52 := z7 ^ z8
+	t53 := z0 ^ z3
+	t54 := z6 ^ z7
+	t55 := z16 ^ z17
+	t56 := z12 ^ t48
+	t57 := t50 ^ t53
+	t58 := z4 ^ t46
+	t59 := z3 ^ t54
+	t60 := t46 ^ t57
+	t61 := z14 ^ t57
+	t62 := t52 ^ t58
+	t63 := t49 ^ t58
+	t64 := z4 ^ t59
+	t65 := t61 ^ t62
+	t66 := z1 ^ t63
+	s0 := t59 ^ t63
+	s6 := t56 ^ ^t62
+	s7 := t48 ^ ^t60
+	t67 := t64 ^ t65
+	s3 := t53 ^ t66
+	s4 := t51 ^ t66
+	s5 := t47 ^ t65
+	s1 := t64 ^ ^s3
+	s2 := t55 ^ ^t67
+
+	q[7], q[6], q[5], q[4], q[3], q[2], q[1], q[0] = s0, s1, s2, s3, s4, s5, s6, s7
+}
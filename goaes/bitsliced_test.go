@@ -0,0 +1,326 @@
+/*
+	bitsliced_test.go
+
+	Tests of the bitsliced AES: FIPS 197 vectors, the S-box circuit, every
+	known answer test, differential tests of the multi-block ECB and CTR
+	paths against crypto/aes and a dudect-style constant-time test.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	bitsliced_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"math"
+	mrand "math/rand"
+	"os"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestBitslicedFIPS197(t *testing.T) {
+	plaintext := decodehex([]byte(fips197plaintext))
+	for _, test := range fips197tests {
+		block, err := NewBitslicedCipher(decodehex([]byte(test.key)))
+		check(err)
+		expected := decodehex([]byte(test.ciphertext))
+
+		encrypted := make([]byte, BlockSize)
+		block.Encrypt(encrypted, plaintext)
+		if !bytes.Equal(encrypted, expected) {
+			t.Error("Expected ", test.ciphertext, ",got ", string(encodehex(encrypted)))
+		}
+		block.Decrypt(encrypted, encrypted)
+		if !bytes.Equal(encrypted, plaintext) {
+			t.Error("Expected ", fips197plaintext, ",got ", string(encodehex(encrypted)))
+		}
+	}
+}
+
+func TestBitslicedSbox(t *testing.T) {
+	var in, out [bitsliceSize]byte
+	for base := 0; base < 256; base += bitsliceSize {
+		for i := range in {
+			in[i] = byte(base + i)
+		}
+		var q bitslicedState
+		q.load(in[:])
+		q.subBytes()
+		q.store(out[:])
+		for i, b := range out {
+			if b != sbox[base+i] {
+				t.Error("S-box of ", base+i, ": expected ", sbox[base+i], ",got ", b)
+			}
+		}
+		q.load(in[:])
+		q.invSubBytes()
+		q.store(out[:])
+		for i, b := range out {
+			if b != invSbox[base+i] {
+				t.Error("Inverse S-box of ", base+i, ": expected ", invSbox[base+i], ",got ", b)
+			}
+		}
+	}
+}
+
+func TestBitslicedKeySize(t *testing.T) {
+	for keySize := 0; keySize <= 40; keySize++ {
+		_, err := NewBitslicedCipher(make([]byte, keySize))
+		valid := keySize == 16 || keySize == 24 || keySize == 32
+		if valid != (err == nil) {
+			t.Error("Unexpected result for key size ", keySize, ": ", err)
+		}
+	}
+}
+
+// TestBitslicedKAT runs every known answer test of the package with NewBitslicedCipher
+func TestBitslicedKAT(t *testing.T) {
+	runKATWith(t, NewBitslicedCipher)
+}
+
+func TestBitslicedDifferential(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(5))
+	for i := 0; i < differentialRounds; i++ {
+		key := randomBytes(keySizes[rng.Intn(len(keySizes))])
+		bitsliced, err := NewBitslicedCipher(key)
+		check(err)
+		stdlib, err := aes.NewCipher(key)
+		check(err)
+
+		// Block counts that do and do not fill the four lanes
+		src := randomBytes(BlockSize * rng.Intn(4*bitsliceBlocks))
+		expected := make([]byte, len(src))
+		for j := 0; j < len(src); j += BlockSize {
+			stdlib.Encrypt(expected[j:], src[j:])
+		}
+		got := append([]byte(nil), src...)
+		bitsliced.(multiBlock).EncryptBlocks(got, got)
+		if !bytes.Equal(got, expected) {
+			t.Fatal("EncryptBlocks differs for ", len(src)/BlockSize, " blocks")
+		}
+		bitsliced.(multiBlock).DecryptBlocks(got, got)
+		if !bytes.Equal(got, src) {
+			t.Fatal("DecryptBlocks differs for ", len(src)/BlockSize, " blocks")
+		}
+
+		if len(src) > 0 {
+			bitsliced.Encrypt(got, src)
+			if !bytes.Equal(got[:BlockSize], expected[:BlockSize]) {
+				t.Fatal("Encrypt expected ", string(encodehex(expected[:BlockSize])), ",got ", string(encodehex(got[:BlockSize])))
+			}
+			bitsliced.Decrypt(got, expected)
+			if !bytes.Equal(got[:BlockSize], src[:BlockSize]) {
+				t.Fatal("Decrypt expected ", string(encodehex(src[:BlockSize])), ",got ", string(encodehex(got[:BlockSize])))
+			}
+		}
+	}
+}
+
+func TestBitslicedECB(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(6))
+	for i := 0; i < differentialRounds; i++ {
+		key := randomBytes(keySizes[rng.Intn(len(keySizes))])
+		bitsliced, err := NewBitslicedCipher(key)
+		check(err)
+		stdlib, err := aes.NewCipher(key)
+		check(err)
+		plaintext := randomBytes(BlockSize * rng.Intn(100))
+
+		expected, err := (&ECB{aes: stdlib, blockSize: BlockSize}).Encrypt(plaintext)
+		check(err)
+		ecb, err := NewECB(bitsliced)
+		check(err)
+		ecb.SetConcurrency(randomConcurrency(rng))
+		encrypted, err := ecb.Encrypt(plaintext)
+		check(err)
+		if !bytes.Equal(encrypted, expected) {
+			t.Fatal("Bitsliced ECB differs for ", len(plaintext), " bytes")
+		}
+		decrypted, err := ecb.Decrypt(encrypted)
+		check(err)
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Fatal("Bitsliced ECB decryption differs for ", len(plaintext), " bytes")
+		}
+	}
+}
+
+func TestBitslicedCTR(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(7))
+	for i := 0; i < differentialRounds; i++ {
+		key := randomBytes(keySizes[rng.Intn(len(keySizes))])
+		bitsliced, err := NewBitslicedCipher(key)
+		check(err)
+		stdlib, err := aes.NewCipher(key)
+		check(err)
+		// A counter about to carry into the higher bytes
+		inputVec := randomBytes(BlockSize)
+		for j := BlockSize - 2; j < BlockSize; j++ {
+			inputVec[j] = 0xff
+		}
+		counterBits := []int{CounterFull, Counter32, 64}[rng.Intn(3)]
+		plaintext := randomBytes(rng.Intn(3000))
+
+		ctr, err := NewCTR(bitsliced, inputVec, counterBits)
+		check(err)
+		if rng.Intn(2) == 0 {
+			ctr.SetConcurrency(randomConcurrency(rng))
+		}
+		reference, err := NewCTR(stdlib, inputVec, counterBits)
+		check(err)
+
+		// Unaligned calls leave part of a key stream block for the next one
+		encrypted := append([]byte(nil), plaintext...)
+		for rest := encrypted; len(rest) > 0; {
+			n := 1 + rng.Intn(len(rest))
+			ctr.XORKeyStream(rest[:n], rest[:n])
+			rest = rest[n:]
+		}
+		if !bytes.Equal(encrypted, reference.Encrypt(plaintext)) {
+			t.Fatal("Bitsliced CTR differs for ", len(plaintext), " bytes with a ", counterBits, "-bit counter")
+		}
+	}
+}
+
+func TestBitslicedCTRExhausted(t *testing.T) {
+	block, err := NewBitslicedCipher(randomBytes(16))
+	check(err)
+	ctr, err := NewCTR(block, make([]byte, BlockSize), 8)
+	check(err)
+	ctr.XORKeyStream(make([]byte, 255*BlockSize), make([]byte, 255*BlockSize))
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic once the 8-bit counter is exhausted")
+		}
+	}()
+	ctr.XORKeyStream(make([]byte, 2*BlockSize), make([]byte, 2*BlockSize))
+}
+
+func FuzzBitslicedCipher(f *testing.F) {
+	for _, test := range fips197tests {
+		f.Add(decodehex([]byte(test.key)), decodehex([]byte(fips197plaintext)))
+	}
+	f.Fuzz(func(t *testing.T, key, src []byte) {
+		stdlib, err := aes.NewCipher(key)
+		bitsliced, bitslicedErr := NewBitslicedCipher(key)
+		if (err == nil) != (bitslicedErr == nil) {
+			t.Fatal("Key size ", len(key), " accepted by only one implementation")
+		}
+		if err != nil {
+			return
+		}
+		src = src[:len(src)-len(src)%BlockSize]
+		expected := make([]byte, len(src))
+		for i := 0; i < len(src); i += BlockSize {
+			stdlib.Encrypt(expected[i:], src[i:])
+		}
+		got := make([]byte, len(src))
+		bitsliced.(multiBlock).EncryptBlocks(got, src)
+		if !bytes.Equal(got, expected) {
+			t.Fatalf("EncryptBlocks expected %x,got %x", expected, got)
+		}
+		bitsliced.(multiBlock).DecryptBlocks(got, got)
+		if !bytes.Equal(got, src) {
+			t.Fatalf("DecryptBlocks expected %x,got %x", src, got)
+		}
+	})
+}
+
+// Parameters of the timing test
+const (
+	timingSamples = 20000
+	// Measurements above this percentile are dominated by interrupts and
+	// scheduling and are dropped
+	timingCrop = 0.9
+	// |t| above 10 is taken as evidence of a timing leak, as in dudect
+	timingThreshold = 10
+)
+
+// timingSink keeps the compiler from removing the timed comparison
+var timingSink bool
+
+// TestBitslicedTiming is a dudect-style constant-time test, see Reparaz,
+// Balasch and Verbauwhede: "Dude, is my code constant time?"
+// It times the encryption of one fixed and of random plaintexts in random
+// order and fails if Welch's t-test tells the two classes apart
+// As a control, the same test must detect an early-returning comparison
+// Wall-clock timings are noisy on shared machines, so the test only runs
+// with GOCIPHERS_DUDECT=1
+func TestBitslicedTiming(t *testing.T) {
+	if os.Getenv("GOCIPHERS_DUDECT") != "1" {
+		t.Skip("Skipping the timing test, set GOCIPHERS_DUDECT=1 to run it")
+	}
+	rng := mrand.New(mrand.NewSource(8))
+
+	block, err := NewBitslicedCipher(randomBytes(16))
+	check(err)
+	out := make([]byte, bitsliceSize)
+	encrypt := func(in []byte) { block.(multiBlock).EncryptBlocks(out, in) }
+	if tStat := timingStatistic(rng, encrypt, make([]byte, bitsliceSize)); math.Abs(tStat) > timingThreshold {
+		t.Error("Bitsliced encryption time depends on the plaintext, t = ", tStat)
+	}
+
+	secret := randomBytes(1024)
+	compare := func(in []byte) { timingSink = bytes.Equal(in, secret) }
+	if tStat := timingStatistic(rng, compare, secret); math.Abs(tStat) <= timingThreshold {
+		t.Error("The timing test missed a variable-time comparison, t = ", tStat)
+	}
+}
+
+// timingStatistic times op on the fixed input and on random inputs of the
+// same length and returns Welch's t statistic of the two classes
+func timingStatistic(rng *mrand.Rand, op func(in []byte), fixed []byte) float64 {
+	// Every measurement has its own input, so that the fixed class does
+	// not gain from a warm cache
+	classes := make([]int, timingSamples)
+	inputs := make([][]byte, timingSamples)
+	for i := range inputs {
+		classes[i] = rng.Intn(2)
+		if classes[i] == 0 {
+			inputs[i] = append([]byte(nil), fixed...)
+		} else {
+			inputs[i] = make([]byte, len(fixed))
+			rng.Read(inputs[i])
+		}
+	}
+
+	times := make([]float64, timingSamples)
+	for i, in := range inputs {
+		start := time.Now()
+		op(in)
+		times[i] = float64(time.Since(start))
+	}
+
+	sorted := append([]float64(nil), times...)
+	sort.Float64s(sorted)
+	limit := sorted[int(timingCrop*float64(len(sorted)))]
+
+	var n, mean, m2 [2]float64
+	for i, x := range times {
+		if x > limit {
+			continue
+		}
+		// Welford's online mean and variance
+		c := classes[i]
+		n[c]++
+		delta := x - mean[c]
+		mean[c] += delta / n[c]
+		m2[c] += delta * (x - mean[c])
+	}
+	variance0, variance1 := m2[0]/(n[0]-1), m2[1]/(n[1]-1)
+	return (mean[0] - mean[1]) / math.Sqrt(variance0/n[0]+variance1/n[1])
+}
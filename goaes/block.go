@@ -0,0 +1,259 @@
+/*
+	block.go
+
+	Pure-Go implementation of the AES block cipher as specified in FIPS 197:
+	the key expansion and the SubBytes, ShiftRows, MixColumns and AddRoundKey
+	round transformations with their inverses.
+
+	The S-box lookups depend on secret data, so this reference implementation
+	is not safe against cache-timing attacks, see crypto/aes for production use.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	block.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/cipher"
+	"errors"
+)
+
+// BlockSize is the AES block size in bytes
+const BlockSize = 16
+
+// ErrKeySize is returned when the AES key is not 128, 192 or 256 bits long
+var ErrKeySize = errors.New("goaes: AES key must be 16, 24 or 32 bytes")
+
+var _ cipher.Block = (*aesCipher)(nil)
+
+// sbox and invSbox are computed by init from their definition in FIPS 197
+var sbox, invSbox [256]byte
+
+// aesCipher is the class for the AES block cipher
+type aesCipher struct {
+	rounds int
+	// The expanded key, 16 bytes per round and one more for the initial AddRoundKey
+	roundKeys []byte
+}
+
+// NewCipher is a constructor for the AES block cipher, the key selects
+// AES-128, AES-192 or AES-256 by its length
+// It is a drop-in replacement for crypto/aes.NewCipher
+func NewCipher(key []byte) (cipher.Block, error) {
+	if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+		return nil, ErrKeySize
+	}
+	c := &aesCipher{rounds: len(key)/4 + 6}
+	c.roundKeys = expandKey(key, c.rounds, subWord)
+	return c, nil
+}
+
+// BlockSize returns the AES block size
+func (c *aesCipher) BlockSize() int { return BlockSize }
+
+// Encrypt encrypts the first block of src into dst, dst and src may overlap entirely
+func (c *aesCipher) Encrypt(dst, src []byte) {
+	checkBlock(dst, src)
+	var state [BlockSize]byte
+	copy(state[:], src)
+
+	addRoundKey(&state, c.roundKeys[:BlockSize])
+	for round := 1; round < c.rounds; round++ {
+		subBytes(&state)
+		shiftRows(&state)
+		mixColumns(&state)
+		addRoundKey(&state, c.roundKeys[round*BlockSize:])
+	}
+	subBytes(&state)
+	shiftRows(&state)
+	addRoundKey(&state, c.roundKeys[c.rounds*BlockSize:])
+
+	copy(dst, state[:])
+}
+
+// Decrypt decrypts the first block of src into dst, dst and src may overlap entirely
+func (c *aesCipher) Decrypt(dst, src []byte) {
+	checkBlock(dst, src)
+	var state [BlockSize]byte
+	copy(state[:], src)
+
+	addRoundKey(&state, c.roundKeys[c.rounds*BlockSize:])
+	for round := c.rounds - 1; round > 0; round-- {
+		invShiftRows(&state)
+		invSubBytes(&state)
+		addRoundKey(&state, c.roundKeys[round*BlockSize:])
+		invMixColumns(&state)
+	}
+	invShiftRows(&state)
+	invSubBytes(&state)
+	addRoundKey(&state, c.roundKeys[:BlockSize])
+
+	copy(dst, state[:])
+}
+
+// checkBlock panics like crypto/aes on short or partially overlapping blocks
+func checkBlock(dst, src []byte) {
+	if len(src) < BlockSize {
+		panic("goaes: input not full block")
+	}
+	if len(dst) < BlockSize {
+		panic("goaes: output not full block")
+	}
+	if inexactOverlap(dst[:BlockSize], src[:BlockSize]) {
+		panic("goaes: invalid buffer overlap")
+	}
+}
+
+// expandKey returns the key schedule of FIPS 197 section 5.2 as bytes,
+// word i of the schedule is bytes 4i to 4i+3
+// subWord applies the S-box to the four bytes of a word
+func expandKey(key []byte, rounds int, subWord func(word *[4]byte)) []byte {
+	keyWords := len(key) / 4
+	w := make([]byte, BlockSize*(rounds+1))
+	copy(w, key)
+
+	rcon := byte(1)
+	for i := keyWords; i < len(w)/4; i++ {
+		var temp [4]byte
+		copy(temp[:], w[4*(i-1):])
+		if i%keyWords == 0 {
+			// RotWord, SubWord and the round constant
+			temp = [4]byte{temp[1], temp[2], temp[3], temp[0]}
+			subWord(&temp)
+			temp[0] ^= rcon
+			rcon = xtime(rcon)
+		} else if keyWords > 6 && i%keyWords == 4 {
+			subWord(&temp)
+		}
+		for j := 0; j < 4; j++ {
+			w[4*i+j] = w[4*(i-keyWords)+j] ^ temp[j]
+		}
+	}
+	return w
+}
+
+// subWord applies the S-box table to each byte of a key schedule word
+func subWord(word *[4]byte) {
+	for i, b := range word {
+		word[i] = sbox[b]
+	}
+}
+
+// The state is stored column by column as in the input block, the byte
+// in row r and column c is state[r+4c]
+
+func addRoundKey(state *[BlockSize]byte, roundKey []byte) {
+	for i := range state {
+		state[i] ^= roundKey[i]
+	}
+}
+
+func subBytes(state *[BlockSize]byte) {
+	for i, b := range state {
+		state[i] = sbox[b]
+	}
+}
+
+func invSubBytes(state *[BlockSize]byte) {
+	for i, b := range state {
+		state[i] = invSbox[b]
+	}
+}
+
+// shiftRows rotates row r to the left by r bytes
+func shiftRows(state *[BlockSize]byte) {
+	s := *state
+	for r := 1; r < 4; r++ {
+		for c := 0; c < 4; c++ {
+			state[r+4*c] = s[r+4*((c+r)%4)]
+		}
+	}
+}
+
+// invShiftRows rotates row r to the right by r bytes
+func invShiftRows(state *[BlockSize]byte) {
+	s := *state
+	for r := 1; r < 4; r++ {
+		for c := 0; c < 4; c++ {
+			state[r+4*((c+r)%4)] = s[r+4*c]
+		}
+	}
+}
+
+// mixColumns multiplies each column by the polynomial {03}x^3 + {01}x^2 +
+// {01}x + {02} modulo x^4 + 1
+func mixColumns(state *[BlockSize]byte) {
+	for c := 0; c < BlockSize; c += 4 {
+		a0, a1, a2, a3 := state[c], state[c+1], state[c+2], state[c+3]
+		state[c] = xtime(a0) ^ xtime(a1) ^ a1 ^ a2 ^ a3
+		state[c+1] = a0 ^ xtime(a1) ^ xtime(a2) ^ a2 ^ a3
+		state[c+2] = a0 ^ a1 ^ xtime(a2) ^ xtime(a3) ^ a3
+		state[c+3] = xtime(a0) ^ a0 ^ a1 ^ a2 ^ xtime(a3)
+	}
+}
+
+// invMixColumns multiplies each column by the inverse polynomial {0b}x^3 +
+// {0d}x^2 + {09}x + {0e}, which factors into {04}x^2 + {05} followed by
+// the MixColumns polynomial
+func invMixColumns(state *[BlockSize]byte) {
+	for c := 0; c < BlockSize; c += 4 {
+		u := xtime(xtime(state[c] ^ state[c+2]))
+		v := xtime(xtime(state[c+1] ^ state[c+3]))
+		state[c] ^= u
+		state[c+1] ^= v
+		state[c+2] ^= u
+		state[c+3] ^= v
+	}
+	mixColumns(state)
+}
+
+// xtime multiplies by x in GF(2^8) modulo x^8 + x^4 + x^3 + x + 1
+func xtime(b byte) byte {
+	return b<<1 ^ (b>>7)*0x1b
+}
+
+// gmul multiplies two elements of GF(2^8)
+func gmul(a, b byte) byte {
+	var p byte
+	for ; b != 0; b >>= 1 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		a = xtime(a)
+	}
+	return p
+}
+
+// init computes the S-box: the multiplicative inverse in GF(2^8), with 0
+// mapped to 0, followed by the affine transformation of FIPS 197 section 5.1.1
+func init() {
+	for i := 0; i < 256; i++ {
+		inv := byte(0)
+		if i != 0 {
+			// The multiplicative group has order 255, so b^254 = b^-1
+			inv = 1
+			for j := 0; j < 254; j++ {
+				inv = gmul(inv, byte(i))
+			}
+		}
+		s := inv ^ rotl8(inv, 1) ^ rotl8(inv, 2) ^ rotl8(inv, 3) ^ rotl8(inv, 4) ^ 0x63
+		sbox[i] = s
+		invSbox[s] = byte(i)
+	}
+}
+
+func rotl8(b byte, n uint) byte {
+	return b<<n | b>>(8-n)
+}
@@ -0,0 +1,184 @@
+/*
+	block_test.go
+
+	FIPS 197 Appendix A and C vectors for the pure-Go AES, every known answer
+	test of the package run again with it, and differential tests and fuzzing
+	against crypto/aes.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	block_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	mrand "math/rand"
+	"testing"
+)
+
+// FIPS 197 Appendix C example vectors
+var fips197tests = []struct {
+	key        string
+	ciphertext string
+}{
+	{"000102030405060708090a0b0c0d0e0f", "69c4e0d86a7b0430d8cdb78070b4c55a"},
+	{"000102030405060708090a0b0c0d0e0f1011121314151617", "dda97ca4864cdfe06eaf70a0ec0d7191"},
+	{"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f", "8ea2b7ca516745bfeafc49904b496089"},
+}
+
+const fips197plaintext = "00112233445566778899aabbccddeeff"
+
+func TestNativeFIPS197(t *testing.T) {
+	plaintext := decodehex([]byte(fips197plaintext))
+	for _, test := range fips197tests {
+		block, err := NewCipher(decodehex([]byte(test.key)))
+		check(err)
+		expected := decodehex([]byte(test.ciphertext))
+
+		encrypted := make([]byte, BlockSize)
+		block.Encrypt(encrypted, plaintext)
+		if !bytes.Equal(encrypted, expected) {
+			t.Error("Expected ", test.ciphertext, ",got ", string(encodehex(encrypted)))
+		}
+		block.Decrypt(encrypted, encrypted)
+		if !bytes.Equal(encrypted, plaintext) {
+			t.Error("Expected ", fips197plaintext, ",got ", string(encodehex(encrypted)))
+		}
+	}
+}
+
+func TestNativeKeyExpansion(t *testing.T) {
+	// The last word of each key schedule in FIPS 197 Appendix A
+	tests := []struct {
+		key  string
+		last string
+	}{
+		{"2b7e151628aed2a6abf7158809cf4f3c", "b6630ca6"},
+		{"8e73b0f7da0e6452c810f32b809079e562f8ead2522c6b7b", "01002202"},
+		{"603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4", "706c631e"},
+	}
+	for _, test := range tests {
+		key := decodehex([]byte(test.key))
+		schedule := expandKey(key, len(key)/4+6, subWord)
+		if last := string(encodehex(schedule[len(schedule)-4:])); last != test.last {
+			t.Error("Expected last word ", test.last, ",got ", last)
+		}
+	}
+	if sbox[0x53] != 0xed || invSbox[0xed] != 0x53 || sbox[0] != 0x63 {
+		t.Error("S-box does not match FIPS 197 Figure 7")
+	}
+}
+
+func TestNativeKeySize(t *testing.T) {
+	for keySize := 0; keySize <= 40; keySize++ {
+		_, err := NewCipher(make([]byte, keySize))
+		valid := keySize == 16 || keySize == 24 || keySize == 32
+		if valid != (err == nil) {
+			t.Error("Unexpected result for key size ", keySize, ": ", err)
+		}
+	}
+}
+
+// TestNativeKAT runs every known answer test of the package with NewCipher
+func TestNativeKAT(t *testing.T) {
+	runKATWith(t, NewCipher)
+}
+
+// runKATWith runs every known answer test of the package with the block
+// cipher created by newCipher
+func runKATWith(t *testing.T, newCipher func([]byte) (cipher.Block, error)) {
+	katCipher = newCipher
+	defer func() { katCipher = aes.NewCipher }()
+
+	tests := []struct {
+		name string
+		run  func(*testing.T)
+	}{
+		{"CBCGFSbox128", TestCBCGFSbox128}, {"CBCGFSbox192", TestCBCGFSbox192}, {"CBCGFSbox256", TestCBCGFSbox256},
+		{"CBCKeySbox128", TestCBCKeySbox128}, {"CBCKeySbox192", TestCBCKeySbox192}, {"CBCKeySbox256", TestCBCKeySbox256},
+		{"CBCVarKey128", TestCBCVarKey128}, {"CBCVarKey192", TestCBCVarKey192}, {"CBCVarKey256", TestCBCVarKey256},
+		{"CBCVarTxt128", TestCBCVarTxt128}, {"CBCVarTxt192", TestCBCVarTxt192}, {"CBCVarTxt256", TestCBCVarTxt256},
+		{"ECBGFSbox128", TestECBGFSbox128}, {"ECBGFSbox192", TestECBGFSbox192}, {"ECBGFSbox256", TestECBGFSbox256},
+		{"ECBKeySbox128", TestECBKeySbox128}, {"ECBKeySbox192", TestECBKeySbox192}, {"ECBKeySbox256", TestECBKeySbox256},
+		{"ECBVarKey128", TestECBVarKey128}, {"ECBVarKey192", TestECBVarKey192}, {"ECBVarKey256", TestECBVarKey256},
+		{"ECBVarTxt128", TestECBVarTxt128}, {"ECBVarTxt192", TestECBVarTxt192}, {"ECBVarTxt256", TestECBVarTxt256},
+		{"CFB1KAT", TestCFB1KAT}, {"CFB8KAT", TestCFB8KAT}, {"CFB128KAT", TestCFB128KAT}, {"OFBKAT", TestOFBKAT},
+		{"CCMCAVP", TestCCMCAVP}, {"XTSCAVP", TestXTSCAVP}, {"KeyWrapCAVP", TestKeyWrapCAVP}, {"CMACCAVP", TestCMACCAVP},
+		{"CFBVectors", TestCFBVectors}, {"CTRVectors", TestCTRVectors}, {"OFBVectors", TestOFBVectors},
+		{"GCMVectors", TestGCMVectors}, {"CCMVectors", TestCCMVectors}, {"XTSVectors", TestXTSVectors},
+		{"CBCCSVectors", TestCBCCSVectors}, {"CBCHMACVectors", TestCBCHMACVectors},
+		{"SIVVectors", TestSIVVectors}, {"GCMSIVVectors", TestGCMSIVVectors}, {"KeyWrapVectors", TestKeyWrapVectors},
+		{"CMACVectors", TestCMACVectors}, {"EAXVectors", TestEAXVectors}, {"OCBVectors", TestOCBVectors},
+		{"OCBIterated", TestOCBIterated},
+	}
+	for _, test := range tests {
+		t.Run(test.name, test.run)
+	}
+}
+
+func TestNativeDifferential(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(1))
+	for i := 0; i < differentialRounds; i++ {
+		key := randomBytes(keySizes[rng.Intn(len(keySizes))])
+		native, err := NewCipher(key)
+		check(err)
+		stdlib, err := aes.NewCipher(key)
+		check(err)
+
+		src := randomBytes(BlockSize)
+		expected := make([]byte, BlockSize)
+		got := make([]byte, BlockSize)
+		stdlib.Encrypt(expected, src)
+		native.Encrypt(got, src)
+		if !bytes.Equal(got, expected) {
+			t.Fatal("Encrypt expected ", string(encodehex(expected)), ",got ", string(encodehex(got)))
+		}
+		stdlib.Decrypt(expected, src)
+		native.Decrypt(got, src)
+		if !bytes.Equal(got, expected) {
+			t.Fatal("Decrypt expected ", string(encodehex(expected)), ",got ", string(encodehex(got)))
+		}
+	}
+}
+
+func FuzzNativeCipher(f *testing.F) {
+	for _, test := range fips197tests {
+		f.Add(decodehex([]byte(test.key)), decodehex([]byte(fips197plaintext)))
+	}
+	f.Fuzz(func(t *testing.T, key, src []byte) {
+		stdlib, err := aes.NewCipher(key)
+		native, nativeErr := NewCipher(key)
+		if (err == nil) != (nativeErr == nil) {
+			t.Fatal("Key size ", len(key), " accepted by only one implementation")
+		}
+		if err != nil || len(src) < BlockSize {
+			return
+		}
+		expected := make([]byte, BlockSize)
+		got := make([]byte, BlockSize)
+		stdlib.Encrypt(expected, src)
+		native.Encrypt(got, src)
+		if !bytes.Equal(got, expected) {
+			t.Fatalf("Encrypt expected %x,got %x", expected, got)
+		}
+		stdlib.Decrypt(expected, src)
+		native.Decrypt(got, src)
+		if !bytes.Equal(got, expected) {
+			t.Fatalf("Decrypt expected %x,got %x", expected, got)
+		}
+	})
+}
@@ -0,0 +1,245 @@
+/*
+	blockmode.go
+
+	ECB and CBC encrypters and decrypters implementing crypto/cipher.BlockMode.
+	They write into caller-provided buffers and support in-place operation.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	blockmode.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/cipher"
+	"unsafe"
+)
+
+var (
+	_ cipher.BlockMode = (*ECBEncrypter)(nil)
+	_ cipher.BlockMode = (*ECBDecrypter)(nil)
+	_ cipher.BlockMode = (*CBCEncrypter)(nil)
+	_ cipher.BlockMode = (*CBCDecrypter)(nil)
+)
+
+// ECBEncrypter is the cipher.BlockMode for ECB encryption
+type ECBEncrypter struct {
+	aes         cipher.Block
+	blockSize   int
+	concurrency Concurrency
+}
+
+// ECBDecrypter is the cipher.BlockMode for ECB decryption
+type ECBDecrypter struct {
+	aes         cipher.Block
+	blockSize   int
+	concurrency Concurrency
+}
+
+// CBCEncrypter is the cipher.BlockMode for CBC encryption
+type CBCEncrypter struct {
+	aes       cipher.Block
+	blockSize int
+	inputVec  []byte
+}
+
+// CBCDecrypter is the cipher.BlockMode for CBC decryption
+type CBCDecrypter struct {
+	aes         cipher.Block
+	blockSize   int
+	inputVec    []byte
+	concurrency Concurrency
+}
+
+// NewECBEncrypter is a constructor for the ECBEncrypter class
+func NewECBEncrypter(b cipher.Block) (*ECBEncrypter, error) {
+	if b == nil {
+		return nil, ErrNilBlock
+	}
+	return &ECBEncrypter{aes: b, blockSize: b.BlockSize()}, nil
+}
+
+// BlockSize returns the mode's block size
+func (x *ECBEncrypter) BlockSize() int { return x.blockSize }
+
+// CryptBlocks encrypts a number of blocks, dst and src may be the same slice
+func (x *ECBEncrypter) CryptBlocks(dst, src []byte) {
+	checkCryptBlocks(dst, src, x.blockSize)
+
+	x.concurrency.run(len(src), x.blockSize, func(start, end int) {
+		if mb, ok := x.aes.(multiBlock); ok {
+			mb.EncryptBlocks(dst[start:end], src[start:end])
+			return
+		}
+		for i := start; i < end; i += x.blockSize {
+			x.aes.Encrypt(dst[i:i+x.blockSize], src[i:i+x.blockSize])
+		}
+	})
+}
+
+// NewECBDecrypter is a constructor for the ECBDecrypter class
+func NewECBDecrypter(b cipher.Block) (*ECBDecrypter, error) {
+	if b == nil {
+		return nil, ErrNilBlock
+	}
+	return &ECBDecrypter{aes: b, blockSize: b.BlockSize()}, nil
+}
+
+// BlockSize returns the mode's block size
+func (x *ECBDecrypter) BlockSize() int { return x.blockSize }
+
+// CryptBlocks decrypts a number of blocks, dst and src may be the same slice
+func (x *ECBDecrypter) CryptBlocks(dst, src []byte) {
+	checkCryptBlocks(dst, src, x.blockSize)
+
+	x.concurrency.run(len(src), x.blockSize, func(start, end int) {
+		if mb, ok := x.aes.(multiBlock); ok {
+			mb.DecryptBlocks(dst[start:end], src[start:end])
+			return
+		}
+		for i := start; i < end; i += x.blockSize {
+			x.aes.Decrypt(dst[i:i+x.blockSize], src[i:i+x.blockSize])
+		}
+	})
+}
+
+// NewCBCEncrypter is a constructor for the CBCEncrypter class
+// The input vector is copied, the caller's slice is never modified
+func NewCBCEncrypter(b cipher.Block, inputVec []byte) (*CBCEncrypter, error) {
+	if b == nil {
+		return nil, ErrNilBlock
+	}
+	if len(inputVec) != b.BlockSize() {
+		return nil, ErrIVSize
+	}
+	return &CBCEncrypter{
+		aes:       b,
+		blockSize: b.BlockSize(),
+		inputVec:  append([]byte(nil), inputVec...),
+	}, nil
+}
+
+// BlockSize returns the mode's block size
+func (x *CBCEncrypter) BlockSize() int { return x.blockSize }
+
+// CryptBlocks encrypts a number of blocks, dst and src may be the same slice
+// The last ciphertext block becomes the input vector of the next call
+func (x *CBCEncrypter) CryptBlocks(dst, src []byte) {
+	checkCryptBlocks(dst, src, x.blockSize)
+
+	inputVec := x.inputVec
+	for i := 0; i < len(src); i += x.blockSize {
+		xor(dst[i:i+x.blockSize], src[i:i+x.blockSize], inputVec)
+		x.aes.Encrypt(dst[i:i+x.blockSize], dst[i:i+x.blockSize])
+		inputVec = dst[i : i+x.blockSize]
+	}
+
+	copy(x.inputVec, inputVec)
+}
+
+// NewCBCDecrypter is a constructor for the CBCDecrypter class
+// The input vector is copied, the caller's slice is never modified
+func NewCBCDecrypter(b cipher.Block, inputVec []byte) (*CBCDecrypter, error) {
+	if b == nil {
+		return nil, ErrNilBlock
+	}
+	if len(inputVec) != b.BlockSize() {
+		return nil, ErrIVSize
+	}
+	return &CBCDecrypter{
+		aes:       b,
+		blockSize: b.BlockSize(),
+		inputVec:  append([]byte(nil), inputVec...),
+	}, nil
+}
+
+// BlockSize returns the mode's block size
+func (x *CBCDecrypter) BlockSize() int { return x.blockSize }
+
+// CryptBlocks decrypts a number of blocks, dst and src may be the same slice
+// The last ciphertext block becomes the input vector of the next call
+func (x *CBCDecrypter) CryptBlocks(dst, src []byte) {
+	checkCryptBlocks(dst, src, x.blockSize)
+	if len(src) == 0 {
+		return
+	}
+
+	// Temporarily store the last `blockSize` bytes that will
+	// serve as input vector for further decryption afterwards
+	lastInputVec := make([]byte, x.blockSize)
+	copy(lastInputVec, src[len(src)-x.blockSize:])
+
+	if x.concurrency.parallel() {
+		// The ciphertext block in front of each chunk is saved first, since
+		// another worker may already have decrypted over it in-place
+		size := x.concurrency.chunkSize(x.blockSize)
+		inputVecs := make([]byte, 0, (len(src)+size-1)/size*x.blockSize)
+		inputVecs = append(inputVecs, x.inputVec...)
+		for start := size; start < len(src); start += size {
+			inputVecs = append(inputVecs, src[start-x.blockSize:start]...)
+		}
+		x.concurrency.run(len(src), x.blockSize, func(start, end int) {
+			inputVec := inputVecs[start/size*x.blockSize:]
+			x.decryptChunk(dst[start:end], src[start:end], inputVec[:x.blockSize])
+		})
+	} else {
+		x.decryptChunk(dst, src, x.inputVec)
+	}
+
+	copy(x.inputVec, lastInputVec)
+}
+
+// decryptChunk decrypts consecutive blocks that follow the input vector
+func (x *CBCDecrypter) decryptChunk(dst, src, inputVec []byte) {
+	// Walk the blocks backwards so that the previous ciphertext block
+	// is still intact when decrypting in-place
+	for i := len(src) - x.blockSize; i > 0; i -= x.blockSize {
+		x.aes.Decrypt(dst[i:i+x.blockSize], src[i:i+x.blockSize])
+		xor(dst[i:i+x.blockSize], dst[i:i+x.blockSize], src[i-x.blockSize:i])
+	}
+
+	x.aes.Decrypt(dst[:x.blockSize], src[:x.blockSize])
+	xor(dst[:x.blockSize], dst[:x.blockSize], inputVec)
+}
+
+// checkCryptBlocks panics on misuse of CryptBlocks, as documented by cipher.BlockMode
+func checkCryptBlocks(dst, src []byte, blockSize int) {
+	if len(src)%blockSize != 0 {
+		panic("goaes: input not full blocks")
+	}
+	if len(dst) < len(src) {
+		panic("goaes: output smaller than input")
+	}
+	if inexactOverlap(dst[:len(src)], src) {
+		panic("goaes: invalid buffer overlap")
+	}
+}
+
+// inexactOverlap reports whether x and y share memory at any non-corresponding
+// index, in which case in-place processing would corrupt the input
+func inexactOverlap(x, y []byte) bool {
+	if len(x) == 0 || len(y) == 0 || &x[0] == &y[0] {
+		return false
+	}
+	return uintptr(unsafe.Pointer(&x[0])) <= uintptr(unsafe.Pointer(&y[len(y)-1])) &&
+		uintptr(unsafe.Pointer(&y[0])) <= uintptr(unsafe.Pointer(&x[len(x)-1]))
+}
+
+// multiBlock is implemented by block ciphers that process several blocks
+// at once faster than one at a time, such as NewBitslicedCipher
+// dst and src hold whole blocks and may be the same slice
+type multiBlock interface {
+	EncryptBlocks(dst, src []byte)
+	DecryptBlocks(dst, src []byte)
+}
@@ -0,0 +1,206 @@
+/*
+	blockmode_test.go
+
+	Randomized differential tests of the cipher.BlockMode implementations
+	against crypto/cipher and the ECB/CBC classes.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	blockmode_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	mrand "math/rand"
+	"testing"
+)
+
+const differentialRounds = 200
+
+var keySizes = []int{16, 24, 32}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	check(err)
+	return b
+}
+
+func randomBlock(t *testing.T, rng *mrand.Rand) cipher.Block {
+	block, err := aes.NewCipher(randomBytes(keySizes[rng.Intn(len(keySizes))]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+func TestCBCEncrypterDifferential(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(1))
+	for i := 0; i < differentialRounds; i++ {
+		block := randomBlock(t, rng)
+		inputVec := randomBytes(aes.BlockSize)
+		plaintext := randomBytes(aes.BlockSize * rng.Intn(64))
+
+		expected := make([]byte, len(plaintext))
+		cipher.NewCBCEncrypter(block, inputVec).CryptBlocks(expected, plaintext)
+
+		enc, err := NewCBCEncrypter(block, inputVec)
+		check(err)
+		encrypted := make([]byte, len(plaintext))
+		// Split the input into two calls to exercise the carried input vector
+		split := aes.BlockSize * rng.Intn(len(plaintext)/aes.BlockSize+1)
+		enc.CryptBlocks(encrypted[:split], plaintext[:split])
+		enc.CryptBlocks(encrypted[split:], plaintext[split:])
+		if !bytes.Equal(encrypted, expected) {
+			t.Fatal("Expected ", string(encodehex(expected)),
+				",got ", string(encodehex(encrypted)))
+		}
+
+		enc, err = NewCBCEncrypter(block, inputVec)
+		check(err)
+		inplace := append([]byte(nil), plaintext...)
+		enc.CryptBlocks(inplace, inplace)
+		if !bytes.Equal(inplace, expected) {
+			t.Fatal("In-place expected ", string(encodehex(expected)),
+				",got ", string(encodehex(inplace)))
+		}
+	}
+}
+
+func TestCBCDecrypterDifferential(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(2))
+	for i := 0; i < differentialRounds; i++ {
+		block := randomBlock(t, rng)
+		inputVec := randomBytes(aes.BlockSize)
+		ciphertext := randomBytes(aes.BlockSize * rng.Intn(64))
+
+		expected := make([]byte, len(ciphertext))
+		cipher.NewCBCDecrypter(block, inputVec).CryptBlocks(expected, ciphertext)
+
+		dec, err := NewCBCDecrypter(block, inputVec)
+		check(err)
+		decrypted := make([]byte, len(ciphertext))
+		split := aes.BlockSize * rng.Intn(len(ciphertext)/aes.BlockSize+1)
+		dec.CryptBlocks(decrypted[:split], ciphertext[:split])
+		dec.CryptBlocks(decrypted[split:], ciphertext[split:])
+		if !bytes.Equal(decrypted, expected) {
+			t.Fatal("Expected ", string(encodehex(expected)),
+				",got ", string(encodehex(decrypted)))
+		}
+
+		dec, err = NewCBCDecrypter(block, inputVec)
+		check(err)
+		inplace := append([]byte(nil), ciphertext...)
+		dec.CryptBlocks(inplace, inplace)
+		if !bytes.Equal(inplace, expected) {
+			t.Fatal("In-place expected ", string(encodehex(expected)),
+				",got ", string(encodehex(inplace)))
+		}
+	}
+}
+
+func TestECBDifferential(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(3))
+	for i := 0; i < differentialRounds; i++ {
+		block := randomBlock(t, rng)
+		plaintext := randomBytes(aes.BlockSize * rng.Intn(64))
+
+		expected := make([]byte, len(plaintext))
+		for j := 0; j < len(plaintext); j += aes.BlockSize {
+			block.Encrypt(expected[j:], plaintext[j:])
+		}
+
+		enc, err := NewECBEncrypter(block)
+		check(err)
+		inplace := append([]byte(nil), plaintext...)
+		enc.CryptBlocks(inplace, inplace)
+		if !bytes.Equal(inplace, expected) {
+			t.Fatal("Expected ", string(encodehex(expected)),
+				",got ", string(encodehex(inplace)))
+		}
+
+		dec, err := NewECBDecrypter(block)
+		check(err)
+		dec.CryptBlocks(inplace, inplace)
+		if !bytes.Equal(inplace, plaintext) {
+			t.Fatal("Expected ", string(encodehex(plaintext)),
+				",got ", string(encodehex(inplace)))
+		}
+	}
+}
+
+func TestCBCClassMatchesBlockMode(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(4))
+	block := randomBlock(t, rng)
+	inputVec := randomBytes(aes.BlockSize)
+	plaintext := randomBytes(aes.BlockSize * 8)
+
+	cbc, err := NewCBC(block, inputVec)
+	check(err)
+	first, err := cbc.Encrypt(plaintext[:aes.BlockSize*3])
+	check(err)
+	second, err := cbc.Encrypt(plaintext[aes.BlockSize*3:])
+	check(err)
+
+	expected := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, inputVec).CryptBlocks(expected, plaintext)
+	if !bytes.Equal(append(first, second...), expected) {
+		t.Error("Expected ", string(encodehex(expected)),
+			",got ", string(encodehex(append(first, second...))))
+	}
+}
+
+func TestCryptBlocksPanics(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	check(err)
+	enc, err := NewCBCEncrypter(block, make([]byte, aes.BlockSize))
+	check(err)
+
+	buf := make([]byte, 4*aes.BlockSize)
+	cases := map[string]func(){
+		"not full blocks": func() { enc.CryptBlocks(buf, buf[:aes.BlockSize+1]) },
+		"short output":    func() { enc.CryptBlocks(buf[:aes.BlockSize], buf) },
+		"inexact overlap": func() { enc.CryptBlocks(buf[1:], buf[:2*aes.BlockSize]) },
+	}
+	for name, run := range cases {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected panic for ", name)
+				}
+			}()
+			run()
+		}()
+	}
+}
+
+func TestConstructorErrors(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	check(err)
+	if _, err := NewCBCEncrypter(block, make([]byte, 8)); err != ErrIVSize {
+		t.Error("Expected ErrIVSize, got ", err)
+	}
+	if _, err := NewCBCDecrypter(nil, make([]byte, 16)); err != ErrNilBlock {
+		t.Error("Expected ErrNilBlock, got ", err)
+	}
+	ecb, err := NewECB(block)
+	check(err)
+	if _, err := ecb.Decrypt(make([]byte, 17)); err == nil {
+		t.Error("Expected an error for input that does not fill the blocks")
+	}
+}
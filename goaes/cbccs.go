@@ -0,0 +1,156 @@
+/*
+	cbccs.go
+
+	Implementation of the ciphertext stealing variants of the Cipher Block
+	Chaining mode (CBC-CS1, CBC-CS2 and CBC-CS3), as specified in the
+	addendum to NIST SP 800-38A. The ciphertext is as long as the plaintext.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	cbccs.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/cipher"
+	"errors"
+)
+
+// Variants of ciphertext stealing, they differ in the order of the last two
+// ciphertext blocks
+const (
+	// CS1 keeps the order of CBC, the partial block comes second to last
+	CS1 = 1
+	// CS2 swaps the last two blocks only if the last block is partial
+	CS2 = 2
+	// CS3 always swaps the last two blocks, as Kerberos (RFC 3962) does
+	CS3 = 3
+)
+
+// ErrCSVariant is returned for an unknown ciphertext stealing variant
+var ErrCSVariant = errors.New("goaes: ciphertext stealing variant must be CS1, CS2 or CS3")
+
+// ErrCSTooShort is returned when the input is shorter than one block
+var ErrCSTooShort = errors.New("goaes: ciphertext stealing needs at least one full block")
+
+// CBCCS is the class for the CBC mode with ciphertext stealing
+type CBCCS struct {
+	aes       cipher.Block
+	blockSize int
+	variant   int
+	inputVec  []byte
+}
+
+// NewCBCCS is a constructor for the CBCCS class
+// The input vector is copied, the caller's slice is never modified
+// Unlike CBC, every message starts from this input vector, the chaining
+// state does not carry over between calls
+func NewCBCCS(b cipher.Block, inputVec []byte, variant int) (*CBCCS, error) {
+	if b == nil {
+		return nil, ErrNilBlock
+	}
+	if len(inputVec) != b.BlockSize() {
+		return nil, ErrIVSize
+	}
+	if variant != CS1 && variant != CS2 && variant != CS3 {
+		return nil, ErrCSVariant
+	}
+	return &CBCCS{
+		aes:       b,
+		blockSize: b.BlockSize(),
+		variant:   variant,
+		inputVec:  append([]byte(nil), inputVec...),
+	}, nil
+}
+
+// lastBlocks returns the number of blocks n, counting the partial block,
+// and the length d of the last block, 0 < d <= blockSize
+func (cs *CBCCS) lastBlocks(in []byte) (int, int) {
+	n := (len(in) + cs.blockSize - 1) / cs.blockSize
+	return n, len(in) - (n-1)*cs.blockSize
+}
+
+// swapped reports whether the last two ciphertext blocks are out of CBC order
+func (cs *CBCCS) swapped(d int) bool {
+	return cs.variant == CS3 || (cs.variant == CS2 && d != cs.blockSize)
+}
+
+// Encrypt is a CBCCS method for encryption
+func (cs *CBCCS) Encrypt(in []byte) ([]byte, error) {
+	if len(in) < cs.blockSize {
+		return nil, ErrCSTooShort
+	}
+	n, d := cs.lastBlocks(in)
+
+	// Encrypt the plaintext padded with zeros to full blocks
+	padded := make([]byte, n*cs.blockSize)
+	copy(padded, in)
+	enc := CBCEncrypter{aes: cs.aes, blockSize: cs.blockSize, inputVec: append([]byte(nil), cs.inputVec...)}
+	enc.CryptBlocks(padded, padded)
+	if n == 1 {
+		return padded, nil
+	}
+
+	// Only the leading d bytes of the second to last block are kept,
+	// the rest is recovered from the last block on decryption
+	prev := padded[(n-2)*cs.blockSize : (n-2)*cs.blockSize+d]
+	last := padded[(n-1)*cs.blockSize:]
+	out := make([]byte, 0, len(in))
+	out = append(out, padded[:(n-2)*cs.blockSize]...)
+	if cs.swapped(d) {
+		out = append(append(out, last...), prev...)
+	} else {
+		out = append(append(out, prev...), last...)
+	}
+
+	return out, nil
+}
+
+// Decrypt is a CBCCS method for decryption
+func (cs *CBCCS) Decrypt(in []byte) ([]byte, error) {
+	if len(in) < cs.blockSize {
+		return nil, ErrCSTooShort
+	}
+	n, d := cs.lastBlocks(in)
+
+	out := make([]byte, len(in))
+	dec := CBCDecrypter{aes: cs.aes, blockSize: cs.blockSize, inputVec: append([]byte(nil), cs.inputVec...)}
+	if n == 1 {
+		dec.CryptBlocks(out, in)
+		return out, nil
+	}
+
+	head := (n - 2) * cs.blockSize
+	var prev, last []byte
+	if cs.swapped(d) {
+		last, prev = in[head:head+cs.blockSize], in[head+cs.blockSize:]
+	} else {
+		prev, last = in[head:head+d], in[head+d:]
+	}
+
+	// Decrypting the last block gives the second to last ciphertext block
+	// XORed with the zero padded last plaintext block, so its trailing bytes
+	// complete the truncated second to last ciphertext block
+	z := make([]byte, cs.blockSize)
+	cs.aes.Decrypt(z, last)
+	full := make([]byte, head+cs.blockSize)
+	copy(full, in[:head])
+	copy(full[head:], prev)
+	copy(full[head+d:], z[d:])
+	xor(out[head+cs.blockSize:], z[:d], prev)
+
+	dec.CryptBlocks(out[:head+cs.blockSize], full)
+
+	return out, nil
+}
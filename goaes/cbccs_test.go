@@ -0,0 +1,208 @@
+/*
+	cbccs_test.go
+
+	Tests for the CBC ciphertext stealing variants.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	cbccs_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+)
+
+type cbccstest struct {
+	name       string
+	plaintext  string
+	ciphertext string
+}
+
+const (
+	// "chicken teriyaki", used with a zero input vector
+	cbccsKey = "636869636b656e207465726979616b69"
+	// Plaintext of the RFC 3962 vectors, each truncated to its length
+	cbccsPlaintext = "4920776f756c64206c696b65207468652047656e6572616c20476175277320" +
+		"436869636b656e2c20706c656173652c20616e6420776f6e746f6e20736f75702e"
+)
+
+// RFC 3962 Appendix B test vectors, Kerberos uses CBC-CS3
+var cbccstests = []cbccstest{
+	{
+		"17 bytes",
+		cbccsPlaintext[:34],
+		"c6353568f2bf8cb4d8a580362da7ff7f97",
+	},
+	{
+		"31 bytes",
+		cbccsPlaintext[:62],
+		"fc00783e0efdb2c1d445d4c8eff7ed2297687268d6ecccc0c07b25e25ecfe5",
+	},
+	{
+		"32 bytes",
+		cbccsPlaintext[:64],
+		"39312523a78662d5be7fcbcc98ebf5a897687268d6ecccc0c07b25e25ecfe584",
+	},
+	{
+		"47 bytes",
+		cbccsPlaintext[:94],
+		"97687268d6ecccc0c07b25e25ecfe584b3fffd940c16a18c1b5549d2f838029e" +
+			"39312523a78662d5be7fcbcc98ebf5",
+	},
+	{
+		"48 bytes",
+		cbccsPlaintext[:96],
+		"97687268d6ecccc0c07b25e25ecfe5849dad8bbb96c4cdc03bc103e1a194bbd8" +
+			"39312523a78662d5be7fcbcc98ebf5a8",
+	},
+	{
+		"64 bytes",
+		cbccsPlaintext,
+		"97687268d6ecccc0c07b25e25ecfe58439312523a78662d5be7fcbcc98ebf5a8" +
+			"4807efe836ee89a526730dbc2f7bc8409dad8bbb96c4cdc03bc103e1a194bbd8",
+	},
+}
+
+// cbccsReorder derives the CS1 or CS2 ciphertext from the CS3 ciphertext, the
+// addendum defines the variants as reorderings of the last two blocks
+func cbccsReorder(cs3 []byte, variant int) []byte {
+	n := (len(cs3) + aes.BlockSize - 1) / aes.BlockSize
+	d := len(cs3) - (n-1)*aes.BlockSize
+	if n == 1 || variant == CS3 || (variant == CS2 && d != aes.BlockSize) {
+		return cs3
+	}
+	head := (n - 2) * aes.BlockSize
+	out := append([]byte(nil), cs3[:head]...)
+	out = append(out, cs3[head+aes.BlockSize:]...)
+	return append(out, cs3[head:head+aes.BlockSize]...)
+}
+
+func TestCBCCSVectors(t *testing.T) {
+	block, err := katCipher(decodehex([]byte(cbccsKey)))
+	check(err)
+	for _, variant := range []int{CS1, CS2, CS3} {
+		cs, err := NewCBCCS(block, make([]byte, aes.BlockSize), variant)
+		check(err)
+		for _, test := range cbccstests {
+			plaintext := decodehex([]byte(test.plaintext))
+			expected := cbccsReorder(decodehex([]byte(test.ciphertext)), variant)
+
+			ciphertext, err := cs.Encrypt(plaintext)
+			if err != nil || !bytes.Equal(ciphertext, expected) {
+				t.Error("CS", variant, " ", test.name, " expected ", string(encodehex(expected)),
+					",got ", string(encodehex(ciphertext)), " error ", err)
+			}
+			decrypted, err := cs.Decrypt(expected)
+			if err != nil || !bytes.Equal(decrypted, plaintext) {
+				t.Error("CS", variant, " ", test.name, " expected ", test.plaintext,
+					",got ", string(encodehex(decrypted)), " error ", err)
+			}
+		}
+	}
+}
+
+func TestCBCCS1MatchesCBC(t *testing.T) {
+	// CS1 of full blocks is plain CBC, and otherwise its ciphertext is the
+	// CBC ciphertext of the zero padded plaintext with the second to last
+	// block truncated
+	key := randomBytes(16)
+	inputVec := randomBytes(aes.BlockSize)
+	block, err := aes.NewCipher(key)
+	check(err)
+	cs, err := NewCBCCS(block, inputVec, CS1)
+	check(err)
+	for n := aes.BlockSize; n <= 4*aes.BlockSize; n++ {
+		plaintext := randomBytes(n)
+		nb := (n + aes.BlockSize - 1) / aes.BlockSize
+		d := n - (nb-1)*aes.BlockSize
+		padded := make([]byte, nb*aes.BlockSize)
+		copy(padded, plaintext)
+		cipher.NewCBCEncrypter(block, inputVec).CryptBlocks(padded, padded)
+		expected := append(padded[:len(padded)-2*aes.BlockSize+d:len(padded)-2*aes.BlockSize+d],
+			padded[len(padded)-aes.BlockSize:]...)
+		if nb == 1 {
+			expected = padded
+		}
+
+		ciphertext, err := cs.Encrypt(plaintext)
+		if err != nil || !bytes.Equal(ciphertext, expected) {
+			t.Error("Length ", n, " expected ", string(encodehex(expected)),
+				",got ", string(encodehex(ciphertext)), " error ", err)
+		}
+	}
+}
+
+func TestCBCCSRoundTrip(t *testing.T) {
+	block, err := aes.NewCipher(randomBytes(32))
+	check(err)
+	for _, variant := range []int{CS1, CS2, CS3} {
+		cs, err := NewCBCCS(block, randomBytes(aes.BlockSize), variant)
+		check(err)
+		for n := aes.BlockSize; n <= 5*aes.BlockSize; n++ {
+			plaintext := randomBytes(n)
+			ciphertext, err := cs.Encrypt(plaintext)
+			check(err)
+			if len(ciphertext) != n {
+				t.Error("Expected ciphertext length ", n, ",got ", len(ciphertext))
+			}
+			decrypted, err := cs.Decrypt(ciphertext)
+			if err != nil || !bytes.Equal(decrypted, plaintext) {
+				t.Error("CS", variant, " expected ", string(encodehex(plaintext)),
+					",got ", string(encodehex(decrypted)), " error ", err)
+			}
+		}
+	}
+}
+
+func TestCBCCSParameters(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	check(err)
+	for variant := 0; variant <= 4; variant++ {
+		_, err := NewCBCCS(block, make([]byte, aes.BlockSize), variant)
+		valid := variant >= CS1 && variant <= CS3
+		if valid != (err == nil) {
+			t.Error("Unexpected result for variant ", variant, ": ", err)
+		}
+	}
+
+	cs, err := NewCBCCS(block, make([]byte, aes.BlockSize), CS3)
+	check(err)
+	if _, err := cs.Encrypt(make([]byte, aes.BlockSize-1)); err != ErrCSTooShort {
+		t.Error("Expected ", ErrCSTooShort, ",got ", err)
+	}
+	if _, err := cs.Decrypt(make([]byte, aes.BlockSize-1)); err != ErrCSTooShort {
+		t.Error("Expected ", ErrCSTooShort, ",got ", err)
+	}
+}
+
+func TestCBCCSShortInput(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	check(err)
+	for variant := CS1; variant <= CS3; variant++ {
+		cs, err := NewCBCCS(block, make([]byte, aes.BlockSize), variant)
+		check(err)
+		for length := 0; length < aes.BlockSize; length++ {
+			if _, err := cs.Encrypt(make([]byte, length)); err != ErrCSTooShort {
+				t.Error("CS", variant, " length ", length, ": expected ", ErrCSTooShort, ",got ", err)
+			}
+			if _, err := cs.Decrypt(make([]byte, length)); err != ErrCSTooShort {
+				t.Error("CS", variant, " length ", length, ": expected ", ErrCSTooShort, ",got ", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+/*
+	ccm.go
+
+	Implementation of the Counter with CBC-MAC (CCM) mode of operation, as
+	specified in NIST SP 800-38C, with configurable tag and nonce lengths.
+	The MAC reuses the CBC encrypter and the encryption the CTR mode.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	ccm.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+)
+
+const ccmBlockSize = 16
+
+var _ cipher.AEAD = (*CCM)(nil)
+
+// CCM is the class for the Counter with CBC-MAC mode of operation
+type CCM struct {
+	aes       cipher.Block
+	tagSize   int
+	nonceSize int
+	// Maximum plaintext length given the length field of 15 - nonceSize bytes
+	maxLength uint64
+}
+
+// NewCCM is a constructor for the CCM class
+// tagSize must be an even number of bytes between 4 and 16, nonceSize
+// between 7 and 13 bytes, longer nonces leave less room for the length
+func NewCCM(b cipher.Block, tagSize, nonceSize int) (*CCM, error) {
+	if b == nil {
+		return nil, ErrNilBlock
+	}
+	if b.BlockSize() != ccmBlockSize {
+		return nil, ErrBlockSize
+	}
+	if tagSize < 4 || tagSize > 16 || tagSize%2 != 0 {
+		return nil, ErrTagSize
+	}
+	if nonceSize < 7 || nonceSize > 13 {
+		return nil, ErrNonceSize
+	}
+
+	ccm := &CCM{
+		aes:       b,
+		tagSize:   tagSize,
+		nonceSize: nonceSize,
+		maxLength: 1<<63 - 1,
+	}
+	if lengthSize := 15 - nonceSize; lengthSize < 8 {
+		ccm.maxLength = 1<<uint(8*lengthSize) - 1
+	}
+	return ccm, nil
+}
+
+// NonceSize returns the size of the nonce that must be passed to Seal and Open
+func (c *CCM) NonceSize() int { return c.nonceSize }
+
+// Overhead returns the difference between the lengths of a plaintext and its ciphertext
+func (c *CCM) Overhead() int { return c.tagSize }
+
+// Seal encrypts and authenticates plaintext, authenticates additionalData
+// and appends the ciphertext and tag to dst
+func (c *CCM) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != c.nonceSize {
+		panic("goaes: incorrect nonce length given to CCM")
+	}
+	if uint64(len(plaintext)) > c.maxLength {
+		panic("goaes: message too large for CCM")
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext)+c.tagSize)
+	if inexactOverlap(out, plaintext) {
+		panic("goaes: invalid buffer overlap")
+	}
+
+	// The tag is computed over the plaintext, before it is overwritten
+	tag := c.mac(nonce, plaintext, additionalData)
+	c.counterCrypt(out, plaintext, tag, nonce)
+	copy(out[len(plaintext):], tag)
+
+	return ret
+}
+
+// Open decrypts and authenticates ciphertext, authenticates additionalData
+// and appends the plaintext to dst, which is wiped if the tag is invalid
+func (c *CCM) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != c.nonceSize {
+		panic("goaes: incorrect nonce length given to CCM")
+	}
+	if len(ciphertext) < c.tagSize || uint64(len(ciphertext)-c.tagSize) > c.maxLength {
+		return nil, ErrOpen
+	}
+
+	tag := append([]byte(nil), ciphertext[len(ciphertext)-c.tagSize:]...)
+	ciphertext = ciphertext[:len(ciphertext)-c.tagSize]
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	if inexactOverlap(out, ciphertext) {
+		panic("goaes: invalid buffer overlap")
+	}
+
+	// CCM authenticates the plaintext, so it has to be decrypted first
+	c.counterCrypt(out, ciphertext, tag, nonce)
+	expectedTag := c.mac(nonce, out, additionalData)
+
+	if subtle.ConstantTimeCompare(expectedTag, tag) != 1 {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, ErrOpen
+	}
+
+	return ret, nil
+}
+
+// counterCrypt encrypts src into dst with the counter blocks Ctr_1, Ctr_2, ...
+// and the tag in-place with Ctr_0
+func (c *CCM) counterCrypt(dst, src, tag, nonce []byte) {
+	lengthSize := 15 - c.nonceSize
+	counter := make([]byte, ccmBlockSize)
+	counter[0] = byte(lengthSize - 1)
+	copy(counter[1:], nonce)
+
+	ctr, err := NewCTR(c.aes, counter, 8*lengthSize)
+	if err != nil {
+		panic(err)
+	}
+	// Ctr_0 is used up as a whole block even for shorter tags
+	s0 := make([]byte, ccmBlockSize)
+	copy(s0, tag)
+	ctr.XORKeyStream(s0, s0)
+	copy(tag, s0)
+	ctr.XORKeyStream(dst, src)
+}
+
+// mac computes the CBC-MAC of the formatted nonce, additional data and plaintext
+func (c *CCM) mac(nonce, plaintext, additionalData []byte) []byte {
+	lengthSize := 15 - c.nonceSize
+
+	// B_0 = flags || nonce || [len(plaintext)]_lengthSize
+	b0 := make([]byte, ccmBlockSize)
+	b0[0] = byte((c.tagSize-2)/2)<<3 | byte(lengthSize-1)
+	if len(additionalData) > 0 {
+		b0[0] |= 1 << 6
+	}
+	copy(b0[1:], nonce)
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(plaintext)))
+	copy(b0[1+c.nonceSize:], length[8-lengthSize:])
+
+	cbc, err := NewCBCEncrypter(c.aes, make([]byte, ccmBlockSize))
+	if err != nil {
+		panic(err)
+	}
+	scratch := make([]byte, ccmBlockSize)
+	cbc.CryptBlocks(scratch, b0)
+
+	if len(additionalData) > 0 {
+		c.macPadded(cbc, append(encodeCCMLength(len(additionalData)), additionalData...))
+	}
+	c.macPadded(cbc, plaintext)
+
+	// The last CBC ciphertext block is kept as the input vector
+	return append([]byte(nil), cbc.inputVec[:c.tagSize]...)
+}
+
+// macPadded feeds data zero padded to the block size into the CBC-MAC
+func (c *CCM) macPadded(cbc *CBCEncrypter, data []byte) {
+	full := len(data) - len(data)%ccmBlockSize
+	scratch := make([]byte, ccmBlockSize)
+	for i := 0; i < full; i += ccmBlockSize {
+		cbc.CryptBlocks(scratch, data[i:i+ccmBlockSize])
+	}
+	if full < len(data) {
+		last := make([]byte, ccmBlockSize)
+		copy(last, data[full:])
+		cbc.CryptBlocks(scratch, last)
+	}
+}
+
+// encodeCCMLength encodes the length of the additional data, see A.2.2 of SP 800-38C
+func encodeCCMLength(n int) []byte {
+	switch {
+	case n < 1<<16-1<<8:
+		return []byte{byte(n >> 8), byte(n)}
+	case uint64(n) < 1<<32:
+		return []byte{0xff, 0xfe, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+	default:
+		enc := []byte{0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint64(enc[2:], uint64(n))
+		return enc
+	}
+}
@@ -0,0 +1,245 @@
+/*
+	ccm_test.go
+
+	NIST SP 800-38C, Appendix C CCM example vectors
+	See: https://csrc.nist.gov/publications/detail/sp/800-38c/final
+
+	CAVP CCM test vectors (VADT, VNT, VPT, VTT and DVPT files) downloaded from:
+	https://csrc.nist.gov/groups/STM/cavp/documents/mac/ccmtestvectors.zip
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	ccm_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type ccmtest struct {
+	name       string
+	tagSize    int
+	nonce      string
+	aad        string
+	plaintext  string
+	ciphertext string
+}
+
+const ccmKey = "404142434445464748494a4b4c4d4e4f"
+
+var ccmtests = []ccmtest{
+	{
+		"Example 1",
+		4,
+		"10111213141516",
+		"0001020304050607",
+		"20212223",
+		"7162015b4dac255d",
+	},
+	{
+		"Example 2",
+		6,
+		"1011121314151617",
+		"000102030405060708090a0b0c0d0e0f",
+		"202122232425262728292a2b2c2d2e2f",
+		"d2a1f0e051ea5f62081a7792073d593d1fc64fbfaccd",
+	},
+	{
+		"Example 3",
+		8,
+		"101112131415161718191a1b",
+		"000102030405060708090a0b0c0d0e0f10111213",
+		"202122232425262728292a2b2c2d2e2f3031323334353637",
+		"e3b201a9f5b71a7a9b1ceaeccd97e70b6176aad9a4428aa5484392fbc1b09951",
+	},
+}
+
+func TestCCMVectors(t *testing.T) {
+	block, err := katCipher(decodehex([]byte(ccmKey)))
+	check(err)
+	for _, test := range ccmtests {
+		nonce := decodehex([]byte(test.nonce))
+		aad := decodehex([]byte(test.aad))
+		plaintext := decodehex([]byte(test.plaintext))
+		expected := decodehex([]byte(test.ciphertext))
+
+		ccm, err := NewCCM(block, test.tagSize, len(nonce))
+		check(err)
+		sealed := ccm.Seal(nil, nonce, plaintext, aad)
+		if !bytes.Equal(sealed, expected) {
+			t.Error(test.name, " expected ", string(encodehex(expected)),
+				",got ", string(encodehex(sealed)))
+		}
+		opened, err := ccm.Open(nil, nonce, expected, aad)
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Error(test.name, " expected ", string(encodehex(plaintext)),
+				",got ", string(encodehex(opened)), " error ", err)
+		}
+	}
+}
+
+func TestCCMExample4(t *testing.T) {
+	// The additional data is 2^16 bytes long, which takes the 6-byte length encoding
+	aad := make([]byte, 1<<16)
+	for i := range aad {
+		aad[i] = byte(i)
+	}
+	block, err := aes.NewCipher(decodehex([]byte(ccmKey)))
+	check(err)
+	ccm, err := NewCCM(block, 14, 13)
+	check(err)
+	nonce := decodehex([]byte("101112131415161718191a1b1c"))
+	plaintext := decodehex([]byte("202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f"))
+	expected := decodehex([]byte("69915dad1e84c6376a68c2967e4dab615ae0fd1faec44cc484828529463ccf72" +
+		"b4ac6bec93e8598e7f0dadbcea5b"))
+
+	sealed := ccm.Seal(nil, nonce, plaintext, aad)
+	if !bytes.Equal(sealed, expected) {
+		t.Error("Expected ", string(encodehex(expected)),
+			",got ", string(encodehex(sealed)))
+	}
+}
+
+func TestCCMTampering(t *testing.T) {
+	block, err := aes.NewCipher(decodehex([]byte(ccmKey)))
+	check(err)
+	ccm, err := NewCCM(block, 16, 12)
+	check(err)
+	nonce := randomBytes(12)
+	plaintext := randomBytes(100)
+	aad := randomBytes(20)
+	sealed := ccm.Seal(nil, nonce, plaintext, aad)
+
+	for i := 0; i < len(sealed)*8; i++ {
+		tampered := append([]byte(nil), sealed...)
+		tampered[i/8] ^= 1 << uint(i%8)
+		if out, err := ccm.Open(nil, nonce, tampered, aad); err != ErrOpen || out != nil {
+			t.Fatal("Expected ErrOpen and no output after flipping bit ", i)
+		}
+	}
+
+	// The in-place buffer must not keep the unauthenticated plaintext
+	tampered := append([]byte(nil), sealed...)
+	tampered[0] ^= 1
+	ccm.Open(tampered[:0], nonce, tampered, aad)
+	if bytes.Contains(tampered, plaintext[1:]) {
+		t.Error("Unauthenticated plaintext left in the output buffer")
+	}
+}
+
+func TestCCMParameters(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	check(err)
+	for tagSize := 0; tagSize <= 18; tagSize++ {
+		_, err := NewCCM(block, tagSize, 12)
+		valid := tagSize >= 4 && tagSize <= 16 && tagSize%2 == 0
+		if valid != (err == nil) {
+			t.Error("Unexpected result for tag size ", tagSize, ": ", err)
+		}
+	}
+	for nonceSize := 5; nonceSize <= 15; nonceSize++ {
+		_, err := NewCCM(block, 16, nonceSize)
+		valid := nonceSize >= 7 && nonceSize <= 13
+		if valid != (err == nil) {
+			t.Error("Unexpected result for nonce size ", nonceSize, ": ", err)
+		}
+	}
+
+	// A 13-byte nonce leaves 2 bytes for the plaintext length
+	ccm, err := NewCCM(block, 16, 13)
+	check(err)
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for a plaintext longer than the length field allows")
+		}
+	}()
+	ccm.Seal(nil, make([]byte, 13), make([]byte, 1<<16), nil)
+}
+
+// cavpfile is a parsed CAVP file other than the confidentiality KAT
+type cavpfile struct {
+	Tests []map[string]string
+}
+
+// cavphex decodes a CAVP hex value truncated to the byte length given in
+// the test, since empty values are written as "00"
+// Failing tests omit the payload, which is decoded as empty
+func cavphex(test map[string]string, value, length string) []byte {
+	if _, found := test[value]; !found {
+		return nil
+	}
+	n, err := strconv.Atoi(test[length])
+	check(err)
+	return decodehex([]byte(test[value]))[:n]
+}
+
+func ccmTestrun(t *testing.T, tests []map[string]string) int {
+	numTests := 0
+	for _, test := range tests {
+		block, err := katCipher(decodehex([]byte(test["Key"])))
+		check(err)
+		tagSize, err := strconv.Atoi(test["Tlen"])
+		check(err)
+		nonce := cavphex(test, "Nonce", "Nlen")
+		aad := cavphex(test, "Adata", "Alen")
+		plaintext := cavphex(test, "Payload", "Plen")
+		ciphertext := decodehex([]byte(test["CT"]))
+
+		ccm, err := NewCCM(block, tagSize, len(nonce))
+		check(err)
+
+		result, verify := test["Result"]
+		if !verify {
+			sealed := ccm.Seal(nil, nonce, plaintext, aad)
+			if !bytes.Equal(sealed, ciphertext) {
+				t.Error("Count ", test["Count"], " expected ", test["CT"],
+					",got ", string(encodehex(sealed)))
+			}
+		}
+
+		opened, err := ccm.Open(nil, nonce, ciphertext, aad)
+		if verify && !strings.HasPrefix(result, "Pass") {
+			if err == nil {
+				t.Error("Count ", test["Count"], " expected authentication failure")
+			}
+		} else if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Error("Count ", test["Count"], " expected ", string(encodehex(plaintext)),
+				",got ", string(encodehex(opened)), " error ", err)
+		}
+		numTests++
+	}
+	return numTests
+}
+
+func TestCCMCAVP(t *testing.T) {
+	for _, kind := range []string{"VADT", "VNT", "VPT", "VTT", "DVPT"} {
+		for _, keySize := range []string{"128", "192", "256"} {
+			name := kind + keySize
+			t.Run(name, func(t *testing.T) {
+				var file cavpfile
+				testJSON := readtestfile(t, "jsontests/"+name+".json")
+				json.Unmarshal(testJSON, &file)
+				numTests := ccmTestrun(t, file.Tests)
+				fmt.Println("Num "+name+" tests: ", numTests)
+			})
+		}
+	}
+}
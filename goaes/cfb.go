@@ -0,0 +1,196 @@
+/*
+	cfb.go
+
+	Implementation of the Cipher Feedback (CFB) mode of operation with
+	1-bit, 8-bit and 128-bit segments, as specified in NIST SP 800-38A.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	cfb.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/cipher"
+	"errors"
+)
+
+const (
+	// CFB1 processes the input bit by bit, most significant bit first
+	CFB1 = 1
+	// CFB8 processes the input byte by byte
+	CFB8 = 8
+	// CFB128 processes the input a whole block at a time
+	CFB128 = 128
+)
+
+// ErrSegmentSize is returned when the CFB segment size is not supported
+var ErrSegmentSize = errors.New("goaes: CFB segment size must be 1 bit or a multiple of 8 bits up to the block size")
+
+var (
+	_ cipher.Stream = (*CFBEncrypter)(nil)
+	_ cipher.Stream = (*CFBDecrypter)(nil)
+)
+
+// CFB is the class for the Cipher Feedback mode of operation
+type CFB struct {
+	aes         cipher.Block
+	blockSize   int
+	segmentBits int
+	// The shift register holds the previous ciphertext segments
+	inputVec  []byte
+	keyStream []byte
+	// Ciphertext of the current segment and the number of its bytes processed
+	segment []byte
+	used    int
+}
+
+// CFBEncrypter is the cipher.Stream for CFB encryption
+type CFBEncrypter struct {
+	cfb *CFB
+}
+
+// CFBDecrypter is the cipher.Stream for CFB decryption
+type CFBDecrypter struct {
+	cfb *CFB
+}
+
+// NewCFB is a constructor for the CFB class
+// segmentBits is the number of bits processed per block cipher call,
+// i.e. CFB1, CFB8 or CFB128
+func NewCFB(b cipher.Block, inputVec []byte, segmentBits int) (*CFB, error) {
+	if b == nil {
+		return nil, ErrNilBlock
+	}
+	if len(inputVec) != b.BlockSize() {
+		return nil, ErrIVSize
+	}
+	if segmentBits != CFB1 && (segmentBits < 8 || segmentBits%8 != 0 || segmentBits > 8*b.BlockSize()) {
+		return nil, ErrSegmentSize
+	}
+
+	cfb := &CFB{
+		aes:         b,
+		blockSize:   b.BlockSize(),
+		segmentBits: segmentBits,
+		inputVec:    append([]byte(nil), inputVec...),
+		keyStream:   make([]byte, b.BlockSize()),
+	}
+	if segmentBits != CFB1 {
+		cfb.segment = make([]byte, segmentBits/8)
+	}
+	return cfb, nil
+}
+
+// NewCFBEncrypter is a constructor for the CFBEncrypter class
+func NewCFBEncrypter(b cipher.Block, inputVec []byte, segmentBits int) (*CFBEncrypter, error) {
+	cfb, err := NewCFB(b, inputVec, segmentBits)
+	if err != nil {
+		return nil, err
+	}
+	return &CFBEncrypter{cfb: cfb}, nil
+}
+
+// NewCFBDecrypter is a constructor for the CFBDecrypter class
+func NewCFBDecrypter(b cipher.Block, inputVec []byte, segmentBits int) (*CFBDecrypter, error) {
+	cfb, err := NewCFB(b, inputVec, segmentBits)
+	if err != nil {
+		return nil, err
+	}
+	return &CFBDecrypter{cfb: cfb}, nil
+}
+
+// XORKeyStream encrypts src into dst, dst and src may be the same slice
+func (x *CFBEncrypter) XORKeyStream(dst, src []byte) {
+	x.cfb.crypt(dst, src, false)
+}
+
+// XORKeyStream decrypts src into dst, dst and src may be the same slice
+func (x *CFBDecrypter) XORKeyStream(dst, src []byte) {
+	x.cfb.crypt(dst, src, true)
+}
+
+// Encrypt is a CFB method for encryption
+func (cfb *CFB) Encrypt(in []byte) []byte {
+	out := make([]byte, len(in))
+	cfb.crypt(out, in, false)
+	return out
+}
+
+// Decrypt is a CFB method for decryption
+func (cfb *CFB) Decrypt(in []byte) []byte {
+	out := make([]byte, len(in))
+	cfb.crypt(out, in, true)
+	return out
+}
+
+func (cfb *CFB) crypt(dst, src []byte, decrypt bool) {
+	if len(dst) < len(src) {
+		panic("goaes: output smaller than input")
+	}
+	if inexactOverlap(dst[:len(src)], src) {
+		panic("goaes: invalid buffer overlap")
+	}
+
+	if cfb.segmentBits == CFB1 {
+		cfb.cryptBits(dst, src, decrypt)
+		return
+	}
+
+	for i, b := range src {
+		// Every segment starts by encrypting the shift register
+		if cfb.used == 0 {
+			cfb.aes.Encrypt(cfb.keyStream, cfb.inputVec)
+		}
+		dst[i] = b ^ cfb.keyStream[cfb.used]
+
+		// The ciphertext is fed back, which is the input when decrypting
+		if decrypt {
+			cfb.segment[cfb.used] = b
+		} else {
+			cfb.segment[cfb.used] = dst[i]
+		}
+		cfb.used++
+
+		if cfb.used == len(cfb.segment) {
+			// Shift the register left by one segment
+			copy(cfb.inputVec, cfb.inputVec[len(cfb.segment):])
+			copy(cfb.inputVec[cfb.blockSize-len(cfb.segment):], cfb.segment)
+			cfb.used = 0
+		}
+	}
+}
+
+// cryptBits is CFB1, each bit requires its own block cipher call
+func (cfb *CFB) cryptBits(dst, src []byte, decrypt bool) {
+	for i, b := range src {
+		var out byte
+		for bit := 7; bit >= 0; bit-- {
+			cfb.aes.Encrypt(cfb.keyStream, cfb.inputVec)
+			in := (b >> uint(bit)) & 1
+			c := in ^ (cfb.keyStream[0] >> 7)
+			out |= c << uint(bit)
+
+			if decrypt {
+				c = in
+			}
+			// Shift the register left by one bit
+			for j := 0; j < cfb.blockSize-1; j++ {
+				cfb.inputVec[j] = cfb.inputVec[j]<<1 | cfb.inputVec[j+1]>>7
+			}
+			cfb.inputVec[cfb.blockSize-1] = cfb.inputVec[cfb.blockSize-1]<<1 | c
+		}
+		dst[i] = out
+	}
+}
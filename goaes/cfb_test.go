@@ -0,0 +1,158 @@
+/*
+	cfb_test.go
+
+	NIST SP 800-38A, Appendix F.3 CFB example vectors
+	See: https://csrc.nist.gov/publications/detail/sp/800-38a/final
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	cfb_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	mrand "math/rand"
+	"testing"
+)
+
+type cfbtest struct {
+	name        string
+	key         string
+	segmentBits int
+	plaintext   string
+	ciphertext  string
+}
+
+const sp80038aInputVec = "000102030405060708090a0b0c0d0e0f"
+
+var cfbtests = []cfbtest{
+	{
+		"F.3.1 CFB1-AES128",
+		"2b7e151628aed2a6abf7158809cf4f3c",
+		CFB1,
+		"6bc1",
+		"68b3",
+	},
+	{
+		"F.3.7 CFB8-AES128",
+		"2b7e151628aed2a6abf7158809cf4f3c",
+		CFB8,
+		"6bc1bee22e409f96e93d7e117393172aae2d",
+		"3b79424c9c0dd436bace9e0ed4586a4f32b9",
+	},
+	{
+		"F.3.11 CFB8-AES256",
+		"603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4",
+		CFB8,
+		"6bc1bee22e409f96e93d7e117393172aae2d",
+		"dc1f1a8520a64db55fcc8ac554844e889700",
+	},
+	{
+		"F.3.13 CFB128-AES128",
+		"2b7e151628aed2a6abf7158809cf4f3c",
+		CFB128,
+		sp80038aPlaintext,
+		"3b3fd92eb72dad20333449f8e83cfb4a" +
+			"c8a64537a0b3a93fcde3cdad9f1ce58b" +
+			"26751f67a3cbb140b1808cf187a4f4df" +
+			"c04b05357c5d1c0eeac4c66f9ff7f2e6",
+	},
+	{
+		"F.3.17 CFB128-AES256",
+		"603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4",
+		CFB128,
+		sp80038aPlaintext,
+		"dc7e84bfda79164b7ecd8486985d3860" +
+			"39ffed143b28b1c832113c6331e5407b" +
+			"df10132415e54b92a13ed0a8267ae2f9" +
+			"75a385741ab9cef82031623d55b1e471",
+	},
+}
+
+func TestCFBVectors(t *testing.T) {
+	for _, test := range cfbtests {
+		block, err := katCipher(decodehex([]byte(test.key)))
+		check(err)
+		inputVec := decodehex([]byte(sp80038aInputVec))
+		plaintext := decodehex([]byte(test.plaintext))
+		expected := decodehex([]byte(test.ciphertext))
+
+		cfb, err := NewCFB(block, inputVec, test.segmentBits)
+		check(err)
+		encrypted := cfb.Encrypt(plaintext)
+		if !bytes.Equal(encrypted, expected) {
+			t.Error(test.name, " expected ", string(encodehex(expected)),
+				",got ", string(encodehex(encrypted)))
+		}
+
+		cfb, err = NewCFB(block, inputVec, test.segmentBits)
+		check(err)
+		decrypted := cfb.Decrypt(expected)
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Error(test.name, " expected ", string(encodehex(plaintext)),
+				",got ", string(encodehex(decrypted)))
+		}
+	}
+}
+
+func TestCFB128Differential(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(6))
+	for i := 0; i < differentialRounds; i++ {
+		block := randomBlock(t, rng)
+		inputVec := randomBytes(aes.BlockSize)
+		plaintext := randomBytes(rng.Intn(1024))
+
+		expected := make([]byte, len(plaintext))
+		cipher.NewCFBEncrypter(block, inputVec).XORKeyStream(expected, plaintext)
+
+		enc, err := NewCFBEncrypter(block, inputVec, CFB128)
+		check(err)
+		// Uneven chunks exercise segments split across calls
+		encrypted := append([]byte(nil), plaintext...)
+		for rest := encrypted; len(rest) > 0; {
+			n := 1 + rng.Intn(len(rest))
+			enc.XORKeyStream(rest[:n], rest[:n])
+			rest = rest[n:]
+		}
+		if !bytes.Equal(encrypted, expected) {
+			t.Fatal("Expected ", string(encodehex(expected)),
+				",got ", string(encodehex(encrypted)))
+		}
+
+		dec, err := NewCFBDecrypter(block, inputVec, CFB128)
+		check(err)
+		for rest := encrypted; len(rest) > 0; {
+			n := 1 + rng.Intn(len(rest))
+			dec.XORKeyStream(rest[:n], rest[:n])
+			rest = rest[n:]
+		}
+		if !bytes.Equal(encrypted, plaintext) {
+			t.Fatal("Expected ", string(encodehex(plaintext)),
+				",got ", string(encodehex(encrypted)))
+		}
+	}
+}
+
+func TestCFBSegmentSize(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	check(err)
+	for _, bits := range []int{0, 2, 12, 136} {
+		if _, err := NewCFB(block, make([]byte, aes.BlockSize), bits); err != ErrSegmentSize {
+			t.Error("Expected ErrSegmentSize for ", bits, " bits, got ", err)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+/*
+	chunk.go
+
+	Online authenticated encryption of a stream with the STREAM construction
+	of Hoang, Reyhanitabar, Rogaway and Vizar, "Online Authenticated-Encryption
+	and its Nonce-Reuse Misuse-Resistance" (CRYPTO 2015).
+
+	The plaintext is split into chunks of a fixed size, each sealed with
+	an AEAD under the nonce prefix || chunk counter || last chunk flag.
+	Reordered, duplicated, dropped or spliced chunks fail to open, and
+	so does a truncated stream, whose last chunk lacks the flag.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	chunk.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// chunkNonceSuffix is the length of the chunk counter and the flag
+const chunkNonceSuffix = 5
+
+// ErrChunkSize is returned for a chunk size smaller than one byte
+var ErrChunkSize = errors.New("goaes: chunk size must be positive")
+
+// ErrPrefixSize is returned when the nonce prefix is not five bytes
+// shorter than the nonce of the AEAD
+var ErrPrefixSize = errors.New("goaes: nonce prefix must be the nonce size minus 5 bytes")
+
+// ErrChunkCount is returned when a stream exceeds 2^32 chunks
+var ErrChunkCount = errors.New("goaes: too many chunks for one nonce prefix")
+
+var (
+	_ io.WriteCloser = (*ChunkWriter)(nil)
+	_ io.Reader      = (*ChunkReader)(nil)
+)
+
+// chunkNonce is the nonce state shared by the writer and the reader
+type chunkNonce struct {
+	nonce   []byte
+	counter uint64
+}
+
+func newChunkNonce(aead cipher.AEAD, prefix []byte) (*chunkNonce, error) {
+	if len(prefix) != aead.NonceSize()-chunkNonceSuffix {
+		return nil, ErrPrefixSize
+	}
+	nonce := make([]byte, aead.NonceSize())
+	copy(nonce, prefix)
+	return &chunkNonce{nonce: nonce}, nil
+}
+
+// next returns the nonce of the next chunk
+func (sn *chunkNonce) next(last bool) ([]byte, error) {
+	if sn.counter > math.MaxUint32 {
+		return nil, ErrChunkCount
+	}
+	suffix := sn.nonce[len(sn.nonce)-chunkNonceSuffix:]
+	binary.BigEndian.PutUint32(suffix, uint32(sn.counter))
+	suffix[4] = 0
+	if last {
+		suffix[4] = 1
+	}
+	sn.counter++
+	return sn.nonce, nil
+}
+
+// ChunkWriter is the class for the sealing side of the STREAM construction
+// Close seals the last chunk, it does not close the underlying writer
+type ChunkWriter struct {
+	aead           cipher.AEAD
+	nonce          *chunkNonce
+	additionalData []byte
+	w              io.Writer
+	// Plaintext of the pending chunk, sealed once it is full and more
+	// plaintext follows, or by Close as the last chunk
+	buf []byte
+	out []byte
+	err error
+}
+
+// NewChunkWriter is a constructor for the ChunkWriter class
+// The prefix must be unique for every stream under the same key, it is
+// the nonce size of the AEAD minus 5 bytes long, e.g. 7 bytes for GCM
+// The additional data is authenticated with every chunk
+func NewChunkWriter(aead cipher.AEAD, prefix []byte, chunkSize int, additionalData []byte, w io.Writer) (*ChunkWriter, error) {
+	if chunkSize < 1 {
+		return nil, ErrChunkSize
+	}
+	nonce, err := newChunkNonce(aead, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return &ChunkWriter{
+		aead:           aead,
+		nonce:          nonce,
+		additionalData: append([]byte(nil), additionalData...),
+		w:              w,
+		buf:            make([]byte, 0, chunkSize),
+		out:            make([]byte, 0, chunkSize+aead.Overhead()),
+	}, nil
+}
+
+// Write buffers p and seals every chunk that is followed by more plaintext
+func (sw *ChunkWriter) Write(p []byte) (int, error) {
+	if sw.err != nil {
+		return 0, sw.err
+	}
+	n := 0
+	for len(p) > 0 {
+		if len(sw.buf) == cap(sw.buf) {
+			if err := sw.seal(false); err != nil {
+				return n, err
+			}
+		}
+		m := copy(sw.buf[len(sw.buf):cap(sw.buf)], p)
+		sw.buf = sw.buf[:len(sw.buf)+m]
+		p = p[m:]
+		n += m
+	}
+	return n, nil
+}
+
+// Close seals the buffered plaintext as the last chunk, which may be empty
+func (sw *ChunkWriter) Close() error {
+	if sw.err != nil {
+		if sw.err == ErrWriterClosed {
+			return nil
+		}
+		return sw.err
+	}
+	if err := sw.seal(true); err != nil {
+		return err
+	}
+	sw.err = ErrWriterClosed
+	return nil
+}
+
+func (sw *ChunkWriter) seal(last bool) error {
+	nonce, err := sw.nonce.next(last)
+	if err != nil {
+		sw.err = err
+		return err
+	}
+	sw.out = sw.aead.Seal(sw.out[:0], nonce, sw.buf, sw.additionalData)
+	sw.buf = sw.buf[:0]
+	if _, err := sw.w.Write(sw.out); err != nil {
+		sw.err = err
+		return err
+	}
+	return nil
+}
+
+// ChunkReader is the class for the opening side of the STREAM construction
+// Read only returns authenticated plaintext, and io.EOF only after the
+// last chunk was opened and no data follows it
+type ChunkReader struct {
+	aead           cipher.AEAD
+	nonce          *chunkNonce
+	additionalData []byte
+	r              io.Reader
+	// One ciphertext chunk and a byte of lookahead, which tells whether
+	// the chunk is the last
+	in        []byte
+	lookahead bool
+	// Opened plaintext not yet returned
+	plaintext []byte
+	out       []byte
+	err       error
+}
+
+// NewChunkReader is a constructor for the ChunkReader class, the
+// parameters must match those of the ChunkWriter
+func NewChunkReader(aead cipher.AEAD, prefix []byte, chunkSize int, additionalData []byte, r io.Reader) (*ChunkReader, error) {
+	if chunkSize < 1 {
+		return nil, ErrChunkSize
+	}
+	nonce, err := newChunkNonce(aead, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return &ChunkReader{
+		aead:           aead,
+		nonce:          nonce,
+		additionalData: append([]byte(nil), additionalData...),
+		r:              r,
+		in:             make([]byte, chunkSize+aead.Overhead()+1),
+		out:            make([]byte, 0, chunkSize),
+	}, nil
+}
+
+// Read returns the plaintext of the chunks opened so far
+// Any modification of the ciphertext, including its truncation, fails
+// with ErrOpen
+func (sr *ChunkReader) Read(p []byte) (int, error) {
+	for len(sr.plaintext) == 0 {
+		if sr.err != nil {
+			return 0, sr.err
+		}
+		sr.plaintext, sr.err = sr.open()
+	}
+	n := copy(p, sr.plaintext)
+	sr.plaintext = sr.plaintext[n:]
+	return n, nil
+}
+
+// open reads and opens the next chunk, it returns io.EOF along with
+// the plaintext of the last chunk
+func (sr *ChunkReader) open() ([]byte, error) {
+	// The lookahead byte of the previous call starts this chunk
+	start := 0
+	if sr.lookahead {
+		start = 1
+	}
+	n, err := io.ReadFull(sr.r, sr.in[start:])
+	n += start
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+
+	// A chunk is the last one if no byte follows it
+	last := n < len(sr.in)
+	chunk := sr.in[:n]
+	if !last {
+		chunk = sr.in[:n-1]
+	}
+	nonce, err := sr.nonce.next(last)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := sr.aead.Open(sr.out[:0], nonce, chunk, sr.additionalData)
+	if err != nil {
+		return nil, ErrOpen
+	}
+	if last {
+		return plaintext, io.EOF
+	}
+	sr.in[0] = sr.in[n-1]
+	sr.lookahead = true
+	return plaintext, nil
+}
@@ -0,0 +1,176 @@
+/*
+	chunk_test.go
+
+	The chunked stream must round trip for every length and reject each way
+	of tampering with the ciphertext: bit flips, truncation, reordering,
+	duplication, dropping, splicing and appending.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	chunk_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"io"
+	mrand "math/rand"
+	"testing"
+)
+
+const testChunkSize = 64
+
+func newTestGCM(t *testing.T) cipher.AEAD {
+	block, err := aes.NewCipher(randomBytes(16))
+	check(err)
+	aead, err := NewGCM(block)
+	check(err)
+	return aead
+}
+
+// sealChunks returns the sealed stream of the plaintext
+func sealChunks(t *testing.T, aead cipher.AEAD, prefix, plaintext, aad []byte) []byte {
+	var sealed bytes.Buffer
+	w, err := NewChunkWriter(aead, prefix, testChunkSize, aad, &sealed)
+	check(err)
+	check(writeChunks(t, mrand.New(mrand.NewSource(int64(len(plaintext)))), w, plaintext))
+	return sealed.Bytes()
+}
+
+// openChunks opens the sealed stream, reading it in small pieces
+func openChunks(aead cipher.AEAD, prefix, sealed, aad []byte) ([]byte, error) {
+	r, err := NewChunkReader(aead, prefix, testChunkSize, aad, bytes.NewReader(sealed))
+	check(err)
+	var opened bytes.Buffer
+	_, err = io.CopyBuffer(&opened, r, make([]byte, 7))
+	return opened.Bytes(), err
+}
+
+// splitChunks splits the sealed stream into its chunks
+func splitChunks(aead cipher.AEAD, sealed []byte) [][]byte {
+	var chunks [][]byte
+	size := testChunkSize + aead.Overhead()
+	for len(sealed) > size {
+		chunks = append(chunks, sealed[:size])
+		sealed = sealed[size:]
+	}
+	return append(chunks, sealed)
+}
+
+func TestChunkRoundTrip(t *testing.T) {
+	aead := newTestGCM(t)
+	prefix := randomBytes(7)
+	aad := []byte("header")
+	for _, length := range []int{0, 1, testChunkSize - 1, testChunkSize, testChunkSize + 1,
+		3 * testChunkSize, 3*testChunkSize + 5, 100000} {
+		plaintext := randomBytes(length)
+		sealed := sealChunks(t, aead, prefix, plaintext, aad)
+
+		chunks := (length + testChunkSize - 1) / testChunkSize
+		if chunks == 0 {
+			chunks = 1
+		}
+		if len(sealed) != length+chunks*aead.Overhead() {
+			t.Fatal("Length ", length, " expected ", chunks, " chunks,got ", len(sealed), " bytes")
+		}
+		opened, err := openChunks(aead, prefix, sealed, aad)
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Fatal("Length ", length, " did not round trip: ", err)
+		}
+	}
+}
+
+func TestChunkNonces(t *testing.T) {
+	aead := newTestGCM(t)
+	prefix := randomBytes(7)
+	plaintext := randomBytes(testChunkSize + 1)
+	chunks := splitChunks(aead, sealChunks(t, aead, prefix, plaintext, nil))
+
+	// prefix || 32-bit big-endian counter || last chunk flag
+	first := aead.Seal(nil, append(append([]byte(nil), prefix...), 0, 0, 0, 0, 0), plaintext[:testChunkSize], nil)
+	last := aead.Seal(nil, append(append([]byte(nil), prefix...), 0, 0, 0, 1, 1), plaintext[testChunkSize:], nil)
+	if !bytes.Equal(chunks[0], first) || !bytes.Equal(chunks[1], last) {
+		t.Error("Chunks are not sealed under prefix || counter || flag")
+	}
+}
+
+func TestChunkTampering(t *testing.T) {
+	aead := newTestGCM(t)
+	prefix := randomBytes(7)
+	aad := []byte("header")
+	plaintext := randomBytes(4*testChunkSize + 10)
+	sealed := sealChunks(t, aead, prefix, plaintext, aad)
+	chunks := splitChunks(aead, sealed)
+	other := splitChunks(aead, sealChunks(t, aead, randomBytes(7), plaintext, aad))
+	join := func(chunks ...[]byte) []byte { return bytes.Join(chunks, nil) }
+
+	tampered := map[string][]byte{
+		"empty":               nil,
+		"truncated mid-chunk": sealed[:len(sealed)-1],
+		"truncated at chunk":  join(chunks[:3]...),
+		"truncated last":      join(chunks[:4]...),
+		"reordered":           join(chunks[1], chunks[0], chunks[2], chunks[3], chunks[4]),
+		"duplicated":          join(chunks[0], chunks[0], chunks[1], chunks[2], chunks[3], chunks[4]),
+		"dropped":             join(chunks[0], chunks[2], chunks[3], chunks[4]),
+		"spliced":             join(chunks[0], other[1], chunks[2], chunks[3], chunks[4]),
+		"spliced last":        join(chunks[0], chunks[1], chunks[2], chunks[3], other[4]),
+		"appended":            join(sealed, []byte{0}),
+		"appended chunk":      join(sealed, chunks[4]),
+	}
+	for name, data := range tampered {
+		if _, err := openChunks(aead, prefix, data, aad); err != ErrOpen {
+			t.Error(name, ": expected ErrOpen,got ", err)
+		}
+	}
+
+	for i := 0; i < len(sealed)*8; i += 7 {
+		flipped := append([]byte(nil), sealed...)
+		flipped[i/8] ^= 1 << uint(i%8)
+		if _, err := openChunks(aead, prefix, flipped, aad); err != ErrOpen {
+			t.Fatal("Expected ErrOpen after flipping bit ", i, ",got ", err)
+		}
+	}
+	if _, err := openChunks(aead, prefix, sealed, []byte("other")); err != ErrOpen {
+		t.Error("Expected ErrOpen for other additional data,got ", err)
+	}
+	if _, err := openChunks(aead, randomBytes(7), sealed, aad); err != ErrOpen {
+		t.Error("Expected ErrOpen for another prefix,got ", err)
+	}
+
+	// Only authenticated chunks are released before the failure
+	opened, err := openChunks(aead, prefix, join(chunks[0], chunks[1], other[2]), aad)
+	if err != ErrOpen || !bytes.Equal(opened, plaintext[:2*testChunkSize]) {
+		t.Error("Expected the first two chunks before ErrOpen,got ", len(opened), " bytes and ", err)
+	}
+}
+
+func TestChunkParameters(t *testing.T) {
+	aead := newTestGCM(t)
+	if _, err := NewChunkWriter(aead, randomBytes(8), testChunkSize, nil, io.Discard); err != ErrPrefixSize {
+		t.Error("Expected ErrPrefixSize,got ", err)
+	}
+	if _, err := NewChunkReader(aead, randomBytes(7), 0, nil, nil); err != ErrChunkSize {
+		t.Error("Expected ErrChunkSize,got ", err)
+	}
+
+	w, err := NewChunkWriter(aead, randomBytes(7), 1, nil, io.Discard)
+	check(err)
+	w.nonce.counter = 1 << 32
+	w.Write([]byte{1, 2})
+	if err := w.Close(); err != ErrChunkCount {
+		t.Error("Expected ErrChunkCount,got ", err)
+	}
+}
@@ -0,0 +1,196 @@
+/*
+	cmac.go
+
+	Implementation of the CMAC message authentication code as specified in
+	NIST SP 800-38B and RFC 4493, and of the raw CBC-MAC it is built on.
+	Both chain the message blocks with CBC encryption under a zero input
+	vector, CMAC masks the last block with a subkey derived from the key,
+	which makes it secure for messages of any length.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	cmac.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/cipher"
+	"hash"
+)
+
+// cmacSize is the block and the tag size of CMAC and CBC-MAC in bytes
+const cmacSize = 16
+
+var (
+	_ hash.Hash = (*CMAC)(nil)
+	_ hash.Hash = (*CBCMAC)(nil)
+)
+
+// cbcMAC is the state shared by CMAC and CBC-MAC, the last ciphertext
+// block of CBC encryption and the pending last block of the message
+// All fields are arrays, so that a copy of the state is independent
+type cbcMAC struct {
+	aes cipher.Block
+	x   [cmacSize]byte
+	buf [cmacSize]byte
+	n   int
+}
+
+// Write absorbs more of the message, it never returns an error
+func (m *cbcMAC) Write(p []byte) (int, error) {
+	// The chaining value is the input vector of CBC, which CryptBlocks
+	// updates in place
+	cbc := CBCEncrypter{aes: m.aes, blockSize: cmacSize, inputVec: m.x[:]}
+	written := len(p)
+	for len(p) > 0 {
+		// A full block is only chained once more data follows, the last
+		// block is finished by Sum
+		if m.n == cmacSize {
+			cbc.CryptBlocks(m.buf[:], m.buf[:])
+			m.n = 0
+		}
+		n := copy(m.buf[m.n:], p)
+		m.n += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Reset starts a new message
+func (m *cbcMAC) Reset() {
+	m.x = [cmacSize]byte{}
+	m.buf = [cmacSize]byte{}
+	m.n = 0
+}
+
+// Size returns the tag size
+func (m *cbcMAC) Size() int { return cmacSize }
+
+// BlockSize returns the block size of the underlying cipher
+func (m *cbcMAC) BlockSize() int { return cmacSize }
+
+// finish appends the encryption of the chaining value XORed with the
+// prepared last block to b
+func (m *cbcMAC) finish(b []byte, last [cmacSize]byte) []byte {
+	xor(last[:], last[:], m.x[:])
+	m.aes.Encrypt(last[:], last[:])
+	return append(b, last[:]...)
+}
+
+func newCBCMAC(b cipher.Block) (cbcMAC, error) {
+	if b == nil {
+		return cbcMAC{}, ErrNilBlock
+	}
+	if b.BlockSize() != cmacSize {
+		return cbcMAC{}, ErrBlockSize
+	}
+	return cbcMAC{aes: b}, nil
+}
+
+// CMAC is the class for the CMAC message authentication code, it
+// implements hash.Hash
+type CMAC struct {
+	cbcMAC
+	// Subkeys for a complete and for a padded last block
+	k1, k2 [cmacSize]byte
+}
+
+// NewCMAC is a constructor for the CMAC class, b is usually the AES cipher
+// of the key
+func NewCMAC(b cipher.Block) (*CMAC, error) {
+	state, err := newCBCMAC(b)
+	if err != nil {
+		return nil, err
+	}
+	c := &CMAC{cbcMAC: state}
+	c.k1, c.k2 = cmacSubkeys(b)
+	return c, nil
+}
+
+// Sum appends the tag of the message written so far to b, it does not
+// change the state
+// The last block is XORed with K1 if it is complete, otherwise it is
+// padded with 0x80 and zeros and XORed with K2
+func (c *CMAC) Sum(b []byte) []byte {
+	last := c.buf
+	if c.n == cmacSize {
+		xor(last[:], last[:], c.k1[:])
+	} else {
+		for i := c.n; i < cmacSize; i++ {
+			last[i] = 0
+		}
+		last[c.n] = 0x80
+		xor(last[:], last[:], c.k2[:])
+	}
+	return c.finish(b, last)
+}
+
+// sum returns the CMAC of msg, it only reads c and may be called
+// concurrently
+func (c *CMAC) sum(msg []byte) (tag [cmacSize]byte) {
+	m := *c
+	m.Reset()
+	m.Write(msg)
+	m.Sum(tag[:0])
+	return
+}
+
+// cmacSubkeys derives K1 and K2 of SP 800-38B section 6.1 by doubling the
+// encryption of the zero block
+func cmacSubkeys(b cipher.Block) (k1, k2 [cmacSize]byte) {
+	b.Encrypt(k1[:], k1[:])
+	dbl(&k1)
+	k2 = k1
+	dbl(&k2)
+	return
+}
+
+// dbl multiplies by x in GF(2^128) in the big-endian convention of CMAC,
+// reducing by x^128 + x^7 + x^2 + x + 1, in constant time
+func dbl(d *[cmacSize]byte) {
+	carry := d[0] >> 7
+	for i := 0; i < cmacSize-1; i++ {
+		d[i] = d[i]<<1 | d[i+1]>>7
+	}
+	d[cmacSize-1] = d[cmacSize-1]<<1 ^ 0x87&-carry
+}
+
+// CBCMAC is the class for the raw CBC-MAC, the last block of the CBC
+// encryption of the message under a zero input vector, it implements
+// hash.Hash
+// A partial last block is padded with zeros. CBC-MAC is only secure for
+// messages of one fixed length, a tag of one message lets an attacker
+// forge the tags of its extensions, use CMAC otherwise
+type CBCMAC struct {
+	cbcMAC
+}
+
+// NewCBCMAC is a constructor for the CBCMAC class
+func NewCBCMAC(b cipher.Block) (*CBCMAC, error) {
+	state, err := newCBCMAC(b)
+	if err != nil {
+		return nil, err
+	}
+	return &CBCMAC{cbcMAC: state}, nil
+}
+
+// Sum appends the tag of the message written so far to b, it does not
+// change the state
+func (m *CBCMAC) Sum(b []byte) []byte {
+	last := m.buf
+	for i := m.n; i < cmacSize; i++ {
+		last[i] = 0
+	}
+	return m.finish(b, last)
+}
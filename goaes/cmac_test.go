@@ -0,0 +1,203 @@
+/*
+	cmac_test.go
+
+	CMAC test vectors from RFC 4493 section 4 and NIST SP 800-38B Appendix D,
+	the NIST CAVP CMAC tests and a differential test of CBC-MAC against
+	crypto/cipher
+	See: https://tools.ietf.org/html/rfc4493
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	cmac_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/json"
+	"fmt"
+	mrand "math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// cmacMessage is the message of the examples, the first 64 bytes of the
+// SP 800-38A example plaintext truncated to 0, 16, 40 and 64 bytes
+const cmacMessage = "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e51" +
+	"30c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710"
+
+var cmactests = []struct {
+	name string
+	key  string
+	// Message length in bytes
+	length int
+	tag    string
+}{
+	{"Example 1 AES-128", "2b7e151628aed2a6abf7158809cf4f3c", 0, "bb1d6929e95937287fa37d129b756746"},
+	{"Example 2 AES-128", "2b7e151628aed2a6abf7158809cf4f3c", 16, "070a16b46b4d4144f79bdd9dd04a287c"},
+	{"Example 3 AES-128", "2b7e151628aed2a6abf7158809cf4f3c", 40, "dfa66747de9ae63030ca32611497c827"},
+	{"Example 4 AES-128", "2b7e151628aed2a6abf7158809cf4f3c", 64, "51f0bebf7e3b9d92fc49741779363cfe"},
+	{"Example 5 AES-192", "8e73b0f7da0e6452c810f32b809079e562f8ead2522c6b7b", 0, "d17ddf46adaacde531cac483de7a9367"},
+	{"Example 6 AES-192", "8e73b0f7da0e6452c810f32b809079e562f8ead2522c6b7b", 16, "9e99a7bf31e710900662f65e617c5184"},
+	{"Example 7 AES-192", "8e73b0f7da0e6452c810f32b809079e562f8ead2522c6b7b", 40, "8a1de5be2eb31aad089a82e6ee908b0e"},
+	{"Example 8 AES-192", "8e73b0f7da0e6452c810f32b809079e562f8ead2522c6b7b", 64, "a1d5df0eed790f794d77589659f39a11"},
+	{"Example 9 AES-256", "603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4", 0, "028962f61b7bf89efc6b551f4667d983"},
+	{"Example 10 AES-256", "603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4", 16, "28a7023f452e8f82bd4bf28d8c37c35c"},
+	{"Example 11 AES-256", "603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4", 40, "aaf3d8f1de5640c232f5b169b9c911e6"},
+	{"Example 12 AES-256", "603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4", 64, "e1992190549f6ed5696a2c056c315410"},
+}
+
+func TestCMACVectors(t *testing.T) {
+	message := decodehex([]byte(cmacMessage))
+	for _, test := range cmactests {
+		block, err := katCipher(decodehex([]byte(test.key)))
+		check(err)
+		mac, err := NewCMAC(block)
+		check(err)
+		mac.Write(message[:test.length])
+		if tag := mac.Sum(nil); !bytes.Equal(tag, decodehex([]byte(test.tag))) {
+			t.Error(test.name, " expected ", test.tag, ",got ", string(encodehex(tag)))
+		}
+	}
+}
+
+// TestCMACSubkeys checks the subkey generation of RFC 4493 section 4
+func TestCMACSubkeys(t *testing.T) {
+	block, err := aes.NewCipher(decodehex([]byte("2b7e151628aed2a6abf7158809cf4f3c")))
+	check(err)
+	k1, k2 := cmacSubkeys(block)
+	if expected := "fbeed618357133667c85e08f7236a8de"; string(encodehex(k1[:])) != expected {
+		t.Error("Expected K1 ", expected, ",got ", string(encodehex(k1[:])))
+	}
+	if expected := "f7ddac306ae266ccf90bc11ee46d513b"; string(encodehex(k2[:])) != expected {
+		t.Error("Expected K2 ", expected, ",got ", string(encodehex(k2[:])))
+	}
+}
+
+// TestCMACStreaming writes messages in random pieces, calls Sum in between
+// and reuses the MAC after Reset
+func TestCMACStreaming(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(14))
+	mac, err := NewCMAC(randomBlock(t, rng))
+	check(err)
+	for i := 0; i < differentialRounds; i++ {
+		message := randomBytes(rng.Intn(200))
+		expected := mac.sum(message)
+
+		mac.Reset()
+		for rest := message; len(rest) > 0; {
+			n := rng.Intn(len(rest) + 1)
+			mac.Write(rest[:n])
+			rest = rest[n:]
+			mac.Sum(nil)
+		}
+		if tag := mac.Sum([]byte("prefix")); !bytes.Equal(tag, append([]byte("prefix"), expected[:]...)) {
+			t.Fatal("Expected ", string(encodehex(expected[:])), ",got ", string(encodehex(tag)))
+		}
+	}
+}
+
+// TestCBCMACDifferential compares CBC-MAC with the last block of
+// crypto/cipher CBC encryption under a zero input vector
+func TestCBCMACDifferential(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(15))
+	for i := 0; i < differentialRounds; i++ {
+		block := randomBlock(t, rng)
+		message := randomBytes(rng.Intn(20) * aes.BlockSize)
+		padded := message
+		if len(padded) == 0 {
+			padded = make([]byte, aes.BlockSize)
+		}
+		out := make([]byte, len(padded))
+		cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(out, padded)
+		expected := out[len(out)-aes.BlockSize:]
+
+		mac, err := NewCBCMAC(block)
+		check(err)
+		mac.Write(message)
+		if tag := mac.Sum(nil); !bytes.Equal(tag, expected) {
+			t.Fatal("Expected ", string(encodehex(expected)), ",got ", string(encodehex(tag)))
+		}
+
+		// A partial last block is padded with zeros, the message ending in
+		// zeros has the same tag without them
+		if len(message) > 0 {
+			copy(message[len(message)-3:], []byte{0, 0, 0})
+			cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(out, message)
+			mac.Reset()
+			mac.Write(message[:len(message)-3])
+			if tag := mac.Sum(nil); !bytes.Equal(tag, out[len(out)-aes.BlockSize:]) {
+				t.Fatal("Expected the partial last block to be padded with zeros")
+			}
+		}
+	}
+}
+
+func TestCMACErrors(t *testing.T) {
+	if _, err := NewCMAC(nil); err != ErrNilBlock {
+		t.Error("Expected ErrNilBlock, got ", err)
+	}
+	if _, err := NewCBCMAC(nil); err != ErrNilBlock {
+		t.Error("Expected ErrNilBlock, got ", err)
+	}
+}
+
+// cmacTestrun runs the tests of a CMACGen or CMACVer file, Mac is the tag
+// truncated to Tlen bytes, the tests of CMACVer have a Result
+func cmacTestrun(t *testing.T, tests []map[string]string) int {
+	numTests := 0
+	for _, test := range tests {
+		block, err := katCipher(decodehex([]byte(test["Key"])))
+		check(err)
+		tagSize, err := strconv.Atoi(test["Tlen"])
+		check(err)
+		message := cavphex(test, "Msg", "Mlen")
+		expected := decodehex([]byte(test["Mac"]))
+
+		mac, err := NewCMAC(block)
+		check(err)
+		mac.Write(message)
+		tag := mac.Sum(nil)[:tagSize]
+
+		result, verify := test["Result"]
+		if verify && !strings.HasPrefix(result, "P") {
+			if bytes.Equal(tag, expected) {
+				t.Error("Count ", test["Count"], " expected verification failure")
+			}
+		} else if !bytes.Equal(tag, expected) {
+			t.Error("Count ", test["Count"], " expected ", test["Mac"],
+				",got ", string(encodehex(tag)))
+		}
+		numTests++
+	}
+	return numTests
+}
+
+func TestCMACCAVP(t *testing.T) {
+	for _, kind := range []string{"Gen", "Ver"} {
+		for _, keySize := range []string{"128", "192", "256"} {
+			name := "CMAC" + kind + "AES" + keySize
+			t.Run(name, func(t *testing.T) {
+				var file cavpfile
+				testJSON := readtestfile(t, "jsontests/"+name+".json")
+				json.Unmarshal(testJSON, &file)
+				numTests := cmacTestrun(t, file.Tests)
+				fmt.Println("Num "+name+" tests: ", numTests)
+			})
+		}
+	}
+}
@@ -0,0 +1,198 @@
+/*
+	ctr.go
+
+	Implementation of the Counter (CTR) mode of operation with a configurable
+	counter width, e.g. the full 128-bit counter block or the 32-bit counter
+	with a 96-bit nonce.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	ctr.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/cipher"
+	"errors"
+)
+
+const (
+	// CounterFull increments the whole counter block
+	CounterFull = 128
+	// Counter32 increments the last 32 bits, the first 96 bits hold the nonce
+	Counter32 = 32
+)
+
+// ctrBatchBlocks is the number of counter blocks encrypted together by a
+// multiBlock cipher
+const ctrBatchBlocks = 32
+
+// ErrCounterSize is returned when the counter width is not supported
+var ErrCounterSize = errors.New("goaes: counter width must be a multiple of 8 bits, between 8 bits and the block size")
+
+var _ cipher.Stream = (*CTR)(nil)
+
+// CTR is the class for the Counter mode of operation
+type CTR struct {
+	aes          cipher.Block
+	blockSize    int
+	counter      []byte
+	counterBytes int
+	// Number of key stream blocks left before the counter wraps around,
+	// only tracked for counters narrow enough to wrap in practice
+	limited    bool
+	blocksLeft uint64
+	keyStream  []byte
+	used       int
+	// Settings for the parallel processing of whole key stream blocks
+	concurrency Concurrency
+}
+
+// NewCTR is a constructor for the CTR class
+// inputVec is the initial counter block, counterBits is the number of
+// its trailing bits that are incremented (CounterFull or Counter32)
+func NewCTR(b cipher.Block, inputVec []byte, counterBits int) (*CTR, error) {
+	if b == nil {
+		return nil, ErrNilBlock
+	}
+	if len(inputVec) != b.BlockSize() {
+		return nil, ErrIVSize
+	}
+	if counterBits < 8 || counterBits%8 != 0 || counterBits > 8*b.BlockSize() {
+		return nil, ErrCounterSize
+	}
+
+	ctr := &CTR{
+		aes:          b,
+		blockSize:    b.BlockSize(),
+		counter:      append([]byte(nil), inputVec...),
+		counterBytes: counterBits / 8,
+		keyStream:    make([]byte, b.BlockSize()),
+	}
+	// The key stream buffer starts out fully used
+	ctr.used = ctr.blockSize
+	if counterBits < 64 {
+		ctr.limited = true
+		ctr.blocksLeft = 1 << uint(counterBits)
+	}
+	return ctr, nil
+}
+
+// XORKeyStream implements cipher.Stream, dst and src may be the same slice
+// It panics once the counter would wrap around and repeat the key stream
+func (ctr *CTR) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("goaes: output smaller than input")
+	}
+	if inexactOverlap(dst[:len(src)], src) {
+		panic("goaes: invalid buffer overlap")
+	}
+
+	for len(src) > 0 {
+		// Whole blocks at a block boundary are split across the workers or
+		// encrypted several at once, unless the counter would run out, which
+		// the loop below reports
+		_, multi := ctr.aes.(multiBlock)
+		if full := len(src) - len(src)%ctr.blockSize; ctr.used == ctr.blockSize &&
+			(ctr.concurrency.parallel() || multi) && full > 0 &&
+			(!ctr.limited || ctr.blocksLeft >= uint64(full/ctr.blockSize)) {
+			ctr.xorBlocks(dst[:full], src[:full])
+			dst, src = dst[full:], src[full:]
+			continue
+		}
+		if ctr.used == ctr.blockSize {
+			ctr.refill()
+		}
+		n := len(src)
+		if n > ctr.blockSize-ctr.used {
+			n = ctr.blockSize - ctr.used
+		}
+		xor(dst[:n], src[:n], ctr.keyStream[ctr.used:])
+		ctr.used += n
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+// xorBlocks encrypts whole blocks concurrently, each chunk starts from
+// its own copy of the counter advanced to its first block
+// A multiBlock cipher encrypts the counter blocks in batches
+func (ctr *CTR) xorBlocks(dst, src []byte) {
+	blocks := uint64(len(src) / ctr.blockSize)
+	ctr.concurrency.run(len(src), ctr.blockSize, func(start, end int) {
+		counter := append([]byte(nil), ctr.counter...)
+		addCounter(counter[ctr.blockSize-ctr.counterBytes:], uint64(start/ctr.blockSize))
+		if mb, ok := ctr.aes.(multiBlock); ok {
+			keyStream := make([]byte, ctrBatchBlocks*ctr.blockSize)
+			for i := start; i < end; i += len(keyStream) {
+				n := len(keyStream)
+				if n > end-i {
+					n = end - i
+				}
+				for j := 0; j < n; j += ctr.blockSize {
+					copy(keyStream[j:], counter)
+					incCounter(counter[ctr.blockSize-ctr.counterBytes:])
+				}
+				mb.EncryptBlocks(keyStream[:n], keyStream[:n])
+				xor(dst[i:i+n], src[i:i+n], keyStream)
+			}
+			return
+		}
+		keyStream := make([]byte, ctr.blockSize)
+		for i := start; i < end; i += ctr.blockSize {
+			ctr.aes.Encrypt(keyStream, counter)
+			incCounter(counter[ctr.blockSize-ctr.counterBytes:])
+			xor(dst[i:i+ctr.blockSize], src[i:i+ctr.blockSize], keyStream)
+		}
+	})
+	if ctr.limited {
+		ctr.blocksLeft -= blocks
+	}
+	addCounter(ctr.counter[ctr.blockSize-ctr.counterBytes:], blocks)
+}
+
+// Encrypt is a CTR method for encryption
+func (ctr *CTR) Encrypt(in []byte) []byte {
+	out := make([]byte, len(in))
+	ctr.XORKeyStream(out, in)
+	return out
+}
+
+// Decrypt is a CTR method for decryption, which is the same as encryption
+func (ctr *CTR) Decrypt(in []byte) []byte {
+	return ctr.Encrypt(in)
+}
+
+// refill encrypts the current counter block into the key stream buffer
+// and increments the counter
+func (ctr *CTR) refill() {
+	if ctr.limited {
+		if ctr.blocksLeft == 0 {
+			panic("goaes: counter space exhausted")
+		}
+		ctr.blocksLeft--
+	}
+	ctr.aes.Encrypt(ctr.keyStream, ctr.counter)
+	ctr.used = 0
+	incCounter(ctr.counter[ctr.blockSize-ctr.counterBytes:])
+}
+
+// incCounter increments a big-endian counter, wrapping within its width
+func incCounter(counter []byte) {
+	for i := len(counter) - 1; i >= 0; i-- {
+		counter[i]++
+		if counter[i] != 0 {
+			break
+		}
+	}
+}
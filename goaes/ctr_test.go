@@ -0,0 +1,210 @@
+/*
+	ctr_test.go
+
+	NIST SP 800-38A, Appendix F.5 CTR example vectors
+	See: https://csrc.nist.gov/publications/detail/sp/800-38a/final
+
+	RFC3686, Using AES Counter Mode With IPsec ESP (32-bit counter)
+	See: https://tools.ietf.org/html/rfc3686
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	ctr_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	mrand "math/rand"
+	"testing"
+)
+
+type ctrtest struct {
+	name        string
+	key         string
+	inputVec    string
+	counterBits int
+	plaintext   string
+	ciphertext  string
+}
+
+// SP 800-38A F.5 plaintext shared by all the example vectors
+const sp80038aPlaintext = "6bc1bee22e409f96e93d7e117393172a" +
+	"ae2d8a571e03ac9c9eb76fac45af8e51" +
+	"30c81c46a35ce411e5fbc1191a0a52ef" +
+	"f69f2445df4f9b17ad2b417be66c3710"
+
+var ctrtests = []ctrtest{
+	{
+		"F.5.1 CTR-AES128",
+		"2b7e151628aed2a6abf7158809cf4f3c",
+		"f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff",
+		CounterFull,
+		sp80038aPlaintext,
+		"874d6191b620e3261bef6864990db6ce" +
+			"9806f66b7970fdff8617187bb9fffdff" +
+			"5ae4df3edbd5d35e5b4f09020db03eab" +
+			"1e031dda2fbe03d1792170a0f3009cee",
+	},
+	{
+		"F.5.3 CTR-AES192",
+		"8e73b0f7da0e6452c810f32b809079e562f8ead2522c6b7b",
+		"f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff",
+		CounterFull,
+		sp80038aPlaintext,
+		"1abc932417521ca24f2b0459fe7e6e0b" +
+			"090339ec0aa6faefd5ccc2c6f4ce8e94" +
+			"1e36b26bd1ebc670d1bd1d665620abf7" +
+			"4f78a7f6d29809585a97daec58c6b050",
+	},
+	{
+		"F.5.5 CTR-AES256",
+		"603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4",
+		"f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff",
+		CounterFull,
+		sp80038aPlaintext,
+		"601ec313775789a5b7a7f504bbf3d228" +
+			"f443e3ca4d62b59aca84e990cacaf5c5" +
+			"2b0930daa23de94ce87017ba2d84988d" +
+			"dfc9c58db67aada613c2dd08457941a6",
+	},
+	{
+		"RFC3686 Test Vector #1",
+		"ae6852f8121067cc4bf7a5765577f39e",
+		"00000030000000000000000000000001",
+		Counter32,
+		"53696e676c6520626c6f636b206d7367",
+		"e4095d4fb7a7b3792d6175a3261311b8",
+	},
+	{
+		"RFC3686 Test Vector #2",
+		"7e24067817fae0d743d6ce1f32539163",
+		"006cb6dbc0543b59da48d90b00000001",
+		Counter32,
+		"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		"5104a106168a72d9790d41ee8edad388eb2e1efc46da57c8fce630df9141be28",
+	},
+}
+
+func TestCTRVectors(t *testing.T) {
+	for _, test := range ctrtests {
+		block, err := katCipher(decodehex([]byte(test.key)))
+		check(err)
+		plaintext := decodehex([]byte(test.plaintext))
+		expected := decodehex([]byte(test.ciphertext))
+
+		ctr, err := NewCTR(block, decodehex([]byte(test.inputVec)), test.counterBits)
+		check(err)
+		encrypted := ctr.Encrypt(plaintext)
+		if !bytes.Equal(encrypted, expected) {
+			t.Error(test.name, " expected ", string(encodehex(expected)),
+				",got ", string(encodehex(encrypted)))
+		}
+
+		// F.5.2, F.5.4 and F.5.6 are the matching decryption vectors
+		ctr, err = NewCTR(block, decodehex([]byte(test.inputVec)), test.counterBits)
+		check(err)
+		decrypted := ctr.Decrypt(expected)
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Error(test.name, " expected ", string(encodehex(plaintext)),
+				",got ", string(encodehex(decrypted)))
+		}
+	}
+}
+
+func TestCTRDifferential(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(5))
+	for i := 0; i < differentialRounds; i++ {
+		block := randomBlock(t, rng)
+		inputVec := randomBytes(aes.BlockSize)
+		plaintext := randomBytes(rng.Intn(1024))
+
+		expected := make([]byte, len(plaintext))
+		cipher.NewCTR(block, inputVec).XORKeyStream(expected, plaintext)
+
+		ctr, err := NewCTR(block, inputVec, CounterFull)
+		check(err)
+		// Feed uneven chunks in-place to exercise the buffered key stream
+		encrypted := append([]byte(nil), plaintext...)
+		for rest := encrypted; len(rest) > 0; {
+			n := 1 + rng.Intn(len(rest))
+			ctr.XORKeyStream(rest[:n], rest[:n])
+			rest = rest[n:]
+		}
+		if !bytes.Equal(encrypted, expected) {
+			t.Fatal("Expected ", string(encodehex(expected)),
+				",got ", string(encodehex(encrypted)))
+		}
+	}
+}
+
+func TestCTRCounterWraps(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	check(err)
+	nonce := "0102030405060708090a0b0c"
+	inputVec := decodehex([]byte(nonce + "ffffffff"))
+
+	ctr, err := NewCTR(block, inputVec, Counter32)
+	check(err)
+	keyStream := ctr.Encrypt(make([]byte, 2*aes.BlockSize))
+
+	// The second block must use the wrapped counter, leaving the nonce untouched
+	expected := make([]byte, aes.BlockSize)
+	block.Encrypt(expected, decodehex([]byte(nonce+"00000000")))
+	if !bytes.Equal(keyStream[aes.BlockSize:], expected) {
+		t.Error("Expected ", string(encodehex(expected)),
+			",got ", string(encodehex(keyStream[aes.BlockSize:])))
+	}
+
+	// The full-width counter carries into the upper bytes instead
+	ctr, err = NewCTR(block, inputVec, CounterFull)
+	check(err)
+	keyStream = ctr.Encrypt(make([]byte, 2*aes.BlockSize))
+	block.Encrypt(expected, decodehex([]byte("0102030405060708090a0b0d00000000")))
+	if !bytes.Equal(keyStream[aes.BlockSize:], expected) {
+		t.Error("Expected ", string(encodehex(expected)),
+			",got ", string(encodehex(keyStream[aes.BlockSize:])))
+	}
+}
+
+func TestCTRCounterExhausted(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	check(err)
+	ctr, err := NewCTR(block, make([]byte, aes.BlockSize), 8)
+	check(err)
+
+	// An 8-bit counter yields exactly 256 blocks of key stream
+	ctr.Encrypt(make([]byte, 256*aes.BlockSize))
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic once the counter space is exhausted")
+		}
+	}()
+	ctr.Encrypt(make([]byte, 1))
+}
+
+func TestCTRConstructorErrors(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	check(err)
+	for _, bits := range []int{0, 12, 136} {
+		if _, err := NewCTR(block, make([]byte, aes.BlockSize), bits); err != ErrCounterSize {
+			t.Error("Expected ErrCounterSize for ", bits, " bits, got ", err)
+		}
+	}
+	if _, err := NewCTR(block, make([]byte, 12), Counter32); err != ErrIVSize {
+		t.Error("Expected ErrIVSize, got ", err)
+	}
+}
@@ -0,0 +1,158 @@
+/*
+	eax.go
+
+	Implementation of the EAX mode of operation of Bellare, Rogaway and
+	Wagner, an authenticated encryption that encrypts with CTR and
+	authenticates the nonce, the additional data and the ciphertext with
+	three tweaked CMACs (OMAC), whose XOR is the tag.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	eax.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+)
+
+const (
+	eaxBlockSize      = 16
+	eaxTagSize        = 16
+	eaxMinimumTagSize = 4
+)
+
+var _ cipher.AEAD = (*EAX)(nil)
+
+// EAX is the class for the EAX mode of operation
+type EAX struct {
+	aes       cipher.Block
+	mac       *CMAC
+	tagSize   int
+	nonceSize int
+}
+
+// NewEAX is a constructor for the EAX class
+// tagSize must be between 4 and 16 bytes, a truncated tag is the prefix of
+// the full one, nonceSize may be any positive length, usually 16 bytes
+func NewEAX(b cipher.Block, tagSize, nonceSize int) (*EAX, error) {
+	if b == nil {
+		return nil, ErrNilBlock
+	}
+	if b.BlockSize() != eaxBlockSize {
+		return nil, ErrBlockSize
+	}
+	if tagSize < eaxMinimumTagSize || tagSize > eaxTagSize {
+		return nil, ErrTagSize
+	}
+	if nonceSize < 1 {
+		return nil, ErrNonceSize
+	}
+	mac, err := NewCMAC(b)
+	if err != nil {
+		return nil, err
+	}
+	return &EAX{aes: b, mac: mac, tagSize: tagSize, nonceSize: nonceSize}, nil
+}
+
+// NonceSize returns the size of the nonce that must be passed to Seal and Open
+func (e *EAX) NonceSize() int { return e.nonceSize }
+
+// Overhead returns the difference between the lengths of a plaintext and its ciphertext
+func (e *EAX) Overhead() int { return e.tagSize }
+
+// Seal encrypts and authenticates plaintext, authenticates additionalData
+// and appends the ciphertext and tag to dst
+func (e *EAX) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != e.nonceSize {
+		panic("goaes: incorrect nonce length given to EAX")
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext)+e.tagSize)
+	if inexactOverlap(out, plaintext) {
+		panic("goaes: invalid buffer overlap")
+	}
+
+	// The MAC of the nonce is the initial counter block
+	n := e.omac(0, nonce)
+	e.counterCrypt(out, plaintext, n[:])
+	tag := e.tag(n, out[:len(plaintext)], additionalData)
+	copy(out[len(plaintext):], tag[:e.tagSize])
+
+	return ret
+}
+
+// Open decrypts and authenticates ciphertext, authenticates additionalData
+// and appends the plaintext to dst
+// EAX authenticates the ciphertext, nothing is decrypted if the tag is invalid
+func (e *EAX) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != e.nonceSize {
+		panic("goaes: incorrect nonce length given to EAX")
+	}
+	if len(ciphertext) < e.tagSize {
+		return nil, ErrOpen
+	}
+
+	tag := ciphertext[len(ciphertext)-e.tagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-e.tagSize]
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	if inexactOverlap(out, ciphertext) {
+		panic("goaes: invalid buffer overlap")
+	}
+
+	n := e.omac(0, nonce)
+	expectedTag := e.tag(n, ciphertext, additionalData)
+	if subtle.ConstantTimeCompare(expectedTag[:e.tagSize], tag) != 1 {
+		return nil, ErrOpen
+	}
+
+	e.counterCrypt(out, ciphertext, n[:])
+	return ret, nil
+}
+
+// tag is the XOR of the MACs of the nonce, the additional data and the
+// ciphertext
+func (e *EAX) tag(n [eaxBlockSize]byte, ciphertext, additionalData []byte) [eaxBlockSize]byte {
+	h := e.omac(1, additionalData)
+	c := e.omac(2, ciphertext)
+	xor(n[:], n[:], h[:])
+	xor(n[:], n[:], c[:])
+	return n
+}
+
+// omac is OMAC^t of the EAX paper, the CMAC of data prefixed by the block
+// encoding t, it only reads e and may be called concurrently
+func (e *EAX) omac(t byte, data []byte) (tag [eaxBlockSize]byte) {
+	var prefix [eaxBlockSize]byte
+	prefix[eaxBlockSize-1] = t
+
+	m := *e.mac
+	m.Reset()
+	m.Write(prefix[:])
+	m.Write(data)
+	m.Sum(tag[:0])
+	return
+}
+
+// counterCrypt encrypts src into dst with CTR, the whole counter block is
+// incremented as a big-endian number
+func (e *EAX) counterCrypt(dst, src, counter []byte) {
+	ctr, err := NewCTR(e.aes, counter, CounterFull)
+	if err != nil {
+		panic(err)
+	}
+	ctr.XORKeyStream(dst, src)
+}
@@ -0,0 +1,243 @@
+/*
+	eax_test.go
+
+	Tests for the EAX mode of operation.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	eax_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	mrand "math/rand"
+	"testing"
+)
+
+// Test vectors of the EAX paper, Bellare, Rogaway and Wagner, "The EAX
+// Mode of Operation", in the layout of the GCM vectors
+var eaxTests = []gcmtest{
+	{
+		"Paper #1",
+		"233952dee4d5ed5f9b9c6d6ff80ff478",
+		"62ec67f9c3a4a407fcb2a8c49031a8b3",
+		"",
+		"6bfb914fd07eae6b",
+		"",
+		"e037830e8389f27b025a2d6527e79d01",
+	},
+	{
+		"Paper #2",
+		"91945d3f4dcbee0bf45ef52255f095a4",
+		"becaf043b0a23d843194ba972c66debd",
+		"f7fb",
+		"fa3bfd4806eb53fa",
+		"19dd",
+		"5c4c9331049d0bdab0277408f67967e5",
+	},
+	{
+		"Paper #3",
+		"01f74ad64077f2e704c0f60ada3dd523",
+		"70c3db4f0d26368400a10ed05d2bff5e",
+		"1a47cb4933",
+		"234a3463c1264ac6",
+		"d851d5bae0",
+		"3a59f238a23e39199dc9266626c40f80",
+	},
+	{
+		"Paper #4",
+		"d07cf6cbb7f313bdde66b727afd3c5e8",
+		"8408dfff3c1a2b1292dc199e46b7d617",
+		"481c9e39b1",
+		"33cce2eabff5a79d",
+		"632a9d131a",
+		"d4c168a4225d8e1ff755939974a7bede",
+	},
+	{
+		"Paper #5",
+		"35b6d0580005bbc12b0587124557d2c2",
+		"fdb6b06676eedc5c61d74276e1f8e816",
+		"40d0c07da5e4",
+		"aeb96eaebe2970e9",
+		"071dfe16c675",
+		"cb0677e536f73afe6a14b74ee49844dd",
+	},
+	{
+		"Paper #6",
+		"bd8e6e11475e60b268784c38c62feb22",
+		"6eac5c93072d8e8513f750935e46da1b",
+		"4de3b35c3fc039245bd1fb7d",
+		"d4482d1ca78dce0f",
+		"835bb4f15d743e350e728414",
+		"abb8644fd6ccb86947c5e10590210a4f",
+	},
+	{
+		"Paper #7",
+		"7c77d6e813bed5ac98baa417477a2e7d",
+		"1a8c98dcd73d38393b2bf1569deefc19",
+		"8b0a79306c9ce7ed99dae4f87f8dd61636",
+		"65d2017990d62528",
+		"02083e3979da014812f59f11d52630da30",
+		"137327d10649b0aa6e1c181db617d7f2",
+	},
+	{
+		"Paper #8",
+		"5fff20cafab119ca2fc73549e20f5b0d",
+		"dde59b97d722156d4d9aff2bc7559826",
+		"1bda122bce8a8dbaf1877d962b8592dd2d56",
+		"54b9f04e6a09189a",
+		"2ec47b2c4954a489afc7ba4897edcdae8cc3",
+		"3b60450599bd02c96382902aef7f832a",
+	},
+	{
+		"Paper #9",
+		"a4a4782bcffd3ec5e7ef6d8c34a56123",
+		"b781fcf2f75fa5a8de97a9ca48e522ec",
+		"6cf36720872b8513f6eab1a8a44438d5ef11",
+		"899a175897561d7e",
+		"0de18fd0fdd91e7af19f1d8ee8733938b1e8",
+		"e7f6d2231618102fdb7fe55ff1991700",
+	},
+	{
+		"Paper #10",
+		"8395fcf1e95bebd697bd010bc766aac3",
+		"22e7add93cfc6393c57ec0b3c17d6b44",
+		"ca40d7446e545ffaed3bd12a740a659ffbbb3ceab7",
+		"126735fcc320d25a",
+		"cb8920f87a6c75cff39627b56e3ed197c552d295a7",
+		"cfc46afc253b4652b1af3795b124ab6e",
+	},
+}
+
+func TestEAXVectors(t *testing.T) {
+	for _, test := range eaxTests {
+		block, err := katCipher(decodehex([]byte(test.key)))
+		check(err)
+		nonce := decodehex([]byte(test.nonce))
+		aead, err := NewEAX(block, 16, len(nonce))
+		check(err)
+		plaintext := decodehex([]byte(test.plaintext))
+		aad := decodehex([]byte(test.aad))
+		expected := decodehex([]byte(test.ciphertext + test.tag))
+
+		sealed := aead.Seal(nil, nonce, plaintext, aad)
+		if !bytes.Equal(sealed, expected) {
+			t.Error(test.name, " expected ", string(encodehex(expected)),
+				",got ", string(encodehex(sealed)))
+		}
+
+		opened, err := aead.Open(nil, nonce, expected, aad)
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Error(test.name, " expected ", string(encodehex(plaintext)),
+				",got ", string(encodehex(opened)), " error ", err)
+		}
+	}
+}
+
+// TestEAXTagSize checks that a truncated tag is the prefix of the full tag
+// and that the nonce may have any length
+func TestEAXTagSize(t *testing.T) {
+	block, err := aes.NewCipher(randomBytes(16))
+	check(err)
+	plaintext := randomBytes(50)
+	aad := randomBytes(20)
+	for _, nonceSize := range []int{1, 12, 16, 40} {
+		nonce := randomBytes(nonceSize)
+		full, err := NewEAX(block, 16, nonceSize)
+		check(err)
+		sealed := full.Seal(nil, nonce, plaintext, aad)
+		for tagSize := 4; tagSize <= 16; tagSize++ {
+			aead, err := NewEAX(block, tagSize, nonceSize)
+			check(err)
+			truncated := aead.Seal(nil, nonce, plaintext, aad)
+			if !bytes.Equal(truncated, sealed[:len(plaintext)+tagSize]) {
+				t.Error("Expected the prefix of the full tag for ", tagSize, " bytes")
+			}
+			if _, err := aead.Open(nil, nonce, truncated, aad); err != nil {
+				t.Error("Expected a valid tag of ", tagSize, " bytes, got ", err)
+			}
+		}
+	}
+}
+
+func TestEAXTampering(t *testing.T) {
+	block, err := aes.NewCipher(randomBytes(16))
+	check(err)
+	aead, err := NewEAX(block, 16, 16)
+	check(err)
+	nonce := randomBytes(16)
+	aad := randomBytes(20)
+	sealed := aead.Seal(nil, nonce, randomBytes(40), aad)
+
+	for i := 0; i < len(sealed)*8; i++ {
+		tampered := append([]byte(nil), sealed...)
+		tampered[i/8] ^= 1 << uint(i%8)
+		if out, err := aead.Open(nil, nonce, tampered, aad); err != ErrOpen || out != nil {
+			t.Fatal("Expected ErrOpen and no output after flipping bit ", i)
+		}
+	}
+
+	otherNonce := append([]byte(nil), nonce...)
+	otherNonce[0] ^= 1
+	if _, err := aead.Open(nil, otherNonce, sealed, aad); err != ErrOpen {
+		t.Error("Expected ErrOpen for another nonce, got ", err)
+	}
+	if _, err := aead.Open(nil, nonce, sealed, aad[1:]); err != ErrOpen {
+		t.Error("Expected ErrOpen for modified additional data, got ", err)
+	}
+	if _, err := aead.Open(nil, nonce, sealed[:aead.Overhead()-1], aad); err != ErrOpen {
+		t.Error("Expected ErrOpen for truncated ciphertext, got ", err)
+	}
+}
+
+func TestEAXRoundTrip(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(13))
+	for i := 0; i < differentialRounds; i++ {
+		block, err := aes.NewCipher(randomBytes(keySizes[rng.Intn(len(keySizes))]))
+		check(err)
+		aead, err := NewEAX(block, 4+rng.Intn(13), 1+rng.Intn(32))
+		check(err)
+		nonce := randomBytes(aead.NonceSize())
+		plaintext := randomBytes(rng.Intn(1200))
+		aad := randomBytes(rng.Intn(40))
+
+		sealed := aead.Seal(nil, nonce, plaintext, aad)
+		buf := append(make([]byte, 0, len(plaintext)+aead.Overhead()), plaintext...)
+		if inPlace := aead.Seal(buf[:0], nonce, buf, aad); !bytes.Equal(inPlace, sealed) {
+			t.Fatal("In-place encryption differs")
+		}
+		opened, err := aead.Open(sealed[:0], nonce, sealed, aad)
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Fatal("Round trip failed with error ", err)
+		}
+	}
+}
+
+func TestEAXConstructorErrors(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	check(err)
+	if _, err := NewEAX(nil, 16, 16); err != ErrNilBlock {
+		t.Error("Expected ErrNilBlock, got ", err)
+	}
+	for _, tagSize := range []int{0, 3, 17} {
+		if _, err := NewEAX(block, tagSize, 16); err != ErrTagSize {
+			t.Error("Expected ErrTagSize for ", tagSize, " bytes, got ", err)
+		}
+	}
+	if _, err := NewEAX(block, 16, 0); err != ErrNonceSize {
+		t.Error("Expected ErrNonceSize for an empty nonce, got ", err)
+	}
+}
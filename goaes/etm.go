@@ -0,0 +1,208 @@
+/*
+	etm.go
+
+	Encrypt-then-MAC composition of AES-CBC or AES-CTR with HMAC-SHA2. The
+	CBC variant is AES_CBC_HMAC_SHA2 of RFC 7518 (A128CBC-HS256,
+	A192CBC-HS384 and A256CBC-HS512), the CTR variant uses the same keys and
+	tag with CTR instead of padded CBC.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	etm.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"hash"
+)
+
+// ErrEtMKeySize is returned when the Encrypt-then-MAC key is not 32, 48 or 64 bytes long
+var ErrEtMKeySize = errors.New("goaes: Encrypt-then-MAC key must be 32, 48 or 64 bytes")
+
+var _ cipher.AEAD = (*EtM)(nil)
+
+// EtM is the class for the Encrypt-then-MAC composition of AES with HMAC-SHA2
+type EtM struct {
+	aes     cipher.Block
+	macKey  []byte
+	hash    func() hash.Hash
+	tagSize int
+	// CTR instead of CBC with PKCS#7 padding
+	ctr bool
+}
+
+// NewCBCHMAC is a constructor for the EtM class with AES-CBC and PKCS#7
+// padding, as AES_CBC_HMAC_SHA2 of RFC 7518 section 5.2
+// The key length selects A128CBC-HS256 (32 bytes), A192CBC-HS384 (48 bytes)
+// or A256CBC-HS512 (64 bytes), its first half keys HMAC and its second
+// half AES, cipherFunc is usually aes.NewCipher
+// The nonce is the CBC input vector and must be random for every message
+func NewCBCHMAC(cipherFunc func([]byte) (cipher.Block, error), key []byte) (*EtM, error) {
+	return newEtM(cipherFunc, key, false)
+}
+
+// NewCTRHMAC is a constructor for the EtM class with AES-CTR, it splits
+// the key and computes the tag as NewCBCHMAC does
+// The nonce is the initial counter block, with all 128 bits incremented,
+// and must never repeat under the same key, nor overlap the counter blocks
+// of another message
+func NewCTRHMAC(cipherFunc func([]byte) (cipher.Block, error), key []byte) (*EtM, error) {
+	return newEtM(cipherFunc, key, true)
+}
+
+func newEtM(cipherFunc func([]byte) (cipher.Block, error), key []byte, ctr bool) (*EtM, error) {
+	var h func() hash.Hash
+	switch len(key) {
+	case 32:
+		h = sha256.New
+	case 48:
+		h = sha512.New384
+	case 64:
+		h = sha512.New
+	default:
+		return nil, ErrEtMKeySize
+	}
+
+	// MAC_KEY || ENC_KEY, see RFC 7518 section 5.2.2.1
+	block, err := cipherFunc(key[len(key)/2:])
+	if err != nil {
+		return nil, err
+	}
+	if block.BlockSize() != BlockSize {
+		return nil, ErrBlockSize
+	}
+	return &EtM{
+		aes:     block,
+		macKey:  append([]byte(nil), key[:len(key)/2]...),
+		hash:    h,
+		tagSize: len(key) / 2,
+		ctr:     ctr,
+	}, nil
+}
+
+// NonceSize returns the size of the nonce that must be passed to Seal and Open
+func (e *EtM) NonceSize() int { return BlockSize }
+
+// Overhead returns the maximum difference between the lengths of a
+// plaintext and its ciphertext, the tag and for CBC a full block of padding
+func (e *EtM) Overhead() int {
+	if e.ctr {
+		return e.tagSize
+	}
+	return BlockSize + e.tagSize
+}
+
+// Seal encrypts plaintext, authenticates the nonce, the ciphertext and
+// additionalData and appends the ciphertext and tag to dst
+func (e *EtM) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != BlockSize {
+		panic("goaes: incorrect nonce length given to EtM")
+	}
+
+	var ciphertext []byte
+	if e.ctr {
+		ctr, err := NewCTR(e.aes, nonce, CounterFull)
+		if err != nil {
+			panic(err)
+		}
+		ciphertext = ctr.Encrypt(plaintext)
+	} else {
+		cbc, err := NewCBC(e.aes, nonce)
+		if err != nil {
+			panic(err)
+		}
+		padded, err := Pad(plaintext, BlockSize)
+		if err != nil {
+			panic(err)
+		}
+		ciphertext, err = cbc.Encrypt(padded)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	ret, out := sliceForAppend(dst, len(ciphertext)+e.tagSize)
+	copy(out, ciphertext)
+	e.tag(out[len(ciphertext):], nonce, ciphertext, additionalData)
+	return ret
+}
+
+// Open verifies the tag in constant time and, only if it is valid,
+// decrypts the ciphertext and appends the plaintext to dst
+// The padding is only checked on authentic ciphertexts, so a forged
+// ciphertext learns nothing from it
+func (e *EtM) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != BlockSize {
+		panic("goaes: incorrect nonce length given to EtM")
+	}
+	if len(ciphertext) < e.tagSize {
+		return nil, ErrOpen
+	}
+	tag := ciphertext[len(ciphertext)-e.tagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-e.tagSize]
+	if !e.ctr && (len(ciphertext) == 0 || len(ciphertext)%BlockSize != 0) {
+		return nil, ErrOpen
+	}
+
+	expectedTag := make([]byte, e.tagSize)
+	e.tag(expectedTag, nonce, ciphertext, additionalData)
+	if subtle.ConstantTimeCompare(expectedTag, tag) != 1 {
+		return nil, ErrOpen
+	}
+
+	var plaintext []byte
+	if e.ctr {
+		ctr, err := NewCTR(e.aes, nonce, CounterFull)
+		if err != nil {
+			panic(err)
+		}
+		plaintext = ctr.Decrypt(ciphertext)
+	} else {
+		cbc, err := NewCBC(e.aes, nonce)
+		if err != nil {
+			panic(err)
+		}
+		padded, err := cbc.Decrypt(ciphertext)
+		if err != nil {
+			panic(err)
+		}
+		if plaintext, err = (PKCS7{}).Unpad(padded, BlockSize); err != nil {
+			return nil, ErrOpen
+		}
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext))
+	copy(out, plaintext)
+	return ret, nil
+}
+
+// tag computes the truncated HMAC of A || IV || E || AL into out, where AL
+// is the bit length of the additional data A as a 64-bit big-endian integer
+func (e *EtM) tag(out, nonce, ciphertext, additionalData []byte) {
+	mac := hmac.New(e.hash, e.macKey)
+	mac.Write(additionalData)
+	mac.Write(nonce)
+	mac.Write(ciphertext)
+	var lengths [8]byte
+	binary.BigEndian.PutUint64(lengths[:], uint64(len(additionalData))*8)
+	mac.Write(lengths[:])
+	copy(out, mac.Sum(nil))
+}
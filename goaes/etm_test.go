@@ -0,0 +1,215 @@
+/*
+	etm_test.go
+
+	Tests of the Encrypt-then-MAC composition with the RFC 7518 Appendix B
+	vectors, tampering and the order of verification and decryption.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	etm_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	mrand "math/rand"
+	"testing"
+)
+
+const (
+	// Plaintext, IV and associated data shared by the RFC 7518 Appendix B vectors
+	etmPlaintext = "41206369706865722073797374656d206d757374206e6f7420626520726571756972656420746f2062652073656372" +
+		"65742c20616e64206974206d7573742062652061626c6520746f2066616c6c20696e746f207468652068616e6473206f6620" +
+		"74686520656e656d7920776974686f757420696e636f6e76656e69656e6365"
+	etmIV  = "1af38c2dc2b96ffdd86694092341bc04"
+	etmAAD = "546865207365636f6e64207072696e6369706c65206f662041756775737465204b6572636b686f666673"
+)
+
+// RFC 7518 Appendix B, the keys are the bytes 00 01 02 ... of the given length
+var etmtests = []struct {
+	name       string
+	keySize    int
+	ciphertext string
+	tag        string
+}{
+	{
+		"A128CBC-HS256",
+		32,
+		"c80edfa32ddf39d5ef00c0b468834279a2e46a1b8049f792f76bfe54b903a9c9a94ac9b47ad2655c5f10f9aef71427e2" +
+			"fc6f9b3f399a221489f16362c703233609d45ac69864e3321cf82935ac4096c86e133314c54019e8ca7980dfa4b9cf1b" +
+			"384c486f3a54c51078158ee5d79de59fbd34d848b3d69550a67646344427ade54b8851ffb598f7f80074b9473c82e2db",
+		"652c3fa36b0a7c5b3219fab3a30bc1c4",
+	},
+	{
+		"A192CBC-HS384",
+		48,
+		"ea65da6b59e61edb419be62d19712ae5d303eeb50052d0dfd6697f77224c8edb000d279bdc14c1072654bd30944230c6" +
+			"57bed4ca0c9f4a8466f22b226d1746214bf8cfc2400add9f5126e479663fc90b3bed787a2f0ffcbf3904be2a641d5c21" +
+			"05bfe591bae23b1d7449e532eef60a9ac8bb6c6b01d35d49787bcd57ef484927f280adc91ac0c4e79c7b11efc60054e3",
+		"8490ac0e58949bfe51875d733f93ac2075168039ccc733d7",
+	},
+	{
+		"A256CBC-HS512",
+		64,
+		"4affaaadb78c31c5da4b1b590d10ffbd3dd8d5d302423526912da037ecbcc7bd822c301dd67c373bccb584ad3e9279c2" +
+			"e6d12a1374b77f077553df829410446b36ebd97066296ae6427ea75c2e0846a11a09ccf5370dc80bfecbad28c73f09b3" +
+			"a3b75e662a2594410ae496b2e2e6609e31e6e02cc837f053d21f37ff4f51950bbe2638d09dd7a4930930806d0703b1f6",
+		"4dd3b4c088a7f45c216839645b2012bf2e6269a8c56a816dbc1b267761955bc5",
+	},
+}
+
+func etmKey(n int) []byte {
+	key := make([]byte, n)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	return key
+}
+
+func TestCBCHMACVectors(t *testing.T) {
+	plaintext := decodehex([]byte(etmPlaintext))
+	nonce := decodehex([]byte(etmIV))
+	aad := decodehex([]byte(etmAAD))
+	for _, test := range etmtests {
+		etm, err := NewCBCHMAC(katCipher, etmKey(test.keySize))
+		check(err)
+		expected := decodehex([]byte(test.ciphertext + test.tag))
+
+		sealed := etm.Seal(nil, nonce, plaintext, aad)
+		if !bytes.Equal(sealed, expected) {
+			t.Error(test.name, ": expected ", test.ciphertext+test.tag, ",got ", string(encodehex(sealed)))
+		}
+		opened, err := etm.Open(nil, nonce, expected, aad)
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Error(test.name, ": decryption failed with error ", err)
+		}
+	}
+}
+
+func TestEtMTampering(t *testing.T) {
+	for _, newEtM := range []func(func([]byte) (cipher.Block, error), []byte) (*EtM, error){NewCBCHMAC, NewCTRHMAC} {
+		etm, err := newEtM(aes.NewCipher, etmKey(32))
+		check(err)
+		nonce := randomBytes(etm.NonceSize())
+		plaintext := randomBytes(40)
+		aad := randomBytes(20)
+		sealed := etm.Seal(nil, nonce, plaintext, aad)
+
+		for i := 0; i < len(sealed)*8; i++ {
+			tampered := append([]byte(nil), sealed...)
+			tampered[i/8] ^= 1 << uint(i%8)
+			if out, err := etm.Open(nil, nonce, tampered, aad); err != ErrOpen || out != nil {
+				t.Fatal("Expected ErrOpen and no output after flipping bit ", i)
+			}
+		}
+
+		otherNonce := append([]byte(nil), nonce...)
+		otherNonce[0] ^= 1
+		if _, err := etm.Open(nil, otherNonce, sealed, aad); err != ErrOpen {
+			t.Error("Expected ErrOpen for a modified nonce, got ", err)
+		}
+		otherAAD := append([]byte(nil), aad...)
+		otherAAD[0] ^= 1
+		if _, err := etm.Open(nil, nonce, sealed, otherAAD); err != ErrOpen {
+			t.Error("Expected ErrOpen for modified additional data, got ", err)
+		}
+		for _, n := range []int{0, 1, etm.tagSize - 1, etm.tagSize, len(sealed) - 1} {
+			if _, err := etm.Open(nil, nonce, sealed[:n], aad); err != ErrOpen {
+				t.Error("Expected ErrOpen for ", n, " bytes, got ", err)
+			}
+		}
+	}
+}
+
+func TestEtMRoundTrip(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(9))
+	for i := 0; i < differentialRounds; i++ {
+		newEtM := []func(func([]byte) (cipher.Block, error), []byte) (*EtM, error){NewCBCHMAC, NewCTRHMAC}[rng.Intn(2)]
+		etm, err := newEtM(aes.NewCipher, randomBytes([]int{32, 48, 64}[rng.Intn(3)]))
+		check(err)
+		nonce := randomBytes(etm.NonceSize())
+		plaintext := randomBytes(rng.Intn(100))
+		aad := randomBytes(rng.Intn(40))
+
+		sealed := etm.Seal(nil, nonce, plaintext, aad)
+		if len(sealed) > len(plaintext)+etm.Overhead() {
+			t.Fatal("Ciphertext of ", len(sealed), " bytes exceeds the overhead of ", etm.Overhead())
+		}
+		// In place, the plaintext buffer receives the ciphertext
+		buf := append(make([]byte, 0, len(plaintext)+etm.Overhead()), plaintext...)
+		if inPlace := etm.Seal(buf[:0], nonce, buf, aad); !bytes.Equal(inPlace, sealed) {
+			t.Fatal("In-place encryption differs")
+		}
+		opened, err := etm.Open(sealed[:0], nonce, sealed, aad)
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Fatal("Round trip failed with error ", err)
+		}
+	}
+}
+
+// decryptCounter counts the calls to Decrypt of the block cipher
+type decryptCounter struct {
+	cipher.Block
+	calls int
+}
+
+func (d *decryptCounter) Decrypt(dst, src []byte) {
+	d.calls++
+	d.Block.Decrypt(dst, src)
+}
+
+func TestCBCHMACVerifiesBeforeDecrypting(t *testing.T) {
+	counter := &decryptCounter{}
+	etm, err := NewCBCHMAC(func(key []byte) (cipher.Block, error) {
+		block, err := aes.NewCipher(key)
+		counter.Block = block
+		return counter, err
+	}, etmKey(32))
+	check(err)
+	nonce := randomBytes(BlockSize)
+	sealed := etm.Seal(nil, nonce, randomBytes(50), nil)
+
+	tampered := append([]byte(nil), sealed...)
+	tampered[0] ^= 1
+	if _, err := etm.Open(nil, nonce, tampered, nil); err != ErrOpen || counter.calls != 0 {
+		t.Error("Expected ErrOpen without decryption, got ", err, " after ", counter.calls, " block decryptions")
+	}
+
+	// A valid tag over malformed padding fails the same way
+	ciphertext := make([]byte, 2*BlockSize)
+	enc, err := NewCBCEncrypter(counter.Block, nonce)
+	check(err)
+	enc.CryptBlocks(ciphertext, bytes.Repeat([]byte{0xff}, 2*BlockSize))
+	tag := make([]byte, etm.tagSize)
+	etm.tag(tag, nonce, ciphertext, nil)
+	if out, err := etm.Open(nil, nonce, append(ciphertext, tag...), nil); err != ErrOpen || out != nil {
+		t.Error("Expected ErrOpen for malformed padding, got ", err)
+	}
+	if counter.calls == 0 {
+		t.Error("Expected decryption of an authentic ciphertext")
+	}
+}
+
+func TestEtMConstructorErrors(t *testing.T) {
+	for _, keySize := range []int{0, 16, 31, 33, 47, 63, 65} {
+		if _, err := NewCBCHMAC(aes.NewCipher, make([]byte, keySize)); err != ErrEtMKeySize {
+			t.Error("Expected ErrEtMKeySize for ", keySize, " bytes, got ", err)
+		}
+		if _, err := NewCTRHMAC(aes.NewCipher, make([]byte, keySize)); err != ErrEtMKeySize {
+			t.Error("Expected ErrEtMKeySize for ", keySize, " bytes, got ", err)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+/*
+	gcm.go
+
+	Implementation of the Galois/Counter Mode (GCM) of operation, including
+	GHASH, as specified in NIST SP 800-38D. The encryption reuses the 32-bit
+	CTR mode, GHASH multiplies in GF(2^128) without data-dependent branches.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	gcm.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	gcmBlockSize         = 16
+	gcmStandardNonceSize = 12
+	gcmTagSize           = 16
+	gcmMinimumTagSize    = 12
+)
+
+// ErrOpen is returned when an authenticated ciphertext fails verification
+var ErrOpen = errors.New("goaes: message authentication failed")
+
+// ErrBlockSize is returned when a mode requires a 128-bit block cipher
+var ErrBlockSize = errors.New("goaes: mode requires a 128-bit block cipher")
+
+// ErrNonceSize is returned when the nonce size is not supported
+var ErrNonceSize = errors.New("goaes: invalid nonce size")
+
+// ErrTagSize is returned when the tag size is not supported
+var ErrTagSize = errors.New("goaes: invalid tag size")
+
+var _ cipher.AEAD = (*GCM)(nil)
+
+// GCM is the class for the Galois/Counter Mode of operation
+type GCM struct {
+	aes       cipher.Block
+	nonceSize int
+	tagSize   int
+	// The hash subkey H = E(K, 0^128) as two big-endian halves
+	hashKey [2]uint64
+}
+
+// NewGCM is a constructor for the GCM class with the standard
+// 96-bit nonce and 128-bit tag
+func NewGCM(b cipher.Block) (*GCM, error) {
+	return newGCM(b, gcmStandardNonceSize, gcmTagSize)
+}
+
+// NewGCMWithNonceSize is a constructor for the GCM class with a non-standard
+// nonce size, only use it for compatibility with existing ciphertexts
+func NewGCMWithNonceSize(b cipher.Block, size int) (*GCM, error) {
+	return newGCM(b, size, gcmTagSize)
+}
+
+// NewGCMWithTagSize is a constructor for the GCM class with a truncated
+// tag, between 12 and 16 bytes
+func NewGCMWithTagSize(b cipher.Block, tagSize int) (*GCM, error) {
+	return newGCM(b, gcmStandardNonceSize, tagSize)
+}
+
+func newGCM(b cipher.Block, nonceSize, tagSize int) (*GCM, error) {
+	if b == nil {
+		return nil, ErrNilBlock
+	}
+	if b.BlockSize() != gcmBlockSize {
+		return nil, ErrBlockSize
+	}
+	if nonceSize <= 0 {
+		return nil, ErrNonceSize
+	}
+	if tagSize < gcmMinimumTagSize || tagSize > gcmTagSize {
+		return nil, ErrTagSize
+	}
+
+	var key [gcmBlockSize]byte
+	b.Encrypt(key[:], key[:])
+
+	return &GCM{
+		aes:       b,
+		nonceSize: nonceSize,
+		tagSize:   tagSize,
+		hashKey: [2]uint64{
+			binary.BigEndian.Uint64(key[:8]),
+			binary.BigEndian.Uint64(key[8:]),
+		},
+	}, nil
+}
+
+// NonceSize returns the size of the nonce that must be passed to Seal and Open
+func (g *GCM) NonceSize() int { return g.nonceSize }
+
+// Overhead returns the difference between the lengths of a plaintext and its ciphertext
+func (g *GCM) Overhead() int { return g.tagSize }
+
+// Seal encrypts and authenticates plaintext, authenticates additionalData
+// and appends the ciphertext and tag to dst
+func (g *GCM) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != g.nonceSize {
+		panic("goaes: incorrect nonce length given to GCM")
+	}
+	if uint64(len(plaintext)) > (1<<32-2)*gcmBlockSize {
+		panic("goaes: message too large for GCM")
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext)+g.tagSize)
+	if inexactOverlap(out, plaintext) {
+		panic("goaes: invalid buffer overlap")
+	}
+
+	preCounter := g.preCounter(nonce)
+	g.counterCrypt(out, plaintext, preCounter)
+	g.tag(out[len(plaintext):], preCounter, out[:len(plaintext)], additionalData)
+
+	return ret
+}
+
+// Open authenticates ciphertext and additionalData and, only if the tag is
+// valid, decrypts the ciphertext and appends the plaintext to dst
+func (g *GCM) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != g.nonceSize {
+		panic("goaes: incorrect nonce length given to GCM")
+	}
+	if len(ciphertext) < g.tagSize {
+		return nil, ErrOpen
+	}
+	if uint64(len(ciphertext)) > (1<<32-2)*gcmBlockSize+uint64(g.tagSize) {
+		return nil, ErrOpen
+	}
+
+	tag := ciphertext[len(ciphertext)-g.tagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-g.tagSize]
+
+	preCounter := g.preCounter(nonce)
+	expectedTag := make([]byte, g.tagSize)
+	g.tag(expectedTag, preCounter, ciphertext, additionalData)
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	if inexactOverlap(out, ciphertext) {
+		panic("goaes: invalid buffer overlap")
+	}
+
+	// Nothing is decrypted unless the tag is valid
+	if subtle.ConstantTimeCompare(expectedTag, tag) != 1 {
+		return nil, ErrOpen
+	}
+
+	g.counterCrypt(out, ciphertext, preCounter)
+
+	return ret, nil
+}
+
+// preCounter derives the pre-counter block J0 from the nonce
+func (g *GCM) preCounter(nonce []byte) []byte {
+	preCounter := make([]byte, gcmBlockSize)
+	if len(nonce) == gcmStandardNonceSize {
+		copy(preCounter, nonce)
+		preCounter[gcmBlockSize-1] = 1
+		return preCounter
+	}
+
+	// J0 = GHASH(nonce || 0 padding || 0^64 || [len(nonce)]_64)
+	var y [2]uint64
+	g.ghashUpdate(&y, nonce)
+	g.ghashLengths(&y, 0, uint64(len(nonce))*8)
+	binary.BigEndian.PutUint64(preCounter[:8], y[0])
+	binary.BigEndian.PutUint64(preCounter[8:], y[1])
+	return preCounter
+}
+
+// counterCrypt encrypts src into dst with the 32-bit counter starting at inc32(J0)
+func (g *GCM) counterCrypt(dst, src, preCounter []byte) {
+	counter := append([]byte(nil), preCounter...)
+	incCounter(counter[gcmBlockSize-4:])
+
+	ctr, err := NewCTR(g.aes, counter, Counter32)
+	if err != nil {
+		panic(err)
+	}
+	ctr.XORKeyStream(dst, src)
+}
+
+// tag computes the authentication tag E(K, J0) xor GHASH(A, C) into out
+func (g *GCM) tag(out, preCounter, ciphertext, additionalData []byte) {
+	var y [2]uint64
+	g.ghashUpdate(&y, additionalData)
+	g.ghashUpdate(&y, ciphertext)
+	g.ghashLengths(&y, uint64(len(additionalData))*8, uint64(len(ciphertext))*8)
+
+	var s [gcmBlockSize]byte
+	binary.BigEndian.PutUint64(s[:8], y[0])
+	binary.BigEndian.PutUint64(s[8:], y[1])
+
+	var mask [gcmBlockSize]byte
+	g.aes.Encrypt(mask[:], preCounter)
+	xor(out, s[:len(out)], mask[:])
+}
+
+// ghashUpdate absorbs data into y, zero padding the last partial block
+func (g *GCM) ghashUpdate(y *[2]uint64, data []byte) {
+	for len(data) > 0 {
+		var block [gcmBlockSize]byte
+		n := copy(block[:], data)
+		data = data[n:]
+
+		y[0] ^= binary.BigEndian.Uint64(block[:8])
+		y[1] ^= binary.BigEndian.Uint64(block[8:])
+		*y = gfMul(*y, g.hashKey)
+	}
+}
+
+// ghashLengths absorbs the final block of bit lengths into y
+func (g *GCM) ghashLengths(y *[2]uint64, first, second uint64) {
+	y[0] ^= first
+	y[1] ^= second
+	*y = gfMul(*y, g.hashKey)
+}
+
+// gfMul multiplies x and y in GF(2^128) as defined for GHASH, i.e. with
+// the bit-reflected representation and R = 11100001 || 0^120
+// Every iteration performs the same operations regardless of the bits,
+// see Algorithm 1 of NIST SP 800-38D
+func gfMul(x, y [2]uint64) [2]uint64 {
+	var z [2]uint64
+	v := y
+
+	for i := 0; i < 128; i++ {
+		bit := (x[i/64] >> uint(63-i%64)) & 1
+		mask := -bit
+		z[0] ^= v[0] & mask
+		z[1] ^= v[1] & mask
+
+		lsb := v[1] & 1
+		v[1] = v[1]>>1 | v[0]<<63
+		v[0] = v[0]>>1 ^ (0xe100000000000000 & -lsb)
+	}
+
+	return z
+}
+
+// sliceForAppend extends in by n bytes, returning the whole slice and the
+// extension, reusing the capacity of in if possible
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
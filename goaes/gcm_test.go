@@ -0,0 +1,239 @@
+/*
+	gcm_test.go
+
+	GCM test vectors from McGrew D., Viega J. - The Galois/Counter Mode of
+	Operation (GCM), as published by NIST alongside SP 800-38D
+	See: https://csrc.nist.gov/groups/ST/toolkit/BCM/documents/proposedmodes/gcm/gcm-spec.pdf
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	gcm_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	mrand "math/rand"
+	"testing"
+)
+
+type gcmtest struct {
+	name       string
+	key        string
+	nonce      string
+	plaintext  string
+	aad        string
+	ciphertext string
+	tag        string
+}
+
+const (
+	gcmKey = "feffe9928665731c6d6a8f9467308308"
+	// Plaintext of the test cases 3 to 6, 4 to 6 truncate it to 60 bytes
+	gcmPlaintext = "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a72" +
+		"1c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b391aafd255"
+	gcmAAD = "feedfacedeadbeeffeedfacedeadbeefabaddad2"
+)
+
+var gcmtests = []gcmtest{
+	{
+		"Test Case 1",
+		"00000000000000000000000000000000",
+		"000000000000000000000000",
+		"",
+		"",
+		"",
+		"58e2fccefa7e3061367f1d57a4e7455a",
+	},
+	{
+		"Test Case 2",
+		"00000000000000000000000000000000",
+		"000000000000000000000000",
+		"00000000000000000000000000000000",
+		"",
+		"0388dace60b6a392f328c2b971b2fe78",
+		"ab6e47d42cec13bdf53a67b21257bddf",
+	},
+	{
+		"Test Case 3",
+		gcmKey,
+		"cafebabefacedbaddecaf888",
+		gcmPlaintext,
+		"",
+		"42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e" +
+			"21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091473f5985",
+		"4d5c2af327cd64a62cf35abd2ba6fab4",
+	},
+	{
+		"Test Case 4",
+		gcmKey,
+		"cafebabefacedbaddecaf888",
+		gcmPlaintext[:120],
+		gcmAAD,
+		"42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e" +
+			"21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091",
+		"5bc94fbc3221a5db94fae95ae7121a47",
+	},
+	{
+		"Test Case 5",
+		gcmKey,
+		"cafebabefacedbad",
+		gcmPlaintext[:120],
+		gcmAAD,
+		"61353b4c2806934a777ff51fa22a4755699b2a714fcdc6f83766e5f97b6c7423" +
+			"73806900e49f24b22b097544d4896b424989b5e1ebac0f07c23f4598",
+		"3612d2e79e3b0785561be14aaca2fccb",
+	},
+	{
+		"Test Case 6",
+		gcmKey,
+		"9313225df88406e555909c5aff5269aa6a7a9538534f7da1e4c303d2a318a728" +
+			"c3c0c95156809539fcf0e2429a6b525416aedbf5a0de6a57a637b39b",
+		gcmPlaintext[:120],
+		gcmAAD,
+		"8ce24998625615b603a033aca13fb894be9112a5c3a211a8ba262a3cca7e2ca7" +
+			"01e4a9a4fba43c90ccdcb281d48c7c6fd62875d2aca417034c34aee5",
+		"619cc5aefffe0bfa462af43c1699d050",
+	},
+	{
+		"Test Case 16",
+		"feffe9928665731c6d6a8f9467308308feffe9928665731c6d6a8f9467308308",
+		"cafebabefacedbaddecaf888",
+		gcmPlaintext[:120],
+		gcmAAD,
+		"522dc1f099567d07f47f37a32a84427d643a8cdcbfe5c0c97598a2bd2555d1aa" +
+			"8cb08e48590dbb3da7b08b1056828838c5f61e6393ba7a0abcc9f662",
+		"76fc6ece0f4e1768cddf8853bb2d551b",
+	},
+}
+
+func TestGCMVectors(t *testing.T) {
+	for _, test := range gcmtests {
+		block, err := katCipher(decodehex([]byte(test.key)))
+		check(err)
+		nonce := decodehex([]byte(test.nonce))
+		plaintext := decodehex([]byte(test.plaintext))
+		aad := decodehex([]byte(test.aad))
+		expected := decodehex([]byte(test.ciphertext + test.tag))
+
+		gcm, err := NewGCMWithNonceSize(block, len(nonce))
+		check(err)
+		sealed := gcm.Seal(nil, nonce, plaintext, aad)
+		if !bytes.Equal(sealed, expected) {
+			t.Error(test.name, " expected ", string(encodehex(expected)),
+				",got ", string(encodehex(sealed)))
+		}
+
+		opened, err := gcm.Open(nil, nonce, expected, aad)
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Error(test.name, " expected ", string(encodehex(plaintext)),
+				",got ", string(encodehex(opened)), " error ", err)
+		}
+	}
+}
+
+func TestGCMDifferential(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(8))
+	for i := 0; i < differentialRounds; i++ {
+		block := randomBlock(t, rng)
+		nonceSize := []int{12, 12, 8, 16, 60}[rng.Intn(5)]
+		tagSize := 12 + rng.Intn(5)
+		nonce := randomBytes(nonceSize)
+		plaintext := randomBytes(rng.Intn(300))
+		aad := randomBytes(rng.Intn(100))
+
+		var ours *GCM
+		var theirs cipher.AEAD
+		var err error
+		if nonceSize == 12 {
+			ours, err = NewGCMWithTagSize(block, tagSize)
+			check(err)
+			theirs, err = cipher.NewGCMWithTagSize(block, tagSize)
+		} else {
+			ours, err = NewGCMWithNonceSize(block, nonceSize)
+			check(err)
+			theirs, err = cipher.NewGCMWithNonceSize(block, nonceSize)
+		}
+		check(err)
+
+		expected := theirs.Seal(nil, nonce, plaintext, aad)
+		sealed := ours.Seal(nil, nonce, plaintext, aad)
+		if !bytes.Equal(sealed, expected) {
+			t.Fatal("Expected ", string(encodehex(expected)),
+				",got ", string(encodehex(sealed)))
+		}
+		opened, err := ours.Open(nil, nonce, sealed, aad)
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Fatal("Round trip failed with error ", err)
+		}
+	}
+}
+
+func TestGCMTampering(t *testing.T) {
+	block, err := aes.NewCipher(decodehex([]byte(gcmKey)))
+	check(err)
+	gcm, err := NewGCM(block)
+	check(err)
+	nonce := decodehex([]byte("cafebabefacedbaddecaf888"))
+	plaintext := decodehex([]byte(gcmPlaintext))
+	aad := decodehex([]byte(gcmAAD))
+	sealed := gcm.Seal(nil, nonce, plaintext, aad)
+
+	for i := 0; i < len(sealed)*8; i++ {
+		tampered := append([]byte(nil), sealed...)
+		tampered[i/8] ^= 1 << uint(i%8)
+		if out, err := gcm.Open(nil, nonce, tampered, aad); err != ErrOpen || out != nil {
+			t.Fatal("Expected ErrOpen and no output after flipping bit ", i)
+		}
+	}
+
+	otherAAD := append([]byte(nil), aad...)
+	otherAAD[0] ^= 1
+	if _, err := gcm.Open(nil, nonce, sealed, otherAAD); err != ErrOpen {
+		t.Error("Expected ErrOpen for modified additional data, got ", err)
+	}
+	if _, err := gcm.Open(nil, nonce, sealed[:gcm.Overhead()-1], aad); err != ErrOpen {
+		t.Error("Expected ErrOpen for truncated ciphertext, got ", err)
+	}
+}
+
+func TestGCMInPlace(t *testing.T) {
+	block, err := aes.NewCipher(decodehex([]byte(gcmKey)))
+	check(err)
+	gcm, err := NewGCM(block)
+	check(err)
+	nonce := decodehex([]byte("cafebabefacedbaddecaf888"))
+	plaintext := decodehex([]byte(gcmPlaintext))
+
+	buf := make([]byte, len(plaintext), len(plaintext)+gcm.Overhead())
+	copy(buf, plaintext)
+	sealed := gcm.Seal(buf[:0], nonce, buf, nil)
+	opened, err := gcm.Open(sealed[:0], nonce, sealed, nil)
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Error("In-place round trip failed with error ", err)
+	}
+}
+
+func TestGCMConstructorErrors(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	check(err)
+	if _, err := NewGCMWithTagSize(block, 11); err != ErrTagSize {
+		t.Error("Expected ErrTagSize, got ", err)
+	}
+	if _, err := NewGCMWithNonceSize(block, 0); err != ErrNonceSize {
+		t.Error("Expected ErrNonceSize, got ", err)
+	}
+}
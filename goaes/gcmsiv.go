@@ -0,0 +1,253 @@
+/*
+	gcmsiv.go
+
+	Implementation of AES-GCM-SIV as specified in RFC 8452, a nonce
+	misuse-resistant authenticated encryption. Every nonce derives its own
+	POLYVAL and AES keys from the key-generating key, the tag is computed
+	over the plaintext with POLYVAL and serves as the initial counter block of
+	a 32-bit little-endian CTR, so repeating a nonce only reveals whether two
+	messages are equal.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	gcmsiv.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	gcmSIVBlockSize = 16
+	gcmSIVNonceSize = 12
+	gcmSIVTagSize   = 16
+	// gcmSIVMaxLength is the largest plaintext or additional data in bytes
+	gcmSIVMaxLength = 1 << 36
+)
+
+// ErrGCMSIVKeySize is returned when the GCM-SIV key is not 16 or 32 bytes long
+var ErrGCMSIVKeySize = errors.New("goaes: GCM-SIV key must be 16 or 32 bytes")
+
+var _ cipher.AEAD = (*GCMSIV)(nil)
+
+// GCMSIV is the class for the AES-GCM-SIV authenticated encryption
+type GCMSIV struct {
+	cipherFunc func([]byte) (cipher.Block, error)
+	// The key-generating key encrypts the nonce into the message keys
+	keyGen  cipher.Block
+	keySize int
+}
+
+// NewGCMSIV is a constructor for the GCMSIV class, a 16 or 32-byte key
+// selects AEAD_AES_128_GCM_SIV or AEAD_AES_256_GCM_SIV
+// cipherFunc is usually aes.NewCipher, it is called for every message with
+// the derived encryption key
+func NewGCMSIV(cipherFunc func([]byte) (cipher.Block, error), key []byte) (*GCMSIV, error) {
+	if len(key) != 16 && len(key) != 32 {
+		return nil, ErrGCMSIVKeySize
+	}
+	keyGen, err := cipherFunc(key)
+	if err != nil {
+		return nil, err
+	}
+	if keyGen.BlockSize() != gcmSIVBlockSize {
+		return nil, ErrBlockSize
+	}
+	return &GCMSIV{cipherFunc: cipherFunc, keyGen: keyGen, keySize: len(key)}, nil
+}
+
+// NonceSize returns the size of the nonce that must be passed to Seal and Open
+func (g *GCMSIV) NonceSize() int { return gcmSIVNonceSize }
+
+// Overhead returns the difference between the lengths of a plaintext and its ciphertext
+func (g *GCMSIV) Overhead() int { return gcmSIVTagSize }
+
+// Seal encrypts and authenticates plaintext, authenticates additionalData
+// and appends the ciphertext and tag to dst
+func (g *GCMSIV) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != gcmSIVNonceSize {
+		panic("goaes: incorrect nonce length given to GCM-SIV")
+	}
+	if uint64(len(plaintext)) > gcmSIVMaxLength || uint64(len(additionalData)) > gcmSIVMaxLength {
+		panic("goaes: message too large for GCM-SIV")
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext)+gcmSIVTagSize)
+	if inexactOverlap(out, plaintext) {
+		panic("goaes: invalid buffer overlap")
+	}
+
+	// The tag depends on the whole plaintext, so it is computed before
+	// the plaintext may be overwritten
+	authKey, enc := g.deriveKeys(nonce)
+	tag := g.tag(authKey, enc, nonce, plaintext, additionalData)
+	g.counterCrypt(enc, out, plaintext, tag[:])
+	copy(out[len(plaintext):], tag[:])
+
+	return ret
+}
+
+// Open decrypts ciphertext and, only if the tag over the plaintext and
+// additionalData is valid, appends the plaintext to dst
+func (g *GCMSIV) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != gcmSIVNonceSize {
+		panic("goaes: incorrect nonce length given to GCM-SIV")
+	}
+	if len(ciphertext) < gcmSIVTagSize {
+		return nil, ErrOpen
+	}
+	if uint64(len(ciphertext)) > gcmSIVMaxLength+gcmSIVTagSize || uint64(len(additionalData)) > gcmSIVMaxLength {
+		return nil, ErrOpen
+	}
+
+	var tag [gcmSIVTagSize]byte
+	copy(tag[:], ciphertext[len(ciphertext)-gcmSIVTagSize:])
+	ciphertext = ciphertext[:len(ciphertext)-gcmSIVTagSize]
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	if inexactOverlap(out, ciphertext) {
+		panic("goaes: invalid buffer overlap")
+	}
+
+	// The tag is computed over the plaintext, which is wiped if it does
+	// not match
+	authKey, enc := g.deriveKeys(nonce)
+	g.counterCrypt(enc, out, ciphertext, tag[:])
+	expectedTag := g.tag(authKey, enc, nonce, out, additionalData)
+	if subtle.ConstantTimeCompare(expectedTag[:], tag[:]) != 1 {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, ErrOpen
+	}
+
+	return ret, nil
+}
+
+// deriveKeys returns the POLYVAL key and the AES cipher of the nonce, the
+// first halves of the encryptions of a little-endian counter and the nonce,
+// see RFC 8452 section 4
+func (g *GCMSIV) deriveKeys(nonce []byte) ([gcmSIVBlockSize]byte, cipher.Block) {
+	var in, out [gcmSIVBlockSize]byte
+	copy(in[4:], nonce)
+	keys := make([]byte, gcmSIVBlockSize+g.keySize)
+	for i := 0; i < len(keys)/8; i++ {
+		binary.LittleEndian.PutUint32(in[:4], uint32(i))
+		g.keyGen.Encrypt(out[:], in[:])
+		copy(keys[8*i:], out[:8])
+	}
+
+	var authKey [gcmSIVBlockSize]byte
+	copy(authKey[:], keys)
+	enc, err := g.cipherFunc(keys[gcmSIVBlockSize:])
+	if err != nil {
+		panic(err)
+	}
+	return authKey, enc
+}
+
+// tag computes the tag, the encryption of the POLYVAL of the additional
+// data, the plaintext and their bit lengths, XORed with the nonce and with
+// the most significant bit of the last byte cleared
+func (g *GCMSIV) tag(authKey [gcmSIVBlockSize]byte, enc cipher.Block, nonce, plaintext, additionalData []byte) [gcmSIVTagSize]byte {
+	var lengths [gcmSIVBlockSize]byte
+	binary.LittleEndian.PutUint64(lengths[:8], uint64(len(additionalData))*8)
+	binary.LittleEndian.PutUint64(lengths[8:], uint64(len(plaintext))*8)
+
+	p := newPolyval(authKey)
+	p.update(additionalData)
+	p.update(plaintext)
+	p.update(lengths[:])
+	s := p.sum()
+
+	xor(s[:gcmSIVNonceSize], s[:gcmSIVNonceSize], nonce)
+	s[gcmSIVBlockSize-1] &= 0x7f
+	enc.Encrypt(s[:], s[:])
+	return s
+}
+
+// counterCrypt encrypts src into dst with CTR, the initial counter block is
+// the tag with the most significant bit of the last byte set, and only its
+// first four bytes count as a little-endian counter, which wraps around
+func (g *GCMSIV) counterCrypt(enc cipher.Block, dst, src, tag []byte) {
+	var counter [gcmSIVBlockSize]byte
+	copy(counter[:], tag)
+	counter[gcmSIVBlockSize-1] |= 0x80
+	ctr := binary.LittleEndian.Uint32(counter[:4])
+
+	// The counter blocks are encrypted in batches, which a multiBlock
+	// cipher encrypts together
+	ecb, err := NewECBEncrypter(enc)
+	if err != nil {
+		panic(err)
+	}
+	keyStream := make([]byte, ctrBatchBlocks*gcmSIVBlockSize)
+	for i := 0; i < len(src); i += len(keyStream) {
+		n := len(keyStream)
+		if n > len(src)-i {
+			n = len(src) - i
+		}
+		blocks := (n + gcmSIVBlockSize - 1) / gcmSIVBlockSize * gcmSIVBlockSize
+		for j := 0; j < blocks; j += gcmSIVBlockSize {
+			binary.LittleEndian.PutUint32(counter[:4], ctr)
+			copy(keyStream[j:], counter[:])
+			ctr++
+		}
+		ecb.CryptBlocks(keyStream[:blocks], keyStream[:blocks])
+		xor(dst[i:i+n], src[i:i+n], keyStream)
+	}
+}
+
+// polyval is the POLYVAL universal hash of RFC 8452, computed with the
+// GHASH multiplication of gcm.go, as POLYVAL(H, X) is the byte reversal of
+// GHASH(mulX_GHASH(ByteReverse(H)), ByteReverse(X)), see Appendix A
+type polyval struct {
+	hashKey [2]uint64
+	y       [2]uint64
+}
+
+func newPolyval(h [gcmSIVBlockSize]byte) *polyval {
+	// ByteReverse(H) in the big-endian words of gfMul, multiplied by x,
+	// which is a right shift in the bit-reflected representation of GHASH
+	v := [2]uint64{binary.LittleEndian.Uint64(h[8:]), binary.LittleEndian.Uint64(h[:8])}
+	lsb := v[1] & 1
+	v[1] = v[1]>>1 | v[0]<<63
+	v[0] = v[0]>>1 ^ (0xe100000000000000 & -lsb)
+	return &polyval{hashKey: v}
+}
+
+// update absorbs data, zero padding the last partial block
+func (p *polyval) update(data []byte) {
+	for len(data) > 0 {
+		var block [gcmSIVBlockSize]byte
+		n := copy(block[:], data)
+		data = data[n:]
+
+		p.y[0] ^= binary.LittleEndian.Uint64(block[8:])
+		p.y[1] ^= binary.LittleEndian.Uint64(block[:8])
+		p.y = gfMul(p.y, p.hashKey)
+	}
+}
+
+// sum returns the POLYVAL of the data absorbed so far
+func (p *polyval) sum() [gcmSIVBlockSize]byte {
+	var s [gcmSIVBlockSize]byte
+	binary.LittleEndian.PutUint64(s[:8], p.y[1])
+	binary.LittleEndian.PutUint64(s[8:], p.y[0])
+	return s
+}
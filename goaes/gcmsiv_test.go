@@ -0,0 +1,669 @@
+/*
+	gcmsiv_test.go
+
+	AES-GCM-SIV test vectors from RFC 8452 Appendix A and C and tests of
+	its nonce misuse resistance
+	See: https://tools.ietf.org/html/rfc8452
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	gcmsiv_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	mrand "math/rand"
+	"testing"
+)
+
+// RFC 8452 Appendix C, in the layout of the GCM vectors, C.3 checks that
+// the 32-bit counter wraps around
+var gcmSIVTests = []gcmtest{
+	{
+		"C.1 AES-128 #1",
+		"01000000000000000000000000000000",
+		"030000000000000000000000",
+		"",
+		"",
+		"",
+		"dc20e2d83f25705bb49e439eca56de25",
+	},
+	{
+		"C.1 AES-128 #2",
+		"01000000000000000000000000000000",
+		"030000000000000000000000",
+		"0100000000000000",
+		"",
+		"b5d839330ac7b786",
+		"578782fff6013b815b287c22493a364c",
+	},
+	{
+		"C.1 AES-128 #3",
+		"01000000000000000000000000000000",
+		"030000000000000000000000",
+		"010000000000000000000000",
+		"",
+		"7323ea61d05932260047d942",
+		"a4978db357391a0bc4fdec8b0d106639",
+	},
+	{
+		"C.1 AES-128 #4",
+		"01000000000000000000000000000000",
+		"030000000000000000000000",
+		"01000000000000000000000000000000",
+		"",
+		"743f7c8077ab25f8624e2e948579cf77",
+		"303aaf90f6fe21199c6068577437a0c4",
+	},
+	{
+		"C.1 AES-128 #5",
+		"01000000000000000000000000000000",
+		"030000000000000000000000",
+		"0100000000000000000000000000000002000000000000000000000000000000",
+		"",
+		"84e07e62ba83a6585417245d7ec413a9fe427d6315c09b57ce45f2e3936a9445",
+		"1a8e45dcd4578c667cd86847bf6155ff",
+	},
+	{
+		"C.1 AES-128 #6",
+		"01000000000000000000000000000000",
+		"030000000000000000000000",
+		"0100000000000000000000000000000002000000000000000000000000000000" +
+			"03000000000000000000000000000000",
+		"",
+		"3fd24ce1f5a67b75bf2351f181a475c7b800a5b4d3dcf70106b1eea82fa1d64d" +
+			"f42bf7226122fa92e17a40eeaac1201b",
+		"5e6e311dbf395d35b0fe39c2714388f8",
+	},
+	{
+		"C.1 AES-128 #7",
+		"01000000000000000000000000000000",
+		"030000000000000000000000",
+		"0100000000000000000000000000000002000000000000000000000000000000" +
+			"0300000000000000000000000000000004000000000000000000000000000000",
+		"",
+		"2433668f1058190f6d43e360f4f35cd8e475127cfca7028ea8ab5c20f7ab2af0" +
+			"2516a2bdcbc08d521be37ff28c152bba36697f25b4cd169c6590d1dd39566d3f",
+		"8a263dd317aa88d56bdf3936dba75bb8",
+	},
+	{
+		"C.1 AES-128 #8",
+		"01000000000000000000000000000000",
+		"030000000000000000000000",
+		"0200000000000000",
+		"01",
+		"1e6daba35669f427",
+		"3b0a1a2560969cdf790d99759abd1508",
+	},
+	{
+		"C.1 AES-128 #9",
+		"01000000000000000000000000000000",
+		"030000000000000000000000",
+		"020000000000000000000000",
+		"01",
+		"296c7889fd99f41917f44620",
+		"08299c5102745aaa3a0c469fad9e075a",
+	},
+	{
+		"C.1 AES-128 #10",
+		"01000000000000000000000000000000",
+		"030000000000000000000000",
+		"02000000000000000000000000000000",
+		"01",
+		"e2b0c5da79a901c1745f700525cb335b",
+		"8f8936ec039e4e4bb97ebd8c4457441f",
+	},
+	{
+		"C.1 AES-128 #11",
+		"01000000000000000000000000000000",
+		"030000000000000000000000",
+		"0200000000000000000000000000000003000000000000000000000000000000",
+		"01",
+		"620048ef3c1e73e57e02bb8562c416a319e73e4caac8e96a1ecb2933145a1d71",
+		"e6af6a7f87287da059a71684ed3498e1",
+	},
+	{
+		"C.1 AES-128 #12",
+		"01000000000000000000000000000000",
+		"030000000000000000000000",
+		"0200000000000000000000000000000003000000000000000000000000000000" +
+			"04000000000000000000000000000000",
+		"01",
+		"50c8303ea93925d64090d07bd109dfd9515a5a33431019c17d93465999a8b005" +
+			"3201d723120a8562b838cdff25bf9d1e",
+		"6a8cc3865f76897c2e4b245cf31c51f2",
+	},
+	{
+		"C.1 AES-128 #13",
+		"01000000000000000000000000000000",
+		"030000000000000000000000",
+		"0200000000000000000000000000000003000000000000000000000000000000" +
+			"0400000000000000000000000000000005000000000000000000000000000000",
+		"01",
+		"2f5c64059db55ee0fb847ed513003746aca4e61c711b5de2e7a77ffd02da42fe" +
+			"ec601910d3467bb8b36ebbaebce5fba30d36c95f48a3e7980f0e7ac299332a80",
+		"cdc46ae475563de037001ef84ae21744",
+	},
+	{
+		"C.1 AES-128 #14",
+		"01000000000000000000000000000000",
+		"030000000000000000000000",
+		"02000000",
+		"010000000000000000000000",
+		"a8fe3e87",
+		"07eb1f84fb28f8cb73de8e99e2f48a14",
+	},
+	{
+		"C.1 AES-128 #15",
+		"01000000000000000000000000000000",
+		"030000000000000000000000",
+		"0300000000000000000000000000000004000000",
+		"010000000000000000000000000000000200",
+		"6bb0fecf5ded9b77f902c7d5da236a4391dd0297",
+		"24afc9805e976f451e6d87f6fe106514",
+	},
+	{
+		"C.1 AES-128 #16",
+		"01000000000000000000000000000000",
+		"030000000000000000000000",
+		"030000000000000000000000000000000400",
+		"0100000000000000000000000000000002000000",
+		"44d0aaf6fb2f1f34add5e8064e83e12a2ada",
+		"bff9b2ef00fb47920cc72a0c0f13b9fd",
+	},
+	{
+		"C.1 AES-128 #17",
+		"e66021d5eb8e4f4066d4adb9c33560e4",
+		"f46e44bb3da0015c94f70887",
+		"",
+		"",
+		"",
+		"a4194b79071b01a87d65f706e3949578",
+	},
+	{
+		"C.1 AES-128 #18",
+		"36864200e0eaf5284d884a0e77d31646",
+		"bae8e37fc83441b16034566b",
+		"7a806c",
+		"46bb91c3c5",
+		"af60eb",
+		"711bd85bc1e4d3e0a462e074eea428a8",
+	},
+	{
+		"C.1 AES-128 #19",
+		"aedb64a6c590bc84d1a5e269e4b47801",
+		"afc0577e34699b9e671fdd4f",
+		"bdc66f146545",
+		"fc880c94a95198874296",
+		"bb93a3e34d3c",
+		"d6a9c45545cfc11f03ad743dba20f966",
+	},
+	{
+		"C.1 AES-128 #20",
+		"d5cc1fd161320b6920ce07787f86743b",
+		"275d1ab32f6d1f0434d8848c",
+		"1177441f195495860f",
+		"046787f3ea22c127aaf195d1894728",
+		"4f37281f7ad12949d0",
+		"1d02fd0cd174c84fc5dae2f60f52fd2b",
+	},
+	{
+		"C.1 AES-128 #21",
+		"b3fed1473c528b8426a582995929a149",
+		"9e9ad8780c8d63d0ab4149c0",
+		"9f572c614b4745914474e7c7",
+		"c9882e5386fd9f92ec489c8fde2be2cf97e74e93",
+		"f54673c5ddf710c745641c8b",
+		"c1dc2f871fb7561da1286e655e24b7b0",
+	},
+	{
+		"C.1 AES-128 #22",
+		"2d4ed87da44102952ef94b02b805249b",
+		"ac80e6f61455bfac8308a2d4",
+		"0d8c8451178082355c9e940fea2f58",
+		"2950a70d5a1db2316fd568378da107b52b0da55210cc1c1b0a",
+		"c9ff545e07b88a015f05b274540aa1",
+		"83b3449b9f39552de99dc214a1190b0b",
+	},
+	{
+		"C.1 AES-128 #23",
+		"bde3b2f204d1e9f8b06bc47f9745b3d1",
+		"ae06556fb6aa7890bebc18fe",
+		"6b3db4da3d57aa94842b9803a96e07fb6de7",
+		"1860f762ebfbd08284e421702de0de18baa9c9596291b08466f37de21c7f",
+		"6298b296e24e8cc35dce0bed484b7f30d580",
+		"3e377094f04709f64d7b985310a4db84",
+	},
+	{
+		"C.1 AES-128 #24",
+		"f901cfe8a69615a93fdf7a98cad48179",
+		"6245709fb18853f68d833640",
+		"e42a3c02c25b64869e146d7b233987bddfc240871d",
+		"7576f7028ec6eb5ea7e298342a94d4b202b370ef9768ec6561c4fe6b7e7296fa" +
+			"859c21",
+		"391cc328d484a4f46406181bcd62efd9b3ee197d05",
+		"2d15506c84a9edd65e13e9d24a2a6e70",
+	},
+	{
+		"C.2 AES-256 #1",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"030000000000000000000000",
+		"",
+		"",
+		"",
+		"07f5f4169bbf55a8400cd47ea6fd400f",
+	},
+	{
+		"C.2 AES-256 #2",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"030000000000000000000000",
+		"0100000000000000",
+		"",
+		"c2ef328e5c71c83b",
+		"843122130f7364b761e0b97427e3df28",
+	},
+	{
+		"C.2 AES-256 #3",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"030000000000000000000000",
+		"010000000000000000000000",
+		"",
+		"9aab2aeb3faa0a34aea8e2b1",
+		"8ca50da9ae6559e48fd10f6e5c9ca17e",
+	},
+	{
+		"C.2 AES-256 #4",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"030000000000000000000000",
+		"01000000000000000000000000000000",
+		"",
+		"85a01b63025ba19b7fd3ddfc033b3e76",
+		"c9eac6fa700942702e90862383c6c366",
+	},
+	{
+		"C.2 AES-256 #5",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"030000000000000000000000",
+		"0100000000000000000000000000000002000000000000000000000000000000",
+		"",
+		"4a6a9db4c8c6549201b9edb53006cba821ec9cf850948a7c86c68ac7539d027f",
+		"e819e63abcd020b006a976397632eb5d",
+	},
+	{
+		"C.2 AES-256 #6",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"030000000000000000000000",
+		"0100000000000000000000000000000002000000000000000000000000000000" +
+			"03000000000000000000000000000000",
+		"",
+		"c00d121893a9fa603f48ccc1ca3c57ce7499245ea0046db16c53c7c66fe717e3" +
+			"9cf6c748837b61f6ee3adcee17534ed5",
+		"790bc96880a99ba804bd12c0e6a22cc4",
+	},
+	{
+		"C.2 AES-256 #7",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"030000000000000000000000",
+		"0100000000000000000000000000000002000000000000000000000000000000" +
+			"0300000000000000000000000000000004000000000000000000000000000000",
+		"",
+		"c2d5160a1f8683834910acdafc41fbb1632d4a353e8b905ec9a5499ac34f96c7" +
+			"e1049eb080883891a4db8caaa1f99dd004d80487540735234e3744512c6f90ce",
+		"112864c269fc0d9d88c61fa47e39aa08",
+	},
+	{
+		"C.2 AES-256 #8",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"030000000000000000000000",
+		"0200000000000000",
+		"01",
+		"1de22967237a8132",
+		"91213f267e3b452f02d01ae33e4ec854",
+	},
+	{
+		"C.2 AES-256 #9",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"030000000000000000000000",
+		"020000000000000000000000",
+		"01",
+		"163d6f9cc1b346cd453a2e4c",
+		"c1a4a19ae800941ccdc57cc8413c277f",
+	},
+	{
+		"C.2 AES-256 #10",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"030000000000000000000000",
+		"02000000000000000000000000000000",
+		"01",
+		"c91545823cc24f17dbb0e9e807d5ec17",
+		"b292d28ff61189e8e49f3875ef91aff7",
+	},
+	{
+		"C.2 AES-256 #11",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"030000000000000000000000",
+		"0200000000000000000000000000000003000000000000000000000000000000",
+		"01",
+		"07dad364bfc2b9da89116d7bef6daaaf6f255510aa654f920ac81b94e8bad365",
+		"aea1bad12702e1965604374aab96dbbc",
+	},
+	{
+		"C.2 AES-256 #12",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"030000000000000000000000",
+		"0200000000000000000000000000000003000000000000000000000000000000" +
+			"04000000000000000000000000000000",
+		"01",
+		"c67a1f0f567a5198aa1fcc8e3f21314336f7f51ca8b1af61feac35a86416fa47" +
+			"fbca3b5f749cdf564527f2314f42fe25",
+		"03332742b228c647173616cfd44c54eb",
+	},
+	{
+		"C.2 AES-256 #13",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"030000000000000000000000",
+		"0200000000000000000000000000000003000000000000000000000000000000" +
+			"0400000000000000000000000000000005000000000000000000000000000000",
+		"01",
+		"67fd45e126bfb9a79930c43aad2d36967d3f0e4d217c1e551f59727870beefc9" +
+			"8cb933a8fce9de887b1e40799988db1fc3f91880ed405b2dd298318858467c89",
+		"5bde0285037c5de81e5b570a049b62a0",
+	},
+	{
+		"C.2 AES-256 #14",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"030000000000000000000000",
+		"02000000",
+		"010000000000000000000000",
+		"22b3f4cd",
+		"1835e517741dfddccfa07fa4661b74cf",
+	},
+	{
+		"C.2 AES-256 #15",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"030000000000000000000000",
+		"0300000000000000000000000000000004000000",
+		"010000000000000000000000000000000200",
+		"43dd0163cdb48f9fe3212bf61b201976067f342b",
+		"b879ad976d8242acc188ab59cabfe307",
+	},
+	{
+		"C.2 AES-256 #16",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"030000000000000000000000",
+		"030000000000000000000000000000000400",
+		"0100000000000000000000000000000002000000",
+		"462401724b5ce6588d5a54aae5375513a075",
+		"cfcdf5042112aa29685c912fc2056543",
+	},
+	{
+		"C.2 AES-256 #17",
+		"e66021d5eb8e4f4066d4adb9c33560e4f46e44bb3da0015c94f7088736864200",
+		"e0eaf5284d884a0e77d31646",
+		"",
+		"",
+		"",
+		"169fbb2fbf389a995f6390af22228a62",
+	},
+	{
+		"C.2 AES-256 #18",
+		"bae8e37fc83441b16034566b7a806c46bb91c3c5aedb64a6c590bc84d1a5e269",
+		"e4b47801afc0577e34699b9e",
+		"671fdd",
+		"4fbdc66f14",
+		"0eaccb",
+		"93da9bb81333aee0c785b240d319719d",
+	},
+	{
+		"C.2 AES-256 #19",
+		"6545fc880c94a95198874296d5cc1fd161320b6920ce07787f86743b275d1ab3",
+		"2f6d1f0434d8848c1177441f",
+		"195495860f04",
+		"6787f3ea22c127aaf195",
+		"a254dad4f3f9",
+		"6b62b84dc40c84636a5ec12020ec8c2c",
+	},
+	{
+		"C.2 AES-256 #20",
+		"d1894728b3fed1473c528b8426a582995929a1499e9ad8780c8d63d0ab4149c0",
+		"9f572c614b4745914474e7c7",
+		"c9882e5386fd9f92ec",
+		"489c8fde2be2cf97e74e932d4ed87d",
+		"0df9e308678244c44b",
+		"c0fd3dc6628dfe55ebb0b9fb2295c8c2",
+	},
+	{
+		"C.2 AES-256 #21",
+		"a44102952ef94b02b805249bac80e6f61455bfac8308a2d40d8c845117808235",
+		"5c9e940fea2f582950a70d5a",
+		"1db2316fd568378da107b52b",
+		"0da55210cc1c1b0abde3b2f204d1e9f8b06bc47f",
+		"8dbeb9f7255bf5769dd56692",
+		"404099c2587f64979f21826706d497d5",
+	},
+	{
+		"C.2 AES-256 #22",
+		"9745b3d1ae06556fb6aa7890bebc18fe6b3db4da3d57aa94842b9803a96e07fb",
+		"6de71860f762ebfbd08284e4",
+		"21702de0de18baa9c9596291b08466",
+		"f37de21c7ff901cfe8a69615a93fdf7a98cad481796245709f",
+		"793576dfa5c0f88729a7ed3c2f1bff",
+		"b3080d28f6ebb5d3648ce97bd5ba67fd",
+	},
+	{
+		"C.2 AES-256 #23",
+		"b18853f68d833640e42a3c02c25b64869e146d7b233987bddfc240871d7576f7",
+		"028ec6eb5ea7e298342a94d4",
+		"b202b370ef9768ec6561c4fe6b7e7296fa85",
+		"9c2159058b1f0fe91433a5bdc20e214eab7fecef4454a10ef0657df21ac7",
+		"857e16a64915a787637687db4a9519635cdd",
+		"454fc2a154fea91f8363a39fec7d0a49",
+	},
+	{
+		"C.2 AES-256 #24",
+		"3c535de192eaed3822a2fbbe2ca9dfc88255e14a661b8aa82cc54236093bbc23",
+		"688089e55540db1872504e1c",
+		"ced532ce4159b035277d4dfbb7db62968b13cd4eec",
+		"734320ccc9d9bbbb19cb81b2af4ecbc3e72834321f7aa0f70b7282b4f33df23f" +
+			"167541",
+		"626660c26ea6612fb17ad91e8e767639edd6c9faee",
+		"9d6c7029675b89eaf4ba1ded1a286594",
+	},
+	{
+		"C.3 counter wrap #1",
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"000000000000000000000000",
+		"000000000000000000000000000000004db923dc793ee6497c76dcc03a98e108",
+		"",
+		"f3f80f2cf0cb2dd9c5984fcda908456cc537703b5ba70324a6793a7bf218d3ea",
+		"ffffffff000000000000000000000000",
+	},
+	{
+		"C.3 counter wrap #2",
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"000000000000000000000000",
+		"eb3640277c7ffd1303c7a542d02d3e4c0000000000000000",
+		"",
+		"18ce4f0b8cb4d0cac65fea8f79257b20888e53e72299e56d",
+		"ffffffff000000000000000000000000",
+	},
+}
+
+func TestGCMSIVVectors(t *testing.T) {
+	for _, test := range gcmSIVTests {
+		aead, err := NewGCMSIV(katCipher, decodehex([]byte(test.key)))
+		check(err)
+		nonce := decodehex([]byte(test.nonce))
+		plaintext := decodehex([]byte(test.plaintext))
+		aad := decodehex([]byte(test.aad))
+		expected := decodehex([]byte(test.ciphertext + test.tag))
+
+		sealed := aead.Seal(nil, nonce, plaintext, aad)
+		if !bytes.Equal(sealed, expected) {
+			t.Error(test.name, " expected ", string(encodehex(expected)),
+				",got ", string(encodehex(sealed)))
+		}
+
+		opened, err := aead.Open(nil, nonce, expected, aad)
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Error(test.name, " expected ", string(encodehex(plaintext)),
+				",got ", string(encodehex(opened)), " error ", err)
+		}
+	}
+}
+
+// TestPolyval checks the POLYVAL example of RFC 8452 Appendix A
+func TestPolyval(t *testing.T) {
+	var h [gcmSIVBlockSize]byte
+	copy(h[:], decodehex([]byte("25629347589242761d31f826ba4b757b")))
+	p := newPolyval(h)
+	p.update(decodehex([]byte("4f4f95668c83dfb6401762bb2d01a262")))
+	p.update(decodehex([]byte("d1a24ddd2721d006bbe45f20d3c9f362")))
+	expected := decodehex([]byte("f7a3b47b846119fae5b7866cf5e5b77e"))
+	if s := p.sum(); !bytes.Equal(s[:], expected) {
+		t.Error("Expected ", string(encodehex(expected)), ",got ", string(encodehex(s[:])))
+	}
+}
+
+// TestGCMSIVNonceReuse encrypts pairs of messages under the same key and
+// nonce with GCM and GCM-SIV
+// Equal messages give equal ciphertexts, but unlike GCM, whose ciphertexts
+// differ exactly where the messages do, GCM-SIV reveals nothing else about
+// two different messages, not even a common prefix
+func TestGCMSIVNonceReuse(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(11))
+	for i := 0; i < differentialRounds; i++ {
+		key := randomBytes([]int{16, 32}[rng.Intn(2)])
+		block, err := aes.NewCipher(key)
+		check(err)
+		gcm, err := NewGCM(block)
+		check(err)
+		gcmSIV, err := NewGCMSIV(aes.NewCipher, key)
+		check(err)
+		nonce := randomBytes(gcmSIVNonceSize)
+		aad := randomBytes(rng.Intn(40))
+
+		// A message and a copy with one bit flipped
+		m1 := randomBytes(32 + rng.Intn(300))
+		m2 := append([]byte(nil), m1...)
+		bit := rng.Intn(len(m2) * 8)
+		m2[bit/8] ^= 1 << uint(bit%8)
+		diff := make([]byte, len(m1))
+		xor(diff, m1, m2)
+
+		c1 := gcm.Seal(nil, nonce, m1, aad)[:len(m1)]
+		c2 := gcm.Seal(nil, nonce, m2, aad)[:len(m2)]
+		xor(c2, c1, c2)
+		if !bytes.Equal(c2, diff) {
+			t.Fatal("Expected GCM to reveal the difference of the messages")
+		}
+
+		s1 := gcmSIV.Seal(nil, nonce, m1, aad)
+		if again := gcmSIV.Seal(nil, nonce, m1, aad); !bytes.Equal(again, s1) {
+			t.Fatal("Expected equal ciphertexts for equal messages")
+		}
+		s2 := gcmSIV.Seal(nil, nonce, m2, aad)
+		if bytes.Equal(s1[len(m1):], s2[len(m2):]) {
+			t.Fatal("Expected different tags for different messages")
+		}
+		// The ciphertexts are unrelated, their difference matches the
+		// difference of the messages only by chance, in about 1 of 256 bytes
+		xor(s2, s1, s2)
+		matches := 0
+		for j := range diff {
+			if s2[j] == diff[j] {
+				matches++
+			}
+		}
+		if matches > len(diff)/16+4 {
+			t.Fatal("Expected unrelated ciphertexts, ", matches, " of ", len(diff),
+				" bytes differ like the messages")
+		}
+
+		// The additional data changes the ciphertext too
+		other := append(append([]byte(nil), aad...), 0)
+		if s3 := gcmSIV.Seal(nil, nonce, m1, other); bytes.Equal(s3[:16], s1[:16]) {
+			t.Fatal("Expected a different ciphertext for different additional data")
+		}
+	}
+}
+
+func TestGCMSIVTampering(t *testing.T) {
+	aead, err := NewGCMSIV(aes.NewCipher, randomBytes(16))
+	check(err)
+	nonce := randomBytes(gcmSIVNonceSize)
+	aad := randomBytes(20)
+	sealed := aead.Seal(nil, nonce, randomBytes(40), aad)
+
+	for i := 0; i < len(sealed)*8; i++ {
+		tampered := append([]byte(nil), sealed...)
+		tampered[i/8] ^= 1 << uint(i%8)
+		if out, err := aead.Open(nil, nonce, tampered, aad); err != ErrOpen || out != nil {
+			t.Fatal("Expected ErrOpen and no output after flipping bit ", i)
+		}
+	}
+
+	// A failed in-place decryption leaves no plaintext behind
+	tampered := append([]byte(nil), sealed...)
+	tampered[0] ^= 1
+	if _, err := aead.Open(tampered[:0], nonce, tampered, aad); err != ErrOpen {
+		t.Error("Expected ErrOpen, got ", err)
+	}
+	if !bytes.Equal(tampered[:len(sealed)-aead.Overhead()], make([]byte, len(sealed)-aead.Overhead())) {
+		t.Error("Expected the decrypted plaintext to be wiped")
+	}
+
+	otherNonce := append([]byte(nil), nonce...)
+	otherNonce[0] ^= 1
+	if _, err := aead.Open(nil, otherNonce, sealed, aad); err != ErrOpen {
+		t.Error("Expected ErrOpen for another nonce, got ", err)
+	}
+	if _, err := aead.Open(nil, nonce, sealed, aad[1:]); err != ErrOpen {
+		t.Error("Expected ErrOpen for modified additional data, got ", err)
+	}
+	if _, err := aead.Open(nil, nonce, sealed[:aead.Overhead()-1], aad); err != ErrOpen {
+		t.Error("Expected ErrOpen for truncated ciphertext, got ", err)
+	}
+}
+
+func TestGCMSIVRoundTrip(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(12))
+	for i := 0; i < differentialRounds; i++ {
+		aead, err := NewGCMSIV(aes.NewCipher, randomBytes([]int{16, 32}[rng.Intn(2)]))
+		check(err)
+		nonce := randomBytes(gcmSIVNonceSize)
+		plaintext := randomBytes(rng.Intn(1200))
+		aad := randomBytes(rng.Intn(40))
+
+		sealed := aead.Seal(nil, nonce, plaintext, aad)
+		buf := append(make([]byte, 0, len(plaintext)+aead.Overhead()), plaintext...)
+		if inPlace := aead.Seal(buf[:0], nonce, buf, aad); !bytes.Equal(inPlace, sealed) {
+			t.Fatal("In-place encryption differs")
+		}
+		opened, err := aead.Open(sealed[:0], nonce, sealed, aad)
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Fatal("Round trip failed with error ", err)
+		}
+	}
+}
+
+func TestGCMSIVConstructorErrors(t *testing.T) {
+	for _, keySize := range []int{0, 15, 24, 33, 64} {
+		if _, err := NewGCMSIV(aes.NewCipher, make([]byte, keySize)); err != ErrGCMSIVKeySize {
+			t.Error("Expected ErrGCMSIVKeySize for ", keySize, " bytes, got ", err)
+		}
+	}
+}
@@ -1,7 +1,21 @@
 /*
 	parse_rsp.go
 
-	Script for parsing .rsp known answer test files to a .json file
+	Script for parsing .rsp known answer test files, and the .txt files of
+	the KW and KWP tests, to a .json file
+
+	Lines of the form "NAME = value" are parsed regardless of spacing. Every
+	block of lines starting with COUNT (or Count) is a test, assignments
+	outside of tests, e.g. "Tlen = 16", "[Alen = 0]" or a Key shared by the
+	following tests, are copied into each following test. Lines without a
+	value, e.g. FAIL, are stored with an empty value.
+
+	The ECB, CBC, CFB1, CFB8, CFB128 and OFB files are additionally split
+	into encrypt and decrypt tests, CFB1 plaintexts and ciphertexts are bit
+	strings and are kept as such. All other files, e.g. the CCM ones, are
+	only stored as a list of tests. In the KW and KWP files the plaintext
+	length is a section, e.g. "[PLAINTEXT LENGTH = 128]", and a test that
+	must fail to unwrap has a FAIL line instead of its plaintext P.
 
 	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
 	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
@@ -27,7 +41,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"strconv"
+	"path/filepath"
 	s "strings"
 )
 
@@ -45,8 +59,9 @@ type teststruct struct {
 }
 
 type testfile struct {
-	Encrypt []teststruct `json:"encrypt"`
-	Decrypt []teststruct `json:"decrypt"`
+	Encrypt []teststruct        `json:"encrypt,omitempty"`
+	Decrypt []teststruct        `json:"decrypt,omitempty"`
+	Tests   []map[string]string `json:"tests"`
 }
 
 func main() {
@@ -56,55 +71,87 @@ func main() {
 	fmt.Println(inputPath)
 	f, err := os.Open(inputPath)
 	check(err)
+	defer f.Close()
 
 	scanner := bufio.NewScanner(f)
-	phase := ""
-	var encPipe, decPipe [300]teststruct
-	var key, iv, plain, cipher string
-	var count int
+	context := map[string]string{}
+	var test map[string]string
+	var file testfile
+
+	// Tests are collected once complete, i.e. at a blank line or a new section
+	flush := func() {
+		if test != nil {
+			file.Tests = append(file.Tests, test)
+			test = nil
+		}
+	}
+
 	for scanner.Scan() {
-		line := scanner.Text()
-		if len(line) == 0 || s.HasPrefix(line, "#") {
+		line := s.TrimSpace(scanner.Text())
+		if s.HasPrefix(line, "#") {
+			continue
+		}
+		if len(line) == 0 {
+			flush()
 			continue
 		}
 		if s.HasPrefix(line, "[") {
-			phase = line[1 : len(line)-1]
+			flush()
+			parseSection(context, line[1:len(line)-1])
 			continue
-		} else if s.HasPrefix(line, "COUNT") {
-			count, err = strconv.Atoi(line[8:])
-			check(err)
-		} else if s.HasPrefix(line, "KEY") {
-			key = line[6:]
-		} else if s.HasPrefix(line, "IV") {
-			iv = line[5:]
-		} else if s.HasPrefix(line, "PLAIN") {
-			plain = line[12:]
-
-			if phase == "DECRYPT" {
-				test := teststruct{Key: key, Iv: iv,
-					Plaintext: plain, Ciphertext: cipher}
-				decPipe[count] = test
-			}
-		} else if s.HasPrefix(line, "CIPHER") {
-			cipher = line[13:]
+		}
+
+		name, value, _ := s.Cut(line, "=")
+		name, value = s.TrimSpace(name), s.TrimSpace(value)
 
-			if phase == "ENCRYPT" {
-				test := teststruct{Key: key, Iv: iv,
-					Plaintext: plain, Ciphertext: cipher}
-				encPipe[count] = test
+		if s.EqualFold(name, "COUNT") {
+			flush()
+			test = map[string]string{}
+			for k, v := range context {
+				test[k] = v
 			}
 		}
+		if test != nil {
+			test[name] = value
+		} else {
+			context[name] = value
+		}
 	}
+	check(scanner.Err())
+	flush()
 
-	enc := encPipe[:count+1]
-	dec := decPipe[:count+1]
-
-	file := testfile{Encrypt: enc, Decrypt: dec}
+	// The known answer tests of the confidentiality modes keep their format
+	for _, test := range file.Tests {
+		if _, found := test["KEY"]; !found {
+			continue
+		}
+		kat := teststruct{Key: test["KEY"], Iv: test["IV"],
+			Plaintext: test["PLAINTEXT"], Ciphertext: test["CIPHERTEXT"]}
+		if test["phase"] == "ENCRYPT" {
+			file.Encrypt = append(file.Encrypt, kat)
+		} else if test["phase"] == "DECRYPT" {
+			file.Decrypt = append(file.Decrypt, kat)
+		}
+	}
 
-	outputPath := inputPath[:len(inputPath)-4] + ".json"
+	outputPath := s.TrimSuffix(inputPath, filepath.Ext(inputPath)) + ".json"
 
 	testsJSON, err := json.Marshal(file)
 	check(err)
 	err = ioutil.WriteFile(outputPath, testsJSON, 0644)
 	check(err)
 }
+
+// parseSection stores the assignments of a section header, e.g.
+// "Alen = 0, Plen = 0", into the context, a bare header such as
+// "ENCRYPT" is stored as the phase
+func parseSection(context map[string]string, header string) {
+	for _, field := range s.Split(header, ",") {
+		name, value, found := s.Cut(field, "=")
+		if !found {
+			context["phase"] = s.TrimSpace(field)
+			continue
+		}
+		context[s.TrimSpace(name)] = s.TrimSpace(value)
+	}
+}
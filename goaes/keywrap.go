@@ -0,0 +1,218 @@
+/*
+	keywrap.go
+
+	Implementation of the AES Key Wrap (KW) and Key Wrap with Padding (KWP)
+	modes as specified in NIST SP 800-38F, compatible with RFC 3394 and
+	RFC 5649. The wrapping function W runs six passes of the block cipher
+	over the 64-bit semiblocks of the key, unwrapping verifies the integrity
+	check value (ICV) before any plaintext is returned.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	keywrap.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	semiblockSize = 8
+	// kwMaxLength bounds the plaintext of KWP by its 32-bit length indicator
+	kwMaxLength = 1<<32 - 1
+)
+
+// kwICV is the default initial value of KW, ICV1 of SP 800-38F
+var kwICV = [semiblockSize]byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// kwpICV is the constant half of the initial value of KWP, ICV2 of SP
+// 800-38F, followed by the 32-bit message length indicator
+var kwpICV = [4]byte{0xa6, 0x59, 0x59, 0xa6}
+
+// ErrWrapLength is returned for a key or a wrapped key of invalid length
+var ErrWrapLength = errors.New("goaes: invalid key wrap input length")
+
+// ErrUnwrap is returned when the integrity check value of a wrapped key does not match
+var ErrUnwrap = errors.New("goaes: key unwrap integrity check failed")
+
+// KeyWrap wraps plaintext with the key-encryption key b using KW, the
+// plaintext must be a multiple of 8 bytes and at least 16 bytes long
+// The result is 8 bytes longer than the plaintext
+func KeyWrap(b cipher.Block, plaintext []byte) ([]byte, error) {
+	if err := checkWrapBlock(b); err != nil {
+		return nil, err
+	}
+	if len(plaintext) < 2*semiblockSize || len(plaintext)%semiblockSize != 0 ||
+		uint64(len(plaintext)) > kwMaxLength {
+		return nil, ErrWrapLength
+	}
+	out := make([]byte, semiblockSize+len(plaintext))
+	copy(out, kwICV[:])
+	copy(out[semiblockSize:], plaintext)
+	wrap(b, out)
+	return out, nil
+}
+
+// KeyUnwrap unwraps ciphertext wrapped by KeyWrap with the key-encryption
+// key b and returns the plaintext if its ICV is valid
+func KeyUnwrap(b cipher.Block, ciphertext []byte) ([]byte, error) {
+	if err := checkWrapBlock(b); err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < 3*semiblockSize || len(ciphertext)%semiblockSize != 0 ||
+		uint64(len(ciphertext)) > kwMaxLength+semiblockSize {
+		return nil, ErrWrapLength
+	}
+	buf := append([]byte(nil), ciphertext...)
+	unwrap(b, buf)
+	if subtle.ConstantTimeCompare(buf[:semiblockSize], kwICV[:]) != 1 {
+		wipe(buf)
+		return nil, ErrUnwrap
+	}
+	return buf[semiblockSize:], nil
+}
+
+// KeyWrapPad wraps plaintext of any length between 1 byte and 2^32-1
+// bytes with the key-encryption key b using KWP
+// The plaintext is padded with zeros to a multiple of 8 bytes, the result
+// is the padded length plus 8 bytes
+func KeyWrapPad(b cipher.Block, plaintext []byte) ([]byte, error) {
+	if err := checkWrapBlock(b); err != nil {
+		return nil, err
+	}
+	if len(plaintext) == 0 || uint64(len(plaintext)) > kwMaxLength {
+		return nil, ErrWrapLength
+	}
+	padded := (len(plaintext) + semiblockSize - 1) / semiblockSize * semiblockSize
+	out := make([]byte, semiblockSize+padded)
+	copy(out, kwpICV[:])
+	binary.BigEndian.PutUint32(out[4:], uint32(len(plaintext)))
+	copy(out[semiblockSize:], plaintext)
+
+	// A single semiblock is encrypted together with the ICV as one block
+	if padded == semiblockSize {
+		b.Encrypt(out, out)
+		return out, nil
+	}
+	wrap(b, out)
+	return out, nil
+}
+
+// KeyUnwrapPad unwraps ciphertext wrapped by KeyWrapPad with the
+// key-encryption key b and returns the plaintext if the ICV, the length
+// indicator and the padding are valid
+func KeyUnwrapPad(b cipher.Block, ciphertext []byte) ([]byte, error) {
+	if err := checkWrapBlock(b); err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < 2*semiblockSize || len(ciphertext)%semiblockSize != 0 ||
+		uint64(len(ciphertext)) > kwMaxLength+2*semiblockSize {
+		return nil, ErrWrapLength
+	}
+	buf := append([]byte(nil), ciphertext...)
+	if len(buf) == 2*semiblockSize {
+		b.Decrypt(buf, buf)
+	} else {
+		unwrap(b, buf)
+	}
+
+	// The length must be in the last semiblock and the padding zero, every
+	// check is made regardless of the others
+	padded := uint64(len(buf) - semiblockSize)
+	length := uint64(binary.BigEndian.Uint32(buf[4:semiblockSize]))
+	valid := subtle.ConstantTimeCompare(buf[:4], kwpICV[:])
+	valid &= lessOrEq(padded-semiblockSize+1, length)
+	valid &= lessOrEq(length, padded)
+	var padding byte
+	for i := semiblockSize; i < len(buf); i++ {
+		// Bytes past the length indicator must be zero
+		inPadding := lessOrEq(length+semiblockSize, uint64(i))
+		padding |= buf[i] & byte(-inPadding)
+	}
+	valid &= subtle.ConstantTimeByteEq(padding, 0)
+	if valid != 1 {
+		wipe(buf)
+		return nil, ErrUnwrap
+	}
+	return buf[semiblockSize : semiblockSize+int(length)], nil
+}
+
+func checkWrapBlock(b cipher.Block) error {
+	if b == nil {
+		return ErrNilBlock
+	}
+	if b.BlockSize() != 2*semiblockSize {
+		return ErrBlockSize
+	}
+	return nil
+}
+
+// wrap applies the wrapping function W of SP 800-38F in place, buf holds
+// the initial value A followed by the semiblocks R[1], ..., R[n]
+// Each of the 6n steps encrypts A || R[i] and XORs the step number into A
+func wrap(b cipher.Block, buf []byte) {
+	n := len(buf)/semiblockSize - 1
+	var block [2 * semiblockSize]byte
+	copy(block[:semiblockSize], buf)
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			r := buf[i*semiblockSize : (i+1)*semiblockSize]
+			copy(block[semiblockSize:], r)
+			b.Encrypt(block[:], block[:])
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(block[:semiblockSize],
+				binary.BigEndian.Uint64(block[:semiblockSize])^t)
+			copy(r, block[semiblockSize:])
+		}
+	}
+	copy(buf, block[:semiblockSize])
+}
+
+// unwrap applies the unwrapping function W^-1 of SP 800-38F in place,
+// buf[:8] receives the recovered initial value
+func unwrap(b cipher.Block, buf []byte) {
+	n := len(buf)/semiblockSize - 1
+	var block [2 * semiblockSize]byte
+	copy(block[:semiblockSize], buf)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			r := buf[i*semiblockSize : (i+1)*semiblockSize]
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(block[:semiblockSize],
+				binary.BigEndian.Uint64(block[:semiblockSize])^t)
+			copy(block[semiblockSize:], r)
+			b.Decrypt(block[:], block[:])
+			copy(r, block[semiblockSize:])
+		}
+	}
+	copy(buf, block[:semiblockSize])
+}
+
+// lessOrEq returns 1 if x <= y and 0 otherwise in constant time, x and y
+// must be below 2^63, unlike subtle.ConstantTimeLessOrEq it is not limited
+// to 31 bits
+func lessOrEq(x, y uint64) int {
+	return int((y-x)>>63) ^ 1
+}
+
+// wipe zeroes an unwrapped key that failed verification
+func wipe(buf []byte) {
+	for i := range buf {
+		buf[i] = 0
+	}
+}
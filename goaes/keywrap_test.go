@@ -0,0 +1,305 @@
+/*
+	keywrap_test.go
+
+	Key wrap test vectors from RFC 3394 section 4 and RFC 5649 section 6,
+	and the NIST CAVP KW and KWP tests
+	See: https://csrc.nist.gov/projects/cryptographic-algorithm-validation-program/cavp-testing-block-cipher-modes
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	keywrap_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/json"
+	"fmt"
+	mrand "math/rand"
+	"testing"
+)
+
+type kwtest struct {
+	name    string
+	kek     string
+	key     string
+	wrapped string
+}
+
+// RFC 3394 section 4
+var kwtests = []kwtest{
+	{
+		"4.1 Wrap 128 bits of Key Data with a 128-bit KEK",
+		"000102030405060708090a0b0c0d0e0f",
+		"00112233445566778899aabbccddeeff",
+		"1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe5",
+	},
+	{
+		"4.2 Wrap 128 bits of Key Data with a 192-bit KEK",
+		"000102030405060708090a0b0c0d0e0f1011121314151617",
+		"00112233445566778899aabbccddeeff",
+		"96778b25ae6ca435f92b5b97c050aed2468ab8a17ad84e5d",
+	},
+	{
+		"4.3 Wrap 128 bits of Key Data with a 256-bit KEK",
+		"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		"00112233445566778899aabbccddeeff",
+		"64e8c3f9ce0f5ba263e9777905818a2a93c8191e7d6e8ae7",
+	},
+	{
+		"4.4 Wrap 192 bits of Key Data with a 192-bit KEK",
+		"000102030405060708090a0b0c0d0e0f1011121314151617",
+		"00112233445566778899aabbccddeeff0001020304050607",
+		"031d33264e15d33268f24ec260743edce1c6c7ddee725a936ba814915c6762d2",
+	},
+	{
+		"4.5 Wrap 192 bits of Key Data with a 256-bit KEK",
+		"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		"00112233445566778899aabbccddeeff0001020304050607",
+		"a8f9bc1612c68b3ff6e6f4fbe30e71e4769c8b80a32cb8958cd5d17d6b254da1",
+	},
+	{
+		"4.6 Wrap 256 bits of Key Data with a 256-bit KEK",
+		"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		"00112233445566778899aabbccddeeff000102030405060708090a0b0c0d0e0f",
+		"28c9f404c4b810f4cbccb35cfb87f8263f5786e2d80ed326cbc7f0e71a99f43b" +
+			"fb988b9b7a02dd21",
+	},
+}
+
+// RFC 5649 section 6
+var kwptests = []kwtest{
+	{
+		"Wrap 20 octets with a 192-bit KEK",
+		"5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8",
+		"c37b7e6492584340bed12207808941155068f738",
+		"138bdeaa9b8fa7fc61f97742e72248ee5ae6ae5360d1ae6a5f54f373fa543b6a",
+	},
+	{
+		"Wrap 7 octets with a 192-bit KEK",
+		"5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8",
+		"466f7250617369",
+		"afbeb0f07dfbf5419200f2ccb50bb24f",
+	},
+}
+
+func TestKeyWrapVectors(t *testing.T) {
+	for i, tests := range [][]kwtest{kwtests, kwptests} {
+		wrapFunc, unwrapFunc := KeyWrap, KeyUnwrap
+		if i == 1 {
+			wrapFunc, unwrapFunc = KeyWrapPad, KeyUnwrapPad
+		}
+		for _, test := range tests {
+			block, err := katCipher(decodehex([]byte(test.kek)))
+			check(err)
+			key := decodehex([]byte(test.key))
+			expected := decodehex([]byte(test.wrapped))
+
+			wrapped, err := wrapFunc(block, key)
+			if err != nil || !bytes.Equal(wrapped, expected) {
+				t.Error(test.name, " expected ", test.wrapped,
+					",got ", string(encodehex(wrapped)), " error ", err)
+			}
+			unwrapped, err := unwrapFunc(block, expected)
+			if err != nil || !bytes.Equal(unwrapped, key) {
+				t.Error(test.name, " expected ", test.key,
+					",got ", string(encodehex(unwrapped)), " error ", err)
+			}
+		}
+	}
+}
+
+func TestKeyUnwrapTampering(t *testing.T) {
+	block, err := aes.NewCipher(randomBytes(16))
+	check(err)
+	for _, size := range []int{1, 8, 20, 32} {
+		wrapFunc, unwrapFunc := KeyWrapPad, KeyUnwrapPad
+		if size%8 == 0 && size >= 16 {
+			wrapFunc, unwrapFunc = KeyWrap, KeyUnwrap
+		}
+		wrapped, err := wrapFunc(block, randomBytes(size))
+		check(err)
+		for i := 0; i < len(wrapped)*8; i++ {
+			tampered := append([]byte(nil), wrapped...)
+			tampered[i/8] ^= 1 << uint(i%8)
+			if out, err := unwrapFunc(block, tampered); err != ErrUnwrap || out != nil {
+				t.Fatal("Expected ErrUnwrap and no key after flipping bit ", i, " of a ", size, "-byte key")
+			}
+		}
+	}
+
+	// KW and KWP have different ICVs, a key wrapped by one does not
+	// unwrap with the other
+	wrapped, err := KeyWrap(block, randomBytes(24))
+	check(err)
+	if _, err := KeyUnwrapPad(block, wrapped); err != ErrUnwrap {
+		t.Error("Expected ErrUnwrap for KW unwrapped as KWP, got ", err)
+	}
+	wrapped, err = KeyWrapPad(block, randomBytes(24))
+	check(err)
+	if _, err := KeyUnwrap(block, wrapped); err != ErrUnwrap {
+		t.Error("Expected ErrUnwrap for KWP unwrapped as KW, got ", err)
+	}
+}
+
+// TestKeyUnwrapPadding wraps initial values with an inconsistent length
+// indicator or a non-zero padding, which KeyUnwrapPad must reject
+func TestKeyUnwrapPadding(t *testing.T) {
+	block, err := aes.NewCipher(randomBytes(16))
+	check(err)
+	invalid := []struct {
+		name    string
+		length  byte
+		padding []byte
+	}{
+		{"zero length", 0, make([]byte, 16)},
+		{"length one semiblock short", 8, make([]byte, 16)},
+		{"length past the padding", 17, make([]byte, 16)},
+		{"non-zero padding", 12, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 0, 0, 0, 1}},
+		{"length of a single semiblock", 9, make([]byte, 8)},
+	}
+	for _, test := range invalid {
+		buf := append([]byte{0xa6, 0x59, 0x59, 0xa6, 0, 0, 0, test.length}, test.padding...)
+		if len(buf) == 16 {
+			block.Encrypt(buf, buf)
+		} else {
+			wrap(block, buf)
+		}
+		if _, err := KeyUnwrapPad(block, buf); err != ErrUnwrap {
+			t.Error("Expected ErrUnwrap for ", test.name, ", got ", err)
+		}
+	}
+}
+
+func TestKeyWrapRoundTrip(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(13))
+	for i := 0; i < differentialRounds; i++ {
+		block := randomBlock(t, rng)
+		key := randomBytes(1 + rng.Intn(100))
+
+		wrapped, err := KeyWrapPad(block, key)
+		check(err)
+		if len(wrapped) != (len(key)+7)/8*8+8 {
+			t.Fatal("Expected ", (len(key)+7)/8*8+8, " bytes, got ", len(wrapped))
+		}
+		unwrapped, err := KeyUnwrapPad(block, wrapped)
+		if err != nil || !bytes.Equal(unwrapped, key) {
+			t.Fatal("KWP round trip failed with error ", err)
+		}
+
+		if len(key)%8 != 0 || len(key) < 16 {
+			continue
+		}
+		wrapped, err = KeyWrap(block, key)
+		check(err)
+		unwrapped, err = KeyUnwrap(block, wrapped)
+		if err != nil || !bytes.Equal(unwrapped, key) {
+			t.Fatal("KW round trip failed with error ", err)
+		}
+	}
+}
+
+func TestKeyWrapErrors(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	check(err)
+	for _, size := range []int{0, 8, 15, 17} {
+		if _, err := KeyWrap(block, make([]byte, size)); err != ErrWrapLength {
+			t.Error("Expected ErrWrapLength wrapping ", size, " bytes, got ", err)
+		}
+	}
+	for _, size := range []int{0, 8, 16, 25} {
+		if _, err := KeyUnwrap(block, make([]byte, size)); err != ErrWrapLength {
+			t.Error("Expected ErrWrapLength unwrapping ", size, " bytes, got ", err)
+		}
+	}
+	if _, err := KeyWrapPad(block, nil); err != ErrWrapLength {
+		t.Error("Expected ErrWrapLength wrapping an empty key, got ", err)
+	}
+	for _, size := range []int{0, 8, 17} {
+		if _, err := KeyUnwrapPad(block, make([]byte, size)); err != ErrWrapLength {
+			t.Error("Expected ErrWrapLength unwrapping ", size, " bytes, got ", err)
+		}
+	}
+	if _, err := KeyWrap(nil, make([]byte, 16)); err != ErrNilBlock {
+		t.Error("Expected ErrNilBlock, got ", err)
+	}
+}
+
+// inverseCipher swaps encryption and decryption, the CAVP "_inv" files
+// wrap with the inverse cipher as the forward function, which SP 800-38F
+// allows
+type inverseCipher struct {
+	cipher.Block
+}
+
+func (c inverseCipher) Encrypt(dst, src []byte) { c.Block.Decrypt(dst, src) }
+func (c inverseCipher) Decrypt(dst, src []byte) { c.Block.Encrypt(dst, src) }
+
+// keyWrapTestrun runs the tests of a KW_AE, KW_AD, KWP_AE or KWP_AD file,
+// tests with a FAIL line must not unwrap
+func keyWrapTestrun(t *testing.T, tests []map[string]string, padded, inverse bool) int {
+	wrapFunc, unwrapFunc := KeyWrap, KeyUnwrap
+	if padded {
+		wrapFunc, unwrapFunc = KeyWrapPad, KeyUnwrapPad
+	}
+	numTests := 0
+	for _, test := range tests {
+		block, err := katCipher(decodehex([]byte(test["K"])))
+		check(err)
+		if inverse {
+			block = inverseCipher{block}
+		}
+		plaintext := decodehex([]byte(test["P"]))
+		ciphertext := decodehex([]byte(test["C"]))
+
+		unwrapped, err := unwrapFunc(block, ciphertext)
+		if _, fail := test["FAIL"]; fail {
+			if err != ErrUnwrap && err != ErrWrapLength {
+				t.Error("Count ", test["COUNT"], " expected the unwrapping to fail, got ", err)
+			}
+			numTests++
+			continue
+		}
+		if err != nil || !bytes.Equal(unwrapped, plaintext) {
+			t.Error("Count ", test["COUNT"], " expected ", test["P"],
+				",got ", string(encodehex(unwrapped)), " error ", err)
+		}
+		wrapped, err := wrapFunc(block, plaintext)
+		if err != nil || !bytes.Equal(wrapped, ciphertext) {
+			t.Error("Count ", test["COUNT"], " expected ", test["C"],
+				",got ", string(encodehex(wrapped)), " error ", err)
+		}
+		numTests++
+	}
+	return numTests
+}
+
+func TestKeyWrapCAVP(t *testing.T) {
+	for _, mode := range []string{"KW", "KWP"} {
+		for _, direction := range []string{"AE", "AD"} {
+			for _, keySize := range []string{"128", "192", "256", "128_inv", "192_inv", "256_inv"} {
+				name := mode + "_" + direction + "_" + keySize
+				t.Run(name, func(t *testing.T) {
+					var file cavpfile
+					testJSON := readtestfile(t, "jsontests/"+name+".json")
+					json.Unmarshal(testJSON, &file)
+					numTests := keyWrapTestrun(t, file.Tests, mode == "KWP", len(keySize) > 3)
+					fmt.Println("Num "+name+" tests: ", numTests)
+				})
+			}
+		}
+	}
+}
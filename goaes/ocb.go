@@ -0,0 +1,268 @@
+/*
+	ocb.go
+
+	Implementation of the OCB3 mode of operation as specified in RFC 7253,
+	a parallelizable authenticated encryption that encrypts every block once.
+	Each block is masked before and after the encryption with an offset
+	derived from the nonce and the block index, the tag encrypts the
+	checksum of the plaintext and is XORed with the hash of the additional
+	data.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	ocb.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"math/bits"
+)
+
+const (
+	ocbBlockSize      = 16
+	ocbTagSize        = 16
+	ocbMinimumTagSize = 4
+	// The nonce leaves at least one bit of the block for the tag length
+	ocbMaximumNonceSize = 15
+)
+
+var _ cipher.AEAD = (*OCB)(nil)
+
+// OCB is the class for the OCB3 mode of operation
+type OCB struct {
+	enc       *ECBEncrypter
+	dec       *ECBDecrypter
+	tagSize   int
+	nonceSize int
+	// L_*, L_$ and L_i = double^i(L_0) of RFC 7253 section 4.1, the offset
+	// of block i is changed by L_ntz(i), and ntz(i) < 64
+	lStar, lDollar [ocbBlockSize]byte
+	l              [64][ocbBlockSize]byte
+}
+
+// NewOCB is a constructor for the OCB class
+// tagSize must be between 4 and 16 bytes, nonceSize between 1 and 15
+// bytes, RFC 7253 recommends 12-byte nonces
+func NewOCB(b cipher.Block, tagSize, nonceSize int) (*OCB, error) {
+	if b == nil {
+		return nil, ErrNilBlock
+	}
+	if b.BlockSize() != ocbBlockSize {
+		return nil, ErrBlockSize
+	}
+	if tagSize < ocbMinimumTagSize || tagSize > ocbTagSize {
+		return nil, ErrTagSize
+	}
+	if nonceSize < 1 || nonceSize > ocbMaximumNonceSize {
+		return nil, ErrNonceSize
+	}
+
+	o := &OCB{tagSize: tagSize, nonceSize: nonceSize}
+	o.enc, _ = NewECBEncrypter(b)
+	o.dec, _ = NewECBDecrypter(b)
+	b.Encrypt(o.lStar[:], o.lStar[:])
+	o.lDollar = o.lStar
+	dbl(&o.lDollar)
+	o.l[0] = o.lDollar
+	dbl(&o.l[0])
+	for i := 1; i < len(o.l); i++ {
+		o.l[i] = o.l[i-1]
+		dbl(&o.l[i])
+	}
+	return o, nil
+}
+
+// NonceSize returns the size of the nonce that must be passed to Seal and Open
+func (o *OCB) NonceSize() int { return o.nonceSize }
+
+// Overhead returns the difference between the lengths of a plaintext and its ciphertext
+func (o *OCB) Overhead() int { return o.tagSize }
+
+// Seal encrypts and authenticates plaintext, authenticates additionalData
+// and appends the ciphertext and tag to dst
+func (o *OCB) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != o.nonceSize {
+		panic("goaes: incorrect nonce length given to OCB")
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext)+o.tagSize)
+	if inexactOverlap(out, plaintext) {
+		panic("goaes: invalid buffer overlap")
+	}
+
+	tag := o.crypt(out[:len(plaintext)], plaintext, nonce, additionalData, true)
+	copy(out[len(plaintext):], tag[:o.tagSize])
+
+	return ret
+}
+
+// Open decrypts and authenticates ciphertext, authenticates additionalData
+// and appends the plaintext to dst, which is wiped if the tag is invalid
+func (o *OCB) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != o.nonceSize {
+		panic("goaes: incorrect nonce length given to OCB")
+	}
+	if len(ciphertext) < o.tagSize {
+		return nil, ErrOpen
+	}
+
+	tag := append([]byte(nil), ciphertext[len(ciphertext)-o.tagSize:]...)
+	ciphertext = ciphertext[:len(ciphertext)-o.tagSize]
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	if inexactOverlap(out, ciphertext) {
+		panic("goaes: invalid buffer overlap")
+	}
+
+	// OCB authenticates the checksum of the plaintext, so it has to be
+	// decrypted first
+	expectedTag := o.crypt(out, ciphertext, nonce, additionalData, false)
+	if subtle.ConstantTimeCompare(expectedTag[:o.tagSize], tag) != 1 {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, ErrOpen
+	}
+
+	return ret, nil
+}
+
+// crypt encrypts or decrypts src into dst and returns the full tag, see
+// RFC 7253 sections 4.2 and 4.3
+func (o *OCB) crypt(dst, src, nonce, additionalData []byte, encrypt bool) [ocbBlockSize]byte {
+	offset := o.initialOffset(nonce)
+	var checksum [ocbBlockSize]byte
+
+	// The masked blocks are encrypted or decrypted in batches, which a
+	// multiBlock cipher processes together
+	full := len(src) / ocbBlockSize * ocbBlockSize
+	buf := make([]byte, ctrBatchBlocks*ocbBlockSize)
+	offsets := make([]byte, len(buf))
+	index := uint64(0)
+	for i := 0; i < full; i += len(buf) {
+		n := len(buf)
+		if n > full-i {
+			n = full - i
+		}
+		for j := 0; j < n; j += ocbBlockSize {
+			index++
+			xor(offset[:], offset[:], o.l[bits.TrailingZeros64(index)][:])
+			copy(offsets[j:], offset[:])
+			xor(buf[j:j+ocbBlockSize], src[i+j:i+j+ocbBlockSize], offset[:])
+			if encrypt {
+				xor(checksum[:], checksum[:], src[i+j:i+j+ocbBlockSize])
+			}
+		}
+		if encrypt {
+			o.enc.CryptBlocks(buf[:n], buf[:n])
+		} else {
+			o.dec.CryptBlocks(buf[:n], buf[:n])
+		}
+		xor(dst[i:i+n], buf[:n], offsets)
+		if !encrypt {
+			for j := 0; j < n; j += ocbBlockSize {
+				xor(checksum[:], checksum[:], dst[i+j:i+j+ocbBlockSize])
+			}
+		}
+	}
+
+	// The last partial block is XORed with a pad, it enters the checksum
+	// padded with 0x80 and zeros
+	if rest := len(src) - full; rest > 0 {
+		xor(offset[:], offset[:], o.lStar[:])
+		var pad [ocbBlockSize]byte
+		o.enc.CryptBlocks(pad[:], offset[:])
+		var last [ocbBlockSize]byte
+		if encrypt {
+			copy(last[:], src[full:])
+		}
+		xor(dst[full:len(src)], src[full:], pad[:])
+		if !encrypt {
+			copy(last[:], dst[full:len(src)])
+		}
+		last[rest] = 0x80
+		xor(checksum[:], checksum[:], last[:])
+	}
+
+	xor(checksum[:], checksum[:], offset[:])
+	xor(checksum[:], checksum[:], o.lDollar[:])
+	o.enc.CryptBlocks(checksum[:], checksum[:])
+	h := o.hash(additionalData)
+	xor(checksum[:], checksum[:], h[:])
+	return checksum
+}
+
+// initialOffset returns Offset_0, bits bottom to bottom+127 of Stretch,
+// which extends the encryption of the nonce block with its low 6 bits
+// cleared, bottom is the value of these 6 bits
+func (o *OCB) initialOffset(nonce []byte) (offset [ocbBlockSize]byte) {
+	// Nonce = num2str(TAGLEN mod 128, 7) || zeros || 1 || N
+	var n [ocbBlockSize]byte
+	n[0] = byte(o.tagSize*8%128) << 1
+	n[ocbBlockSize-1-len(nonce)] |= 1
+	copy(n[ocbBlockSize-len(nonce):], nonce)
+	bottom := uint(n[ocbBlockSize-1] & 0x3f)
+	n[ocbBlockSize-1] &^= 0x3f
+
+	// Stretch = Ktop || (Ktop[1..64] xor Ktop[9..72])
+	var stretch [ocbBlockSize + 8]byte
+	o.enc.CryptBlocks(stretch[:ocbBlockSize], n[:])
+	for i := 0; i < 8; i++ {
+		stretch[ocbBlockSize+i] = stretch[i] ^ stretch[i+1]
+	}
+
+	shift, bitShift := bottom/8, bottom%8
+	for i := range offset {
+		offset[i] = stretch[shift+uint(i)]<<bitShift | stretch[shift+uint(i)+1]>>(8-bitShift)
+	}
+	return
+}
+
+// hash is HASH of RFC 7253 section 4.1, the sum of the encryptions of the
+// blocks of the additional data masked with offsets that start at zero
+func (o *OCB) hash(additionalData []byte) (sum [ocbBlockSize]byte) {
+	var offset [ocbBlockSize]byte
+	full := len(additionalData) / ocbBlockSize * ocbBlockSize
+	buf := make([]byte, ctrBatchBlocks*ocbBlockSize)
+	index := uint64(0)
+	for i := 0; i < full; i += len(buf) {
+		n := len(buf)
+		if n > full-i {
+			n = full - i
+		}
+		for j := 0; j < n; j += ocbBlockSize {
+			index++
+			xor(offset[:], offset[:], o.l[bits.TrailingZeros64(index)][:])
+			xor(buf[j:j+ocbBlockSize], additionalData[i+j:i+j+ocbBlockSize], offset[:])
+		}
+		o.enc.CryptBlocks(buf[:n], buf[:n])
+		for j := 0; j < n; j += ocbBlockSize {
+			xor(sum[:], sum[:], buf[j:j+ocbBlockSize])
+		}
+	}
+
+	// The last partial block is padded with 0x80 and zeros
+	if rest := len(additionalData) - full; rest > 0 {
+		xor(offset[:], offset[:], o.lStar[:])
+		var last [ocbBlockSize]byte
+		copy(last[:], additionalData[full:])
+		last[rest] = 0x80
+		xor(last[:], last[:], offset[:])
+		o.enc.CryptBlocks(last[:], last[:])
+		xor(sum[:], sum[:], last[:])
+	}
+	return
+}
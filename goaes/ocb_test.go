@@ -0,0 +1,354 @@
+/*
+	ocb_test.go
+
+	Tests for the OCB3 mode of operation.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	ocb_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	mrand "math/rand"
+	"testing"
+)
+
+const ocbKey = "000102030405060708090a0b0c0d0e0f"
+
+// RFC 7253 Appendix A sample results with a 128-bit tag, in the layout of
+// the GCM vectors
+var ocbTests = []gcmtest{
+	{
+		"Sample 1",
+		ocbKey,
+		"bbaa99887766554433221100",
+		"",
+		"",
+		"",
+		"785407bfffc8ad9edcc5520ac9111ee6",
+	},
+	{
+		"Sample 2",
+		ocbKey,
+		"bbaa99887766554433221101",
+		"0001020304050607",
+		"0001020304050607",
+		"6820b3657b6f615a",
+		"5725bda0d3b4eb3a257c9af1f8f03009",
+	},
+	{
+		"Sample 3",
+		ocbKey,
+		"bbaa99887766554433221102",
+		"",
+		"0001020304050607",
+		"",
+		"81017f8203f081277152fade694a0a00",
+	},
+	{
+		"Sample 4",
+		ocbKey,
+		"bbaa99887766554433221103",
+		"0001020304050607",
+		"",
+		"45dd69f8f5aae724",
+		"14054cd1f35d82760b2cd00d2f99bfa9",
+	},
+	{
+		"Sample 5",
+		ocbKey,
+		"bbaa99887766554433221104",
+		"000102030405060708090a0b0c0d0e0f",
+		"000102030405060708090a0b0c0d0e0f",
+		"571d535b60b277188be5147170a9a22c",
+		"3ad7a4ff3835b8c5701c1ccec8fc3358",
+	},
+	{
+		"Sample 6",
+		ocbKey,
+		"bbaa99887766554433221105",
+		"",
+		"000102030405060708090a0b0c0d0e0f",
+		"",
+		"8cf761b6902ef764462ad86498ca6b97",
+	},
+	{
+		"Sample 7",
+		ocbKey,
+		"bbaa99887766554433221106",
+		"000102030405060708090a0b0c0d0e0f",
+		"",
+		"5ce88ec2e0692706a915c00aeb8b2396",
+		"f40e1c743f52436bdf06d8fa1eca343d",
+	},
+	{
+		"Sample 8",
+		ocbKey,
+		"bbaa99887766554433221107",
+		"000102030405060708090a0b0c0d0e0f1011121314151617",
+		"000102030405060708090a0b0c0d0e0f1011121314151617",
+		"1ca2207308c87c010756104d8840ce1952f09673a448a122",
+		"c92c62241051f57356d7f3c90bb0e07f",
+	},
+	{
+		"Sample 9",
+		ocbKey,
+		"bbaa99887766554433221108",
+		"",
+		"000102030405060708090a0b0c0d0e0f1011121314151617",
+		"",
+		"6dc225a071fc1b9f7c69f93b0f1e10de",
+	},
+	{
+		"Sample 10",
+		ocbKey,
+		"bbaa99887766554433221109",
+		"000102030405060708090a0b0c0d0e0f1011121314151617",
+		"",
+		"221bd0de7fa6fe993eccd769460a0af2d6cded0c395b1c3c",
+		"e725f32494b9f914d85c0b1eb38357ff",
+	},
+	{
+		"Sample 11",
+		ocbKey,
+		"bbaa9988776655443322110a",
+		"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		"bd6f6c496201c69296c11efd138a467abd3c707924b964deaffc40319af5a485",
+		"40fbba186c5553c68ad9f592a79a4240",
+	},
+	{
+		"Sample 12",
+		ocbKey,
+		"bbaa9988776655443322110b",
+		"",
+		"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		"",
+		"fe80690bee8a485d11f32965bc9d2a32",
+	},
+	{
+		"Sample 13",
+		ocbKey,
+		"bbaa9988776655443322110c",
+		"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		"",
+		"2942bfc773bda23cabc6acfd9bfd5835bd300f0973792ef46040c53f1432bcdf",
+		"b5e1dde3bc18a5f840b52e653444d5df",
+	},
+	{
+		"Sample 14",
+		ocbKey,
+		"bbaa9988776655443322110d",
+		"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f" +
+			"2021222324252627",
+		"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f" +
+			"2021222324252627",
+		"d5ca91748410c1751ff8a2f618255b68a0a12e093ff454606e59f9c1d0ddc54b" +
+			"65e8628e568bad7a",
+		"ed07ba06a4a69483a7035490c5769e60",
+	},
+	{
+		"Sample 15",
+		ocbKey,
+		"bbaa9988776655443322110e",
+		"",
+		"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f" +
+			"2021222324252627",
+		"",
+		"c5cd9d1850c141e358649994ee701b68",
+	},
+	{
+		"Sample 16",
+		ocbKey,
+		"bbaa9988776655443322110f",
+		"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f" +
+			"2021222324252627",
+		"",
+		"4412923493c57d5de0d700f753cce0d1d2d95060122e9f15a5ddbfc5787e50b5" +
+			"cc55ee507bcb084e",
+		"479ad363ac366b95a98ca5f3000b1479",
+	},
+}
+
+func TestOCBVectors(t *testing.T) {
+	// The last sample of Appendix A has a 96-bit tag
+	tests := append(ocbTests, gcmtest{
+		"Sample 17",
+		"0f0e0d0c0b0a09080706050403020100",
+		"bbaa9988776655443322110d",
+		"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f" +
+			"2021222324252627",
+		"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f" +
+			"2021222324252627",
+		"1792a4e31e0755fb03e31b22116e6c2ddf9efd6e33d536f1a0124b0a55bae884" +
+			"ed93481529c76b6a",
+		"d0c515f4d1cdd4fdac4f02aa",
+	})
+	for _, test := range tests {
+		block, err := katCipher(decodehex([]byte(test.key)))
+		check(err)
+		aead, err := NewOCB(block, len(test.tag)/2, 12)
+		check(err)
+		nonce := decodehex([]byte(test.nonce))
+		plaintext := decodehex([]byte(test.plaintext))
+		aad := decodehex([]byte(test.aad))
+		expected := decodehex([]byte(test.ciphertext + test.tag))
+
+		sealed := aead.Seal(nil, nonce, plaintext, aad)
+		if !bytes.Equal(sealed, expected) {
+			t.Error(test.name, " expected ", string(encodehex(expected)),
+				",got ", string(encodehex(sealed)))
+		}
+
+		opened, err := aead.Open(nil, nonce, expected, aad)
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Error(test.name, " expected ", string(encodehex(plaintext)),
+				",got ", string(encodehex(opened)), " error ", err)
+		}
+	}
+}
+
+// TestOCBIterated runs the iterated test of RFC 7253 Appendix A for every
+// key and tag size, it seals messages and additional data of 0 to 127
+// bytes and finally authenticates all of the outputs
+func TestOCBIterated(t *testing.T) {
+	tests := []struct {
+		keySize  int
+		tagSize  int
+		expected string
+	}{
+		{16, 16, "67e944d23256c5e0b6c61fa22fdf1ea2"},
+		{24, 16, "f673f2c3e7174aae7bae986ca9f29e17"},
+		{32, 16, "d90eb8e9c977c88b79dd793d7ffa161c"},
+		{16, 12, "77a3d8e73589158d25d01209"},
+		{24, 12, "05d56ead2752c86be6932c5e"},
+		{32, 12, "5458359ac23b0cba9e6330dd"},
+		{16, 8, "192c9b7bd90ba06a"},
+		{24, 8, "0066bc6e0ef34e24"},
+		{32, 8, "7d4ea5d445501cbe"},
+	}
+	for _, test := range tests {
+		key := make([]byte, test.keySize)
+		key[len(key)-1] = byte(8 * test.tagSize)
+		block, err := katCipher(key)
+		check(err)
+		aead, err := NewOCB(block, test.tagSize, 12)
+		check(err)
+
+		nonce := make([]byte, 12)
+		setNonce := func(n uint32) []byte {
+			binary.BigEndian.PutUint32(nonce[8:], n)
+			return nonce
+		}
+		var c []byte
+		for i := uint32(0); i < 128; i++ {
+			s := make([]byte, i)
+			c = aead.Seal(c, setNonce(3*i+1), s, s)
+			c = aead.Seal(c, setNonce(3*i+2), s, nil)
+			c = aead.Seal(c, setNonce(3*i+3), nil, s)
+		}
+		got := aead.Seal(nil, setNonce(385), nil, c)
+		if !bytes.Equal(got, decodehex([]byte(test.expected))) {
+			t.Error("Key size ", test.keySize, ", tag size ", test.tagSize,
+				" expected ", test.expected, ",got ", string(encodehex(got)))
+		}
+	}
+}
+
+func TestOCBTampering(t *testing.T) {
+	block, err := aes.NewCipher(randomBytes(16))
+	check(err)
+	aead, err := NewOCB(block, 16, 12)
+	check(err)
+	nonce := randomBytes(12)
+	aad := randomBytes(20)
+	sealed := aead.Seal(nil, nonce, randomBytes(40), aad)
+
+	for i := 0; i < len(sealed)*8; i++ {
+		tampered := append([]byte(nil), sealed...)
+		tampered[i/8] ^= 1 << uint(i%8)
+		if out, err := aead.Open(nil, nonce, tampered, aad); err != ErrOpen || out != nil {
+			t.Fatal("Expected ErrOpen and no output after flipping bit ", i)
+		}
+	}
+
+	// A failed in-place decryption leaves no plaintext behind
+	tampered := append([]byte(nil), sealed...)
+	tampered[0] ^= 1
+	if _, err := aead.Open(tampered[:0], nonce, tampered, aad); err != ErrOpen {
+		t.Error("Expected ErrOpen, got ", err)
+	}
+	if !bytes.Equal(tampered[:len(sealed)-aead.Overhead()], make([]byte, len(sealed)-aead.Overhead())) {
+		t.Error("Expected the decrypted plaintext to be wiped")
+	}
+
+	otherNonce := append([]byte(nil), nonce...)
+	otherNonce[0] ^= 1
+	if _, err := aead.Open(nil, otherNonce, sealed, aad); err != ErrOpen {
+		t.Error("Expected ErrOpen for another nonce, got ", err)
+	}
+	if _, err := aead.Open(nil, nonce, sealed, aad[1:]); err != ErrOpen {
+		t.Error("Expected ErrOpen for modified additional data, got ", err)
+	}
+	if _, err := aead.Open(nil, nonce, sealed[:aead.Overhead()-1], aad); err != ErrOpen {
+		t.Error("Expected ErrOpen for truncated ciphertext, got ", err)
+	}
+}
+
+// TestOCBRoundTrip covers messages longer than one batch of blocks and
+// every nonce length, whose low 6 bits select the bits of Stretch
+func TestOCBRoundTrip(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(14))
+	for i := 0; i < differentialRounds; i++ {
+		block, err := aes.NewCipher(randomBytes(keySizes[rng.Intn(len(keySizes))]))
+		check(err)
+		aead, err := NewOCB(block, 4+rng.Intn(13), 1+rng.Intn(15))
+		check(err)
+		nonce := randomBytes(aead.NonceSize())
+		plaintext := randomBytes(rng.Intn(1200))
+		aad := randomBytes(rng.Intn(600))
+
+		sealed := aead.Seal(nil, nonce, plaintext, aad)
+		buf := append(make([]byte, 0, len(plaintext)+aead.Overhead()), plaintext...)
+		if inPlace := aead.Seal(buf[:0], nonce, buf, aad); !bytes.Equal(inPlace, sealed) {
+			t.Fatal("In-place encryption differs")
+		}
+		opened, err := aead.Open(sealed[:0], nonce, sealed, aad)
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Fatal("Round trip failed with error ", err)
+		}
+	}
+}
+
+func TestOCBConstructorErrors(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	check(err)
+	if _, err := NewOCB(nil, 16, 12); err != ErrNilBlock {
+		t.Error("Expected ErrNilBlock, got ", err)
+	}
+	for _, tagSize := range []int{0, 3, 17} {
+		if _, err := NewOCB(block, tagSize, 12); err != ErrTagSize {
+			t.Error("Expected ErrTagSize for ", tagSize, " bytes, got ", err)
+		}
+	}
+	for _, nonceSize := range []int{0, 16} {
+		if _, err := NewOCB(block, 16, nonceSize); err != ErrNonceSize {
+			t.Error("Expected ErrNonceSize for ", nonceSize, " bytes, got ", err)
+		}
+	}
+}
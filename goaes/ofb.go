@@ -0,0 +1,91 @@
+/*
+	ofb.go
+
+	Implementation of the Output Feedback (OFB) mode of operation.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	ofb.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/cipher"
+)
+
+var _ cipher.Stream = (*OFB)(nil)
+
+// OFB is the class for the Output Feedback mode of operation
+type OFB struct {
+	aes       cipher.Block
+	blockSize int
+	// The input vector is replaced by each encrypted block, which
+	// also serves as the key stream
+	inputVec []byte
+	used     int
+}
+
+// NewOFB is a constructor for the OFB class
+// The input vector is copied, the caller's slice is never modified
+func NewOFB(b cipher.Block, inputVec []byte) (*OFB, error) {
+	if b == nil {
+		return nil, ErrNilBlock
+	}
+	if len(inputVec) != b.BlockSize() {
+		return nil, ErrIVSize
+	}
+	return &OFB{
+		aes:       b,
+		blockSize: b.BlockSize(),
+		inputVec:  append([]byte(nil), inputVec...),
+		// The input vector itself is not part of the key stream
+		used: b.BlockSize(),
+	}, nil
+}
+
+// XORKeyStream implements cipher.Stream, dst and src may be the same slice
+func (ofb *OFB) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("goaes: output smaller than input")
+	}
+	if inexactOverlap(dst[:len(src)], src) {
+		panic("goaes: invalid buffer overlap")
+	}
+
+	for len(src) > 0 {
+		if ofb.used == ofb.blockSize {
+			ofb.aes.Encrypt(ofb.inputVec, ofb.inputVec)
+			ofb.used = 0
+		}
+		n := len(src)
+		if n > ofb.blockSize-ofb.used {
+			n = ofb.blockSize - ofb.used
+		}
+		xor(dst[:n], src[:n], ofb.inputVec[ofb.used:])
+		ofb.used += n
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+// Encrypt is an OFB method for encryption
+func (ofb *OFB) Encrypt(in []byte) []byte {
+	out := make([]byte, len(in))
+	ofb.XORKeyStream(out, in)
+	return out
+}
+
+// Decrypt is an OFB method for decryption, which is the same as encryption
+func (ofb *OFB) Decrypt(in []byte) []byte {
+	return ofb.Encrypt(in)
+}
@@ -0,0 +1,104 @@
+/*
+	ofb_test.go
+
+	NIST SP 800-38A, Appendix F.4 OFB example vectors
+	See: https://csrc.nist.gov/publications/detail/sp/800-38a/final
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	ofb_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	mrand "math/rand"
+	"testing"
+)
+
+var ofbtests = []struct {
+	name       string
+	key        string
+	ciphertext string
+}{
+	{
+		"F.4.1 OFB-AES128",
+		"2b7e151628aed2a6abf7158809cf4f3c",
+		"3b3fd92eb72dad20333449f8e83cfb4a" +
+			"7789508d16918f03f53c52dac54ed825" +
+			"9740051e9c5fecf64344f7a82260edcc" +
+			"304c6528f659c77866a510d9c1d6ae5e",
+	},
+	{
+		"F.4.5 OFB-AES256",
+		"603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4",
+		"dc7e84bfda79164b7ecd8486985d3860" +
+			"4febdc6740d20b3ac88f6ad82a4fb08d" +
+			"71ab47a086e86eedf39d1c5bba97c408" +
+			"0126141d67f37be8538f5a8be740e484",
+	},
+}
+
+func TestOFBVectors(t *testing.T) {
+	for _, test := range ofbtests {
+		block, err := katCipher(decodehex([]byte(test.key)))
+		check(err)
+		inputVec := decodehex([]byte(sp80038aInputVec))
+		plaintext := decodehex([]byte(sp80038aPlaintext))
+		expected := decodehex([]byte(test.ciphertext))
+
+		ofb, err := NewOFB(block, inputVec)
+		check(err)
+		encrypted := ofb.Encrypt(plaintext)
+		if !bytes.Equal(encrypted, expected) {
+			t.Error(test.name, " expected ", string(encodehex(expected)),
+				",got ", string(encodehex(encrypted)))
+		}
+
+		ofb, err = NewOFB(block, inputVec)
+		check(err)
+		decrypted := ofb.Decrypt(expected)
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Error(test.name, " expected ", string(encodehex(plaintext)),
+				",got ", string(encodehex(decrypted)))
+		}
+	}
+}
+
+func TestOFBDifferential(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(7))
+	for i := 0; i < differentialRounds; i++ {
+		block := randomBlock(t, rng)
+		inputVec := randomBytes(aes.BlockSize)
+		plaintext := randomBytes(rng.Intn(1024))
+
+		expected := make([]byte, len(plaintext))
+		cipher.NewOFB(block, inputVec).XORKeyStream(expected, plaintext)
+
+		ofb, err := NewOFB(block, inputVec)
+		check(err)
+		encrypted := append([]byte(nil), plaintext...)
+		for rest := encrypted; len(rest) > 0; {
+			n := 1 + rng.Intn(len(rest))
+			ofb.XORKeyStream(rest[:n], rest[:n])
+			rest = rest[n:]
+		}
+		if !bytes.Equal(encrypted, expected) {
+			t.Fatal("Expected ", string(encodehex(expected)),
+				",got ", string(encodehex(encrypted)))
+		}
+	}
+}
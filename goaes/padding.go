@@ -0,0 +1,306 @@
+/*
+	padding.go
+
+	Padding schemes for the block modes of operation: PKCS#7, ANSI X9.23,
+	ISO/IEC 7816-4, ISO 10126, zero padding and no padding.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	padding.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+)
+
+// ErrPadBlockSize is returned when a padding scheme is given a block size
+// it cannot encode, the padding length must fit in one byte
+var ErrPadBlockSize = errors.New("goaes: padding block size must be between 1 and 255 bytes")
+
+var (
+	_ Padding = PKCS7{}
+	_ Padding = ANSIX923{}
+	_ Padding = ISO7816{}
+	_ Padding = ISO10126{}
+	_ Padding = ZeroPadding{}
+	_ Padding = NoPadding{}
+)
+
+// Padding is the interface of a padding scheme for the block modes
+type Padding interface {
+	// Pad returns a copy of src extended to a multiple of blockSize
+	Pad(src []byte, blockSize int) ([]byte, error)
+	// Unpad validates the padding and returns src without it
+	Unpad(src []byte, blockSize int) ([]byte, error)
+}
+
+// PaddingError is returned when the padding of a decrypted text is malformed
+// It matches ErrInvalidPadding with errors.Is
+// PKCS7 returns ErrInvalidPadding itself, so that its failures cannot be told apart
+type PaddingError struct {
+	Scheme string
+	Reason string
+}
+
+func (e *PaddingError) Error() string {
+	return "goaes: invalid " + e.Scheme + " padding: " + e.Reason
+}
+
+// Is reports whether target is ErrInvalidPadding
+func (e *PaddingError) Is(target error) bool {
+	return target == ErrInvalidPadding
+}
+
+// PKCS7 pads with n bytes of value n, always adding between 1 and blockSize bytes
+// Unpadding runs in constant time over the last block
+type PKCS7 struct{}
+
+// ANSIX923 pads with zeros followed by the number of padding bytes
+type ANSIX923 struct{}
+
+// ISO7816 pads with a single 0x80 byte followed by zeros, as ISO/IEC 7816-4
+type ISO7816 struct{}
+
+// ISO10126 pads with random bytes followed by the number of padding bytes
+type ISO10126 struct{}
+
+// ZeroPadding pads with zeros only if the input does not fill the blocks
+// Trailing zeros of the plaintext itself are lost on unpadding, so it only
+// suits data that cannot end with a zero byte
+type ZeroPadding struct{}
+
+// NoPadding leaves the input unchanged, it must already fill the blocks
+type NoPadding struct{}
+
+// checkPadBlockSize validates the block size passed to Pad and Unpad
+func checkPadBlockSize(blockSize int) error {
+	if blockSize < 1 || blockSize > 255 {
+		return ErrPadBlockSize
+	}
+	return nil
+}
+
+// padLength returns the number of bytes that pad src to the next full block
+func padLength(src []byte, blockSize int) int {
+	return blockSize - len(src)%blockSize
+}
+
+// padWith returns a copy of src followed by n bytes from fill
+func padWith(src []byte, n int, fill func(pad []byte)) []byte {
+	out := make([]byte, len(src)+n)
+	copy(out, src)
+	fill(out[len(src):])
+	return out
+}
+
+// checkPadded validates the length of a padded text and its trailing
+// length byte, which all schemes but ISO 7816-4 and zero padding share
+func checkPadded(scheme string, src []byte, blockSize int) (int, error) {
+	if len(src) == 0 || len(src)%blockSize != 0 {
+		return 0, &PaddingError{scheme, "input does not fill the blocks"}
+	}
+	n := int(src[len(src)-1])
+	if n == 0 || n > blockSize {
+		return 0, &PaddingError{scheme, "padding length out of range"}
+	}
+	return n, nil
+}
+
+// Pad implements Padding
+func (PKCS7) Pad(src []byte, blockSize int) ([]byte, error) {
+	if err := checkPadBlockSize(blockSize); err != nil {
+		return nil, err
+	}
+	n := padLength(src, blockSize)
+	return padWith(src, n, func(pad []byte) {
+		for i := range pad {
+			pad[i] = byte(n)
+		}
+	}), nil
+}
+
+// Unpad implements Padding
+// Every malformed input gives the same ErrInvalidPadding
+func (PKCS7) Unpad(src []byte, blockSize int) ([]byte, error) {
+	if err := checkPadBlockSize(blockSize); err != nil {
+		return nil, err
+	}
+	if len(src) == 0 || len(src)%blockSize != 0 {
+		return nil, ErrInvalidPadding
+	}
+	return unpadPKCS7(src, blockSize)
+}
+
+// unpadPKCS7 validates the padding in constant time, reading the last
+// maxPad bytes whatever the padding length is
+func unpadPKCS7(src []byte, maxPad int) ([]byte, error) {
+	if len(src) == 0 {
+		return nil, ErrInvalidPadding
+	}
+	if maxPad > len(src) {
+		maxPad = len(src)
+	}
+	if maxPad > 255 {
+		maxPad = 255
+	}
+
+	n := int(src[len(src)-1])
+	good := subtle.ConstantTimeLessOrEq(1, n) & subtle.ConstantTimeLessOrEq(n, maxPad)
+	for i := 1; i <= maxPad; i++ {
+		// The i-th byte from the end must equal n if it is part of the padding
+		inPad := subtle.ConstantTimeLessOrEq(i, n)
+		equal := subtle.ConstantTimeByteEq(src[len(src)-i], byte(n))
+		good &= subtle.ConstantTimeSelect(inPad, equal, 1)
+	}
+
+	if good != 1 {
+		return nil, ErrInvalidPadding
+	}
+	return src[:len(src)-n], nil
+}
+
+// Pad implements Padding
+func (ANSIX923) Pad(src []byte, blockSize int) ([]byte, error) {
+	if err := checkPadBlockSize(blockSize); err != nil {
+		return nil, err
+	}
+	n := padLength(src, blockSize)
+	return padWith(src, n, func(pad []byte) {
+		pad[len(pad)-1] = byte(n)
+	}), nil
+}
+
+// Unpad implements Padding
+func (ANSIX923) Unpad(src []byte, blockSize int) ([]byte, error) {
+	if err := checkPadBlockSize(blockSize); err != nil {
+		return nil, err
+	}
+	n, err := checkPadded("ANSI X9.23", src, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range src[len(src)-n : len(src)-1] {
+		if b != 0 {
+			return nil, &PaddingError{"ANSI X9.23", "padding bytes are not zero"}
+		}
+	}
+	return src[:len(src)-n], nil
+}
+
+// Pad implements Padding
+func (ISO7816) Pad(src []byte, blockSize int) ([]byte, error) {
+	if err := checkPadBlockSize(blockSize); err != nil {
+		return nil, err
+	}
+	return padWith(src, padLength(src, blockSize), func(pad []byte) {
+		pad[0] = 0x80
+	}), nil
+}
+
+// Unpad implements Padding
+func (ISO7816) Unpad(src []byte, blockSize int) ([]byte, error) {
+	if err := checkPadBlockSize(blockSize); err != nil {
+		return nil, err
+	}
+	if len(src) == 0 || len(src)%blockSize != 0 {
+		return nil, &PaddingError{"ISO/IEC 7816-4", "input does not fill the blocks"}
+	}
+	// The marker is the last non-zero byte, within the last block
+	i := len(src) - 1
+	for i > len(src)-blockSize && src[i] == 0 {
+		i--
+	}
+	if src[i] == 0x80 {
+		return src[:i], nil
+	}
+	return nil, &PaddingError{"ISO/IEC 7816-4", "missing 0x80 marker"}
+}
+
+// Pad implements Padding
+func (ISO10126) Pad(src []byte, blockSize int) ([]byte, error) {
+	if err := checkPadBlockSize(blockSize); err != nil {
+		return nil, err
+	}
+	n := padLength(src, blockSize)
+	out := make([]byte, len(src)+n)
+	copy(out, src)
+	if _, err := rand.Read(out[len(src) : len(out)-1]); err != nil {
+		return nil, err
+	}
+	out[len(out)-1] = byte(n)
+	return out, nil
+}
+
+// Unpad implements Padding
+// The random padding bytes cannot be validated, only the padding length
+func (ISO10126) Unpad(src []byte, blockSize int) ([]byte, error) {
+	if err := checkPadBlockSize(blockSize); err != nil {
+		return nil, err
+	}
+	n, err := checkPadded("ISO 10126", src, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	return src[:len(src)-n], nil
+}
+
+// Pad implements Padding
+func (ZeroPadding) Pad(src []byte, blockSize int) ([]byte, error) {
+	if err := checkPadBlockSize(blockSize); err != nil {
+		return nil, err
+	}
+	n := padLength(src, blockSize) % blockSize
+	return padWith(src, n, func([]byte) {}), nil
+}
+
+// Unpad implements Padding
+// At most blockSize-1 trailing zeros are removed
+func (ZeroPadding) Unpad(src []byte, blockSize int) ([]byte, error) {
+	if err := checkPadBlockSize(blockSize); err != nil {
+		return nil, err
+	}
+	if len(src)%blockSize != 0 {
+		return nil, &PaddingError{"zero", "input does not fill the blocks"}
+	}
+	end := len(src)
+	for end > 0 && end > len(src)-blockSize+1 && src[end-1] == 0 {
+		end--
+	}
+	return src[:end], nil
+}
+
+// Pad implements Padding
+func (NoPadding) Pad(src []byte, blockSize int) ([]byte, error) {
+	if err := checkPadBlockSize(blockSize); err != nil {
+		return nil, err
+	}
+	if err := checkFullBlocks(src, blockSize); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), src...), nil
+}
+
+// Unpad implements Padding
+func (NoPadding) Unpad(src []byte, blockSize int) ([]byte, error) {
+	if err := checkPadBlockSize(blockSize); err != nil {
+		return nil, err
+	}
+	if len(src)%blockSize != 0 {
+		return nil, &PaddingError{"no", "input does not fill the blocks"}
+	}
+	return src, nil
+}
@@ -0,0 +1,262 @@
+/*
+	padding_test.go
+
+	Tests for the padding schemes.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	padding_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"errors"
+	"testing"
+)
+
+type paddingtest struct {
+	name    string
+	padding Padding
+	src     string
+	padded  string
+}
+
+var paddingtests = []paddingtest{
+	{"PKCS#7", PKCS7{}, "dddddddddd", "dddddddddd060606060606"},
+	{"PKCS#7 full block", PKCS7{}, "dddddddddddddddddddddd", "dddddddddddddddddddddd0b0b0b0b0b0b0b0b0b0b0b"},
+	{"ANSI X9.23", ANSIX923{}, "dddddddddd", "dddddddddd000000000006"},
+	{"ANSI X9.23 full block", ANSIX923{}, "dddddddddddddddddddddd", "dddddddddddddddddddddd000000000000000000000b"},
+	{"ISO/IEC 7816-4", ISO7816{}, "dddddddddd", "dddddddddd800000000000"},
+	{"ISO/IEC 7816-4 one byte", ISO7816{}, "dddddddddddddddddddd", "dddddddddddddddddddd80"},
+	{"Zero", ZeroPadding{}, "dddddddddd", "dddddddddd000000000000"},
+	{"Zero full block", ZeroPadding{}, "dddddddddddddddddddddd", "dddddddddddddddddddddd"},
+	{"None", NoPadding{}, "dddddddddddddddddddddd", "dddddddddddddddddddddd"},
+}
+
+// The tests use 11-byte blocks to catch any assumption of a 16-byte block
+const paddingBlockSize = 11
+
+func TestPaddingVectors(t *testing.T) {
+	for _, test := range paddingtests {
+		src := decodehex([]byte(test.src))
+		expected := decodehex([]byte(test.padded))
+
+		padded, err := test.padding.Pad(src, paddingBlockSize)
+		if err != nil || !bytes.Equal(padded, expected) {
+			t.Error(test.name, " expected ", test.padded,
+				",got ", string(encodehex(padded)), " error ", err)
+		}
+		unpadded, err := test.padding.Unpad(expected, paddingBlockSize)
+		if err != nil || !bytes.Equal(unpadded, src) {
+			t.Error(test.name, " expected ", test.src,
+				",got ", string(encodehex(unpadded)), " error ", err)
+		}
+	}
+}
+
+func TestPaddingRoundTrip(t *testing.T) {
+	schemes := []Padding{PKCS7{}, ANSIX923{}, ISO7816{}, ISO10126{}, ZeroPadding{}}
+	for _, padding := range schemes {
+		for n := 0; n <= 3*16; n++ {
+			// Zero padding cannot restore trailing zeros
+			src := bytes.Repeat([]byte{0xa5}, n)
+			padded, err := padding.Pad(src, 16)
+			check(err)
+			if len(padded)%16 != 0 || len(padded) < n {
+				t.Error("Unexpected padded length ", len(padded), " for ", n, " bytes")
+			}
+			unpadded, err := padding.Unpad(padded, 16)
+			if err != nil || !bytes.Equal(unpadded, src) {
+				t.Error("Expected ", string(encodehex(src)),
+					",got ", string(encodehex(unpadded)), " error ", err)
+			}
+		}
+	}
+
+	// Padding must not write into the caller's buffer
+	src := make([]byte, 5, 16)
+	PKCS7{}.Pad(src, 16)
+	if src[:16][5] != 0 {
+		t.Error("Pad modified the spare capacity of src")
+	}
+}
+
+func TestPaddingInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		padding Padding
+		padded  string
+	}{
+		{"PKCS#7 empty", PKCS7{}, ""},
+		{"PKCS#7 partial block", PKCS7{}, "dddddddddd06"},
+		{"PKCS#7 zero length", PKCS7{}, "dddddddddddddddddddd00"},
+		{"PKCS#7 length too large", PKCS7{}, "dddddddddddddddddddd0c"},
+		{"PKCS#7 inconsistent bytes", PKCS7{}, "dddddddddd060606060506"},
+		{"ANSI X9.23 non-zero fill", ANSIX923{}, "dddddddddd000000010006"},
+		{"ANSI X9.23 zero length", ANSIX923{}, "dddddddddd000000000000"},
+		{"ISO/IEC 7816-4 no marker", ISO7816{}, "dddddddddd000000000000"},
+		{"ISO/IEC 7816-4 wrong marker", ISO7816{}, "dddddddddd810000000000"},
+		{"ISO/IEC 7816-4 all zero block", ISO7816{}, "8000000000000000000000" + "0000000000000000000000"},
+		{"ISO 10126 length too large", ISO10126{}, "dddddddddd01020304050c"},
+		{"Zero partial block", ZeroPadding{}, "dddddddddd00"},
+		{"None partial block", NoPadding{}, "dddddddddd"},
+	}
+	for _, test := range tests {
+		out, err := test.padding.Unpad(decodehex([]byte(test.padded)), paddingBlockSize)
+		// PKCS#7 gives one generic error for every failure
+		if _, pkcs7 := test.padding.(PKCS7); pkcs7 {
+			if out != nil || err != ErrInvalidPadding {
+				t.Error(test.name, " expected ", ErrInvalidPadding, ",got ", err)
+			}
+			continue
+		}
+		var paddingErr *PaddingError
+		if out != nil || !errors.As(err, &paddingErr) || !errors.Is(err, ErrInvalidPadding) {
+			t.Error(test.name, " expected a PaddingError,got ", err)
+		}
+	}
+
+	if _, err := (NoPadding{}).Pad(make([]byte, 10), paddingBlockSize); !errors.Is(err, ErrNotFullBlocks) {
+		t.Error("Expected ", ErrNotFullBlocks, ",got ", err)
+	}
+}
+
+func TestPaddingBlockSize(t *testing.T) {
+	schemes := []struct {
+		name    string
+		padding Padding
+	}{
+		{"PKCS#7", PKCS7{}}, {"ANSI X9.23", ANSIX923{}}, {"ISO/IEC 7816-4", ISO7816{}},
+		{"ISO 10126", ISO10126{}}, {"Zero", ZeroPadding{}}, {"None", NoPadding{}},
+	}
+	for _, scheme := range schemes {
+		for _, blockSize := range []int{-1, 0, 256, 1024} {
+			if _, err := scheme.padding.Pad(make([]byte, 10), blockSize); err != ErrPadBlockSize {
+				t.Error(scheme.name, " Pad with block size ", blockSize, " expected ", ErrPadBlockSize, ",got ", err)
+			}
+			if _, err := scheme.padding.Unpad(make([]byte, 16), blockSize); err != ErrPadBlockSize {
+				t.Error(scheme.name, " Unpad with block size ", blockSize, " expected ", ErrPadBlockSize, ",got ", err)
+			}
+		}
+
+		// The largest block size still fits the padding length in a byte
+		src := bytes.Repeat([]byte{0xa5}, 255)
+		padded, err := scheme.padding.Pad(src, 255)
+		check(err)
+		unpadded, err := scheme.padding.Unpad(padded, 255)
+		if err != nil || !bytes.Equal(unpadded, src) {
+			t.Error(scheme.name, " failed a round trip with block size 255, error ", err)
+		}
+	}
+
+	if _, err := Pad(nil, 0); err != ErrPadBlockSize {
+		t.Error("Expected ", ErrPadBlockSize, ",got ", err)
+	}
+}
+
+// verbosePKCS7Unpad is the previous PKCS#7 unpadding, which gave a different
+// error for each kind of malformed padding
+func verbosePKCS7Unpad(src []byte, blockSize int) ([]byte, error) {
+	n, err := checkPadded("PKCS#7", src, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range src[len(src)-n:] {
+		if int(b) != n {
+			return nil, &PaddingError{"PKCS#7", "padding bytes differ from the padding length"}
+		}
+	}
+	return src[:len(src)-n], nil
+}
+
+// oracleAttack runs the first step of a padding oracle attack on a one block
+// CBC ciphertext, recovering the last plaintext byte from whether unpadding
+// succeeds under 256 forged input vectors
+// A forged byte v that unpads is taken for D(C)[15]^v == 0x01, unless
+// changing the byte before it breaks the padding, which means the padding
+// was longer. It returns the expected and the recovered byte and the set
+// of error messages that the failures gave
+func oracleAttack(unpad func([]byte, int) ([]byte, error)) (int, int, map[string]bool) {
+	block, err := aes.NewCipher(randomBytes(16))
+	check(err)
+	inputVec := randomBytes(aes.BlockSize)
+	plaintext, err := Pad([]byte("attack at dawn"), aes.BlockSize)
+	check(err)
+	enc, err := NewCBCEncrypter(block, inputVec)
+	check(err)
+	ciphertext := make([]byte, aes.BlockSize)
+	enc.CryptBlocks(ciphertext, plaintext)
+
+	errs := map[string]bool{}
+	oracle := func(forged []byte) bool {
+		dec, err := NewCBCDecrypter(block, forged)
+		check(err)
+		decrypted := make([]byte, aes.BlockSize)
+		dec.CryptBlocks(decrypted, ciphertext)
+		if _, err := unpad(decrypted, aes.BlockSize); err != nil {
+			errs[err.Error()] = true
+			return false
+		}
+		return true
+	}
+
+	recovered := -1
+	for v := 0; v < 256; v++ {
+		forged := append([]byte(nil), inputVec...)
+		forged[aes.BlockSize-1] = byte(v)
+		if !oracle(forged) {
+			continue
+		}
+		forged[aes.BlockSize-2] ^= 0xff
+		if oracle(forged) {
+			// D(C)[15]^v == 0x01, the plaintext byte is D(C)[15]^IV[15]
+			recovered = v ^ 0x01 ^ int(inputVec[aes.BlockSize-1])
+		}
+	}
+	return int(plaintext[aes.BlockSize-1]), recovered, errs
+}
+
+// TestPaddingUniformError checks what the constant-time unpadding provides:
+// every malformed input, of a wrong length or with a wrong padding, fails
+// with the same error, where the previous unpadding told them apart
+func TestPaddingUniformError(t *testing.T) {
+	_, _, errs := oracleAttack(verbosePKCS7Unpad)
+	if len(errs) < 2 {
+		t.Error("Expected several errors from the previous unpadding,got ", errs)
+	}
+
+	_, _, errs = oracleAttack(PKCS7{}.Unpad)
+	for _, length := range []int{0, 1, aes.BlockSize - 1, aes.BlockSize + 1} {
+		if _, err := (PKCS7{}).Unpad(make([]byte, length), aes.BlockSize); err != nil {
+			errs[err.Error()] = true
+		}
+	}
+	if len(errs) != 1 || !errs[ErrInvalidPadding.Error()] {
+		t.Error("Expected only ", ErrInvalidPadding, ",got ", errs)
+	}
+}
+
+// TestPaddingOracle documents what it does not provide: whether unpadding
+// succeeds is still observable and enough to recover the plaintext of
+// unauthenticated CBC, only authenticating the ciphertext prevents it
+func TestPaddingOracle(t *testing.T) {
+	for _, unpad := range []func([]byte, int) ([]byte, error){verbosePKCS7Unpad, PKCS7{}.Unpad} {
+		expected, recovered, _ := oracleAttack(unpad)
+		if recovered != expected {
+			t.Error("Expected the attack to recover ", expected, ",got ", recovered)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+/*
+	parallel.go
+
+	Optional parallel processing of the modes whose blocks are independent:
+	ECB encryption and decryption, CBC decryption and CTR. The input is split
+	into chunks that worker goroutines process concurrently, the output is
+	identical to the sequential path.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	parallel.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultChunkSize is the number of bytes per task if Concurrency.ChunkSize is 0
+const DefaultChunkSize = 64 * 1024
+
+// Concurrency is the class for the parallel processing settings
+// The zero value processes the input sequentially
+type Concurrency struct {
+	// Number of worker goroutines, 0 and 1 are sequential
+	Workers int
+	// Bytes per task, rounded down to whole blocks, DefaultChunkSize if 0
+	ChunkSize int
+}
+
+// parallel reports whether the settings use more than one goroutine
+func (c Concurrency) parallel() bool {
+	return c.Workers > 1
+}
+
+// chunkSize returns the chunk size rounded down to whole blocks, at least one
+func (c Concurrency) chunkSize(blockSize int) int {
+	size := c.ChunkSize
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	if size < blockSize {
+		return blockSize
+	}
+	return size - size%blockSize
+}
+
+// bufferSize returns the amount of input that keeps every worker busy
+func (c Concurrency) bufferSize(blockSize int) int {
+	if !c.parallel() {
+		return 0
+	}
+	return c.Workers * c.chunkSize(blockSize)
+}
+
+// run calls fn for the consecutive chunks of the first n bytes, n is a
+// multiple of the block size, the chunks are processed concurrently
+func (c Concurrency) run(n, blockSize int, fn func(start, end int)) {
+	size := c.chunkSize(blockSize)
+	chunks := (n + size - 1) / size
+	if !c.parallel() || chunks < 2 {
+		fn(0, n)
+		return
+	}
+
+	workers := c.Workers
+	if workers > chunks {
+		workers = chunks
+	}
+	var next int64
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				chunk := int(atomic.AddInt64(&next, 1) - 1)
+				if chunk >= chunks {
+					return
+				}
+				end := (chunk + 1) * size
+				if end > n {
+					end = n
+				}
+				fn(chunk*size, end)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// SetConcurrency sets the parallel processing of Encrypt and Decrypt
+func (ecb *ECB) SetConcurrency(c Concurrency) { ecb.concurrency = c }
+
+// SetConcurrency sets the parallel processing of Decrypt, CBC encryption
+// is inherently sequential
+func (cbc *CBC) SetConcurrency(c Concurrency) { cbc.concurrency = c }
+
+// SetConcurrency sets the parallel processing of CryptBlocks
+func (x *ECBEncrypter) SetConcurrency(c Concurrency) { x.concurrency = c }
+
+// SetConcurrency sets the parallel processing of CryptBlocks
+func (x *ECBDecrypter) SetConcurrency(c Concurrency) { x.concurrency = c }
+
+// SetConcurrency sets the parallel processing of CryptBlocks
+func (x *CBCDecrypter) SetConcurrency(c Concurrency) { x.concurrency = c }
+
+// SetConcurrency sets the parallel processing of the whole blocks of the
+// key stream in XORKeyStream
+func (ctr *CTR) SetConcurrency(c Concurrency) { ctr.concurrency = c }
+
+// addCounter adds n to a big-endian counter, wrapping within its width
+func addCounter(counter []byte, n uint64) {
+	for i := len(counter) - 1; i >= 0 && n > 0; i-- {
+		sum := uint64(counter[i]) + n&0xff
+		counter[i] = byte(sum)
+		n = n>>8 + sum>>8
+	}
+}
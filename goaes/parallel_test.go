@@ -0,0 +1,229 @@
+/*
+	parallel_test.go
+
+	The parallel paths must produce the output of the sequential ones for
+	every length, worker count and chunk size, in-place and across calls.
+	The benchmarks encrypt 100MB, the size of bigfile.txt, with 1 to 8 workers.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	parallel_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"fmt"
+	mrand "math/rand"
+	"testing"
+)
+
+// randomConcurrency returns parallel settings with small chunks, so that
+// short inputs are split as well
+func randomConcurrency(rng *mrand.Rand) Concurrency {
+	return Concurrency{Workers: 2 + rng.Intn(7), ChunkSize: 1 + rng.Intn(200)}
+}
+
+func TestParallelECB(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(1))
+	for i := 0; i < differentialRounds; i++ {
+		block := randomBlock(t, rng)
+		plaintext := randomBytes(aes.BlockSize * rng.Intn(200))
+
+		sequential, err := NewECB(block)
+		check(err)
+		parallel, err := NewECB(block)
+		check(err)
+		parallel.SetConcurrency(randomConcurrency(rng))
+
+		expected, err := sequential.Encrypt(plaintext)
+		check(err)
+		encrypted, err := parallel.Encrypt(plaintext)
+		check(err)
+		if !bytes.Equal(encrypted, expected) {
+			t.Fatal("Parallel ECB encryption differs for ", len(plaintext), " bytes")
+		}
+		decrypted, err := parallel.Decrypt(encrypted)
+		check(err)
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Fatal("Parallel ECB decryption differs for ", len(plaintext), " bytes")
+		}
+	}
+}
+
+func TestParallelCBCDecrypter(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(2))
+	for i := 0; i < differentialRounds; i++ {
+		block := randomBlock(t, rng)
+		inputVec := randomBytes(aes.BlockSize)
+		plaintext := randomBytes(aes.BlockSize * rng.Intn(200))
+		cbc, err := NewCBC(block, inputVec)
+		check(err)
+		ciphertext, err := cbc.Encrypt(plaintext)
+		check(err)
+
+		// In-place, split into two calls to exercise the carried input vector
+		dec, err := NewCBCDecrypter(block, inputVec)
+		check(err)
+		dec.SetConcurrency(randomConcurrency(rng))
+		decrypted := append([]byte(nil), ciphertext...)
+		split := aes.BlockSize * rng.Intn(len(ciphertext)/aes.BlockSize+1)
+		dec.CryptBlocks(decrypted[:split], decrypted[:split])
+		dec.CryptBlocks(decrypted[split:], decrypted[split:])
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Fatal("Parallel CBC decryption differs for ", len(plaintext), " bytes split at ", split)
+		}
+
+		cbc, err = NewCBC(block, inputVec)
+		check(err)
+		cbc.SetConcurrency(randomConcurrency(rng))
+		decrypted, err = cbc.Decrypt(ciphertext)
+		check(err)
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Fatal("Parallel CBC class decryption differs for ", len(plaintext), " bytes")
+		}
+	}
+}
+
+func TestParallelCTR(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(3))
+	for i := 0; i < differentialRounds; i++ {
+		block := randomBlock(t, rng)
+		// A counter about to carry into the higher bytes
+		inputVec := randomBytes(aes.BlockSize)
+		for j := aes.BlockSize - 2; j < aes.BlockSize; j++ {
+			inputVec[j] = 0xff
+		}
+		counterBits := []int{CounterFull, Counter32, 64}[rng.Intn(3)]
+		plaintext := randomBytes(rng.Intn(3000))
+
+		sequential, err := NewCTR(block, inputVec, counterBits)
+		check(err)
+		parallel, err := NewCTR(block, inputVec, counterBits)
+		check(err)
+		parallel.SetConcurrency(randomConcurrency(rng))
+
+		expected := sequential.Encrypt(plaintext)
+		// Unaligned calls leave part of a key stream block for the next one
+		encrypted := append([]byte(nil), plaintext...)
+		for rest := encrypted; len(rest) > 0; {
+			n := 1 + rng.Intn(len(rest))
+			parallel.XORKeyStream(rest[:n], rest[:n])
+			rest = rest[n:]
+		}
+		if !bytes.Equal(encrypted, expected) {
+			t.Fatal("Parallel CTR differs for ", len(plaintext), " bytes with a ", counterBits, "-bit counter")
+		}
+	}
+}
+
+func TestParallelCTRExhausted(t *testing.T) {
+	block, err := aes.NewCipher(randomBytes(16))
+	check(err)
+	ctr, err := NewCTR(block, make([]byte, aes.BlockSize), 8)
+	check(err)
+	ctr.SetConcurrency(Concurrency{Workers: 4, ChunkSize: 16})
+	ctr.XORKeyStream(make([]byte, 255*aes.BlockSize), make([]byte, 255*aes.BlockSize))
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic once the 8-bit counter is exhausted")
+		}
+	}()
+	ctr.XORKeyStream(make([]byte, 2*aes.BlockSize), make([]byte, 2*aes.BlockSize))
+}
+
+func TestParallelStreamWriter(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(4))
+	block := randomBlock(t, rng)
+	inputVec := randomBytes(aes.BlockSize)
+	plaintext := randomBytes(5*streamBufferSize + 3)
+	cbc, err := NewCBC(block, inputVec)
+	check(err)
+	padded, err := Pad(plaintext, aes.BlockSize)
+	check(err)
+	expected, err := cbc.Encrypt(padded)
+	check(err)
+
+	dec, err := NewCBCDecrypter(block, inputVec)
+	check(err)
+	dec.SetConcurrency(Concurrency{Workers: 4})
+	var decrypted bytes.Buffer
+	w := NewDecryptWriter(dec, PKCS7{}, &decrypted)
+	if cap(w.buf) < 4*DefaultChunkSize {
+		t.Error("Expected a buffer for 4 workers,got ", cap(w.buf))
+	}
+	check(writeChunks(t, rng, w, expected))
+	if !bytes.Equal(decrypted.Bytes(), plaintext) {
+		t.Error("Parallel stream decryption differs")
+	}
+}
+
+// bigFileSize is the size of bigfile.txt written by generate_big_file.go
+const bigFileSize = 104857600
+
+var benchWorkers = []int{1, 2, 4, 8}
+
+func benchmarkWorkers(b *testing.B, run func(c Concurrency, buf []byte)) {
+	buf := make([]byte, bigFileSize)
+	for _, workers := range benchWorkers {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			c := Concurrency{Workers: workers}
+			b.SetBytes(bigFileSize)
+			for i := 0; i < b.N; i++ {
+				run(c, buf)
+			}
+		})
+	}
+}
+
+func BenchmarkParallelECBEncrypt(b *testing.B) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	check(err)
+	benchmarkWorkers(b, func(c Concurrency, buf []byte) {
+		enc := ECBEncrypter{aes: block, blockSize: aes.BlockSize, concurrency: c}
+		enc.CryptBlocks(buf, buf)
+	})
+}
+
+func BenchmarkParallelECBDecrypt(b *testing.B) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	check(err)
+	benchmarkWorkers(b, func(c Concurrency, buf []byte) {
+		dec := ECBDecrypter{aes: block, blockSize: aes.BlockSize, concurrency: c}
+		dec.CryptBlocks(buf, buf)
+	})
+}
+
+func BenchmarkParallelCBCDecrypt(b *testing.B) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	check(err)
+	benchmarkWorkers(b, func(c Concurrency, buf []byte) {
+		dec, err := NewCBCDecrypter(block, make([]byte, aes.BlockSize))
+		check(err)
+		dec.SetConcurrency(c)
+		dec.CryptBlocks(buf, buf)
+	})
+}
+
+func BenchmarkParallelCTR(b *testing.B) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	check(err)
+	benchmarkWorkers(b, func(c Concurrency, buf []byte) {
+		ctr, err := NewCTR(block, make([]byte, aes.BlockSize), CounterFull)
+		check(err)
+		ctr.SetConcurrency(c)
+		ctr.XORKeyStream(buf, buf)
+	})
+}
@@ -0,0 +1,204 @@
+/*
+	siv.go
+
+	Implementation of the Synthetic Initialization Vector mode (AES-SIV) as
+	specified in RFC 5297, a deterministic and nonce misuse-resistant
+	authenticated encryption. S2V derives the synthetic IV from the
+	associated data and the plaintext with CMAC (NIST SP 800-38B), which
+	then serves as the tag and as the initial counter block of CTR.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	siv.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+)
+
+const sivBlockSize = 16
+
+// sivMaxComponents is the largest number of associated data components,
+// S2V takes at most 127 strings including the plaintext
+const sivMaxComponents = 126
+
+// ErrSIVKeySize is returned when the SIV key is not 32, 48 or 64 bytes long
+var ErrSIVKeySize = errors.New("goaes: SIV key must be 32, 48 or 64 bytes, two AES keys")
+
+// ErrSIVComponents is returned for more than 126 associated data components
+var ErrSIVComponents = errors.New("goaes: SIV takes at most 126 associated data components")
+
+var _ cipher.AEAD = (*SIV)(nil)
+
+// SIV is the class for the Synthetic Initialization Vector mode
+type SIV struct {
+	// K1 keys S2V, K2 keys CTR
+	mac *CMAC
+	aes cipher.Block
+}
+
+// NewSIV is a constructor for the SIV class
+// The double-length key is split in half, the first half keys S2V and the
+// second half CTR, cipherFunc is usually aes.NewCipher
+func NewSIV(cipherFunc func([]byte) (cipher.Block, error), key []byte) (*SIV, error) {
+	if len(key) != 32 && len(key) != 48 && len(key) != 64 {
+		return nil, ErrSIVKeySize
+	}
+	macBlock, err := cipherFunc(key[:len(key)/2])
+	if err != nil {
+		return nil, err
+	}
+	ctrBlock, err := cipherFunc(key[len(key)/2:])
+	if err != nil {
+		return nil, err
+	}
+	if macBlock.BlockSize() != sivBlockSize || ctrBlock.BlockSize() != sivBlockSize {
+		return nil, ErrBlockSize
+	}
+	mac, err := NewCMAC(macBlock)
+	if err != nil {
+		return nil, err
+	}
+	return &SIV{mac: mac, aes: ctrBlock}, nil
+}
+
+// NonceSize returns the recommended nonce size, Seal and Open take a
+// nonce of any length, including none for deterministic encryption
+func (siv *SIV) NonceSize() int { return sivBlockSize }
+
+// Overhead returns the difference between the lengths of a plaintext and
+// its ciphertext, the synthetic IV that precedes the ciphertext
+func (siv *SIV) Overhead() int { return sivBlockSize }
+
+// Seal encrypts and authenticates plaintext with additionalData and the
+// nonce as the two associated data components, as RFC 5297 section 6
+// defines for an AEAD, and appends the synthetic IV and the ciphertext to dst
+// An empty nonce is left out, the encryption is then deterministic
+func (siv *SIV) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	out, err := siv.SealComponents(dst, plaintext, siv.components(nonce, additionalData)...)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// Open authenticates and decrypts ciphertext as sealed by Seal and
+// appends the plaintext to dst, nothing is appended if it is not authentic
+func (siv *SIV) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	return siv.OpenComponents(dst, ciphertext, siv.components(nonce, additionalData)...)
+}
+
+func (siv *SIV) components(nonce, additionalData []byte) [][]byte {
+	if len(nonce) == 0 {
+		return [][]byte{additionalData}
+	}
+	return [][]byte{additionalData, nonce}
+}
+
+// SealComponents encrypts plaintext, authenticating it with the given
+// associated data components, and appends the synthetic IV and the
+// ciphertext to dst
+// A nonce, if any, is passed as the last component
+func (siv *SIV) SealComponents(dst, plaintext []byte, additionalData ...[]byte) ([]byte, error) {
+	if len(additionalData) > sivMaxComponents {
+		return nil, ErrSIVComponents
+	}
+	// The synthetic IV precedes the ciphertext, which is first encrypted
+	// on the side so that dst may share the plaintext's storage
+	v := siv.s2v(plaintext, additionalData)
+	ciphertext := make([]byte, len(plaintext))
+	siv.ctr(ciphertext, plaintext, v[:])
+
+	ret, out := sliceForAppend(dst, sivBlockSize+len(plaintext))
+	copy(out, v[:])
+	copy(out[sivBlockSize:], ciphertext)
+	return ret, nil
+}
+
+// OpenComponents decrypts ciphertext as sealed by SealComponents with the
+// same associated data components and appends the plaintext to dst if the
+// synthetic IV is valid
+func (siv *SIV) OpenComponents(dst, ciphertext []byte, additionalData ...[]byte) ([]byte, error) {
+	if len(additionalData) > sivMaxComponents {
+		return nil, ErrSIVComponents
+	}
+	if len(ciphertext) < sivBlockSize {
+		return nil, ErrOpen
+	}
+	var v [sivBlockSize]byte
+	copy(v[:], ciphertext)
+	ciphertext = ciphertext[sivBlockSize:]
+
+	// The plaintext is needed to recompute the synthetic IV, it is only
+	// released if the synthetic IV matches
+	plaintext := make([]byte, len(ciphertext))
+	siv.ctr(plaintext, ciphertext, v[:])
+	expected := siv.s2v(plaintext, additionalData)
+	if subtle.ConstantTimeCompare(expected[:], v[:]) != 1 {
+		for i := range plaintext {
+			plaintext[i] = 0
+		}
+		return nil, ErrOpen
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext))
+	copy(out, plaintext)
+	return ret, nil
+}
+
+// ctr encrypts src into dst with CTR, the counter block is the synthetic IV
+// with bits 63 and 31 cleared, so that implementations with 32 or 64-bit
+// counters agree
+func (siv *SIV) ctr(dst, src, v []byte) {
+	var q [sivBlockSize]byte
+	copy(q[:], v)
+	q[8] &= 0x7f
+	q[12] &= 0x7f
+
+	ctr, err := NewCTR(siv.aes, q[:], CounterFull)
+	if err != nil {
+		panic(err)
+	}
+	ctr.XORKeyStream(dst, src)
+}
+
+// s2v is the S2V function of RFC 5297 section 2.4, a pseudorandom function
+// of a vector of strings: the associated data components and the plaintext
+func (siv *SIV) s2v(plaintext []byte, additionalData [][]byte) [sivBlockSize]byte {
+	var zero [sivBlockSize]byte
+	d := siv.mac.sum(zero[:])
+	for _, component := range additionalData {
+		dbl(&d)
+		s := siv.mac.sum(component)
+		xor(d[:], d[:], s[:])
+	}
+
+	var t []byte
+	if len(plaintext) >= sivBlockSize {
+		// T = plaintext xorend D
+		t = append([]byte(nil), plaintext...)
+		end := t[len(t)-sivBlockSize:]
+		xor(end, end, d[:])
+	} else {
+		// T = dbl(D) xor pad(plaintext), pad appends 0x80 and zeros
+		dbl(&d)
+		t = d[:]
+		xor(t[:len(plaintext)], t[:len(plaintext)], plaintext)
+		t[len(plaintext)] ^= 0x80
+	}
+	return siv.mac.sum(t)
+}
@@ -0,0 +1,188 @@
+/*
+	siv_test.go
+
+	Tests of AES-SIV with the RFC 5297 Appendix A vectors, tampering,
+	determinism and the associated data components.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	siv_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	mrand "math/rand"
+	"testing"
+)
+
+// RFC 5297 Appendix A, the nonce of A.2 is the last component
+var sivtests = []struct {
+	name       string
+	key        string
+	components []string
+	plaintext  string
+	ciphertext string
+}{
+	{
+		"A.1 Deterministic Authenticated Encryption",
+		"fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff",
+		[]string{"101112131415161718191a1b1c1d1e1f2021222324252627"},
+		"112233445566778899aabbccddee",
+		"85632d07c6e8f37f950acd320a2ecc9340c02b9690c4dc04daef7f6afe5c",
+	},
+	{
+		"A.2 Nonce-Based Authenticated Encryption",
+		"7f7e7d7c7b7a79787776757473727170404142434445464748494a4b4c4d4e4f",
+		[]string{
+			"00112233445566778899aabbccddeeffdeaddadadeaddadaffeeddccbbaa99887766554433221100",
+			"102030405060708090a0",
+			"09f911029d74e35bd84156c5635688c0",
+		},
+		"7468697320697320736f6d6520706c61696e7465787420746f20656e6372797074207573696e67205349562d414553",
+		"7bdb6e3b432667eb06f4d14bff2fbd0fcb900f2fddbe404326601965c889bf17dba77ceb094fa663b7a3f748ba8af829ea64ad544a272e9c485b62a3fd5c0d",
+	},
+}
+
+func TestSIVVectors(t *testing.T) {
+	for _, test := range sivtests {
+		siv, err := NewSIV(katCipher, decodehex([]byte(test.key)))
+		check(err)
+		var components [][]byte
+		for _, component := range test.components {
+			components = append(components, decodehex([]byte(component)))
+		}
+		plaintext := decodehex([]byte(test.plaintext))
+		expected := decodehex([]byte(test.ciphertext))
+
+		sealed, err := siv.SealComponents(nil, plaintext, components...)
+		if err != nil || !bytes.Equal(sealed, expected) {
+			t.Error(test.name, ": expected ", test.ciphertext, ",got ", string(encodehex(sealed)))
+		}
+		opened, err := siv.OpenComponents(nil, expected, components...)
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Error(test.name, ": decryption failed with error ", err)
+		}
+	}
+}
+
+func TestSIVNonce(t *testing.T) {
+	siv, err := NewSIV(aes.NewCipher, randomBytes(32))
+	check(err)
+	plaintext := randomBytes(40)
+	aad := randomBytes(10)
+
+	// Without a nonce equal plaintexts give equal ciphertexts
+	first := siv.Seal(nil, nil, plaintext, aad)
+	if second := siv.Seal(nil, nil, plaintext, aad); !bytes.Equal(first, second) {
+		t.Error("Expected deterministic encryption without a nonce")
+	}
+	expected, err := siv.SealComponents(nil, plaintext, aad)
+	check(err)
+	if !bytes.Equal(first, expected) {
+		t.Error("Expected the additional data as the only component without a nonce")
+	}
+
+	nonce := randomBytes(siv.NonceSize())
+	withNonce := siv.Seal(nil, nonce, plaintext, aad)
+	if bytes.Equal(withNonce, first) {
+		t.Error("Expected the nonce to change the ciphertext")
+	}
+	expected, err = siv.SealComponents(nil, plaintext, aad, nonce)
+	check(err)
+	if !bytes.Equal(withNonce, expected) {
+		t.Error("Expected the nonce as the last component")
+	}
+	if _, err := siv.Open(nil, nil, withNonce, aad); err != ErrOpen {
+		t.Error("Expected ErrOpen without the nonce, got ", err)
+	}
+}
+
+func TestSIVTampering(t *testing.T) {
+	siv, err := NewSIV(aes.NewCipher, randomBytes(64))
+	check(err)
+	components := [][]byte{randomBytes(20), randomBytes(5), randomBytes(16)}
+	sealed, err := siv.SealComponents(nil, randomBytes(33), components...)
+	check(err)
+
+	for i := 0; i < len(sealed)*8; i++ {
+		tampered := append([]byte(nil), sealed...)
+		tampered[i/8] ^= 1 << uint(i%8)
+		if out, err := siv.OpenComponents(nil, tampered, components...); err != ErrOpen || out != nil {
+			t.Fatal("Expected ErrOpen and no output after flipping bit ", i)
+		}
+	}
+
+	// The components are authenticated as a vector, with their order and boundaries
+	altered := [][][]byte{
+		components[:2],
+		{components[1], components[0], components[2]},
+		{append(append([]byte(nil), components[0]...), components[1]...), components[2]},
+		{components[0], components[1], components[2], nil},
+	}
+	for i, other := range altered {
+		if _, err := siv.OpenComponents(nil, sealed, other...); err != ErrOpen {
+			t.Error("Expected ErrOpen for altered components ", i, ", got ", err)
+		}
+	}
+	for n := 0; n < siv.Overhead(); n++ {
+		if _, err := siv.OpenComponents(nil, sealed[:n], components...); err != ErrOpen {
+			t.Error("Expected ErrOpen for ", n, " bytes, got ", err)
+		}
+	}
+}
+
+func TestSIVRoundTrip(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(10))
+	for i := 0; i < differentialRounds; i++ {
+		siv, err := NewSIV(aes.NewCipher, randomBytes([]int{32, 48, 64}[rng.Intn(3)]))
+		check(err)
+		nonce := randomBytes(rng.Intn(2) * siv.NonceSize())
+		plaintext := randomBytes(rng.Intn(100))
+		aad := randomBytes(rng.Intn(40))
+
+		sealed := siv.Seal(nil, nonce, plaintext, aad)
+		// In place, the plaintext buffer receives the ciphertext
+		buf := append(make([]byte, 0, len(plaintext)+siv.Overhead()), plaintext...)
+		if inPlace := siv.Seal(buf[:0], nonce, buf, aad); !bytes.Equal(inPlace, sealed) {
+			t.Fatal("In-place encryption differs")
+		}
+		opened, err := siv.Open(sealed[:0], nonce, sealed, aad)
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Fatal("Round trip failed with error ", err)
+		}
+	}
+}
+
+func TestSIVErrors(t *testing.T) {
+	for _, keySize := range []int{0, 16, 24, 31, 33, 65} {
+		if _, err := NewSIV(aes.NewCipher, make([]byte, keySize)); err != ErrSIVKeySize {
+			t.Error("Expected ErrSIVKeySize for ", keySize, " bytes, got ", err)
+		}
+	}
+
+	siv, err := NewSIV(aes.NewCipher, make([]byte, 32))
+	check(err)
+	components := make([][]byte, sivMaxComponents+1)
+	if _, err := siv.SealComponents(nil, nil, components[:sivMaxComponents]...); err != nil {
+		t.Error("Expected ", sivMaxComponents, " components to be accepted, got ", err)
+	}
+	if _, err := siv.SealComponents(nil, nil, components...); err != ErrSIVComponents {
+		t.Error("Expected ErrSIVComponents, got ", err)
+	}
+	if _, err := siv.OpenComponents(nil, make([]byte, 16), components...); err != ErrSIVComponents {
+		t.Error("Expected ErrSIVComponents, got ", err)
+	}
+}
@@ -0,0 +1,233 @@
+/*
+	stream.go
+
+	Writers that encrypt or decrypt a stream with the block modes in bounded
+	memory. The stream modes (CTR, CFB, OFB) already implement cipher.Stream
+	and are used with cipher.StreamReader and cipher.StreamWriter.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	stream.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/cipher"
+	"errors"
+	"io"
+)
+
+// streamBufferSize is the approximate amount of input buffered by the
+// writers, it is rounded up to whole blocks or sectors
+const streamBufferSize = 64 * 1024
+
+// ErrWriterClosed is returned when writing to a closed writer
+var ErrWriterClosed = errors.New("goaes: write to closed writer")
+
+// ErrXTSTooShort is returned when the last XTS data unit is shorter than one block
+var ErrXTSTooShort = errors.New("goaes: last XTS data unit shorter than one block")
+
+var _ io.WriteCloser = (*StreamWriter)(nil)
+
+// StreamWriter is the class for the writers returned by the constructors
+// below. It buffers the input and writes whole chunks to the underlying
+// writer, the buffered rest, and the padding if any, are handled by Close
+// Close does not close the underlying writer
+type StreamWriter struct {
+	w   io.Writer
+	buf []byte
+	// Bytes held back from the chunks for Close, e.g. the padded block
+	keep  int
+	chunk func(buf []byte)
+	final func(buf []byte) ([]byte, error)
+	err   error
+}
+
+// newStreamWriter allocates the buffer, a multiple of unit larger than keep
+// and at least size bytes, or streamBufferSize
+func newStreamWriter(w io.Writer, unit, keep, size int) *StreamWriter {
+	if size < streamBufferSize {
+		size = streamBufferSize
+	}
+	size = (size + unit - 1) / unit * unit
+	for size <= keep {
+		size += unit
+	}
+	return &StreamWriter{w: w, buf: make([]byte, 0, size), keep: keep}
+}
+
+// modeBufferSize returns the buffer size that keeps the workers of a
+// parallel block mode busy, 0 for a sequential one
+func modeBufferSize(mode cipher.BlockMode) int {
+	switch x := mode.(type) {
+	case *ECBEncrypter:
+		return x.concurrency.bufferSize(x.blockSize)
+	case *ECBDecrypter:
+		return x.concurrency.bufferSize(x.blockSize)
+	case *CBCDecrypter:
+		return x.concurrency.bufferSize(x.blockSize)
+	}
+	return 0
+}
+
+// NewEncryptWriter returns a writer that encrypts with the block mode and
+// writes the ciphertext to w, Close pads the final block
+func NewEncryptWriter(mode cipher.BlockMode, padding Padding, w io.Writer) *StreamWriter {
+	sw := newStreamWriter(w, mode.BlockSize(), 0, modeBufferSize(mode))
+	sw.chunk = func(buf []byte) { mode.CryptBlocks(buf, buf) }
+	sw.final = func(buf []byte) ([]byte, error) {
+		buf, err := padding.Pad(buf, mode.BlockSize())
+		if err != nil {
+			return nil, err
+		}
+		mode.CryptBlocks(buf, buf)
+		return buf, nil
+	}
+	return sw
+}
+
+// NewDecryptWriter returns a writer that decrypts with the block mode and
+// writes the plaintext to w
+// The last block is held back until Close, which removes the padding
+// Everything before it is written unauthenticated, as soon as it is decrypted
+func NewDecryptWriter(mode cipher.BlockMode, padding Padding, w io.Writer) *StreamWriter {
+	sw := newStreamWriter(w, mode.BlockSize(), mode.BlockSize(), modeBufferSize(mode))
+	sw.chunk = func(buf []byte) { mode.CryptBlocks(buf, buf) }
+	sw.final = func(buf []byte) ([]byte, error) {
+		if err := checkFullBlocks(buf, mode.BlockSize()); err != nil {
+			return nil, err
+		}
+		mode.CryptBlocks(buf, buf)
+		return padding.Unpad(buf, mode.BlockSize())
+	}
+	return sw
+}
+
+// NewCBCCSEncryptWriter returns a writer that encrypts with ciphertext
+// stealing, the last two blocks are held back until Close
+func NewCBCCSEncryptWriter(cs *CBCCS, w io.Writer) *StreamWriter {
+	sw := newStreamWriter(w, cs.blockSize, 2*cs.blockSize, 0)
+	enc := CBCEncrypter{aes: cs.aes, blockSize: cs.blockSize, inputVec: append([]byte(nil), cs.inputVec...)}
+	sw.chunk = func(buf []byte) { enc.CryptBlocks(buf, buf) }
+	sw.final = func(buf []byte) ([]byte, error) {
+		// The rest is a message of its own, chained to the written blocks
+		tail := CBCCS{aes: cs.aes, blockSize: cs.blockSize, variant: cs.variant, inputVec: enc.inputVec}
+		return tail.Encrypt(buf)
+	}
+	return sw
+}
+
+// NewCBCCSDecryptWriter returns a writer that decrypts with ciphertext
+// stealing, the last two blocks are held back until Close
+func NewCBCCSDecryptWriter(cs *CBCCS, w io.Writer) *StreamWriter {
+	sw := newStreamWriter(w, cs.blockSize, 2*cs.blockSize, 0)
+	dec := CBCDecrypter{aes: cs.aes, blockSize: cs.blockSize, inputVec: append([]byte(nil), cs.inputVec...)}
+	sw.chunk = func(buf []byte) { dec.CryptBlocks(buf, buf) }
+	sw.final = func(buf []byte) ([]byte, error) {
+		tail := CBCCS{aes: cs.aes, blockSize: cs.blockSize, variant: cs.variant, inputVec: dec.inputVec}
+		return tail.Decrypt(buf)
+	}
+	return sw
+}
+
+// NewXTSEncryptWriter returns a writer that encrypts data units of
+// sectorSize bytes, numbered from zero
+// The last data unit may be shorter, but not shorter than one block
+func NewXTSEncryptWriter(xts *XTS, sectorSize int, w io.Writer) *StreamWriter {
+	return newXTSWriter(xts, sectorSize, w, false)
+}
+
+// NewXTSDecryptWriter returns a writer that decrypts data units of
+// sectorSize bytes, see NewXTSEncryptWriter
+func NewXTSDecryptWriter(xts *XTS, sectorSize int, w io.Writer) *StreamWriter {
+	return newXTSWriter(xts, sectorSize, w, true)
+}
+
+func newXTSWriter(xts *XTS, sectorSize int, w io.Writer, decrypt bool) *StreamWriter {
+	if sectorSize < xtsBlockSize {
+		panic("goaes: XTS data unit shorter than one block")
+	}
+	sw := newStreamWriter(w, sectorSize, 0, 0)
+	var sector uint64
+	crypt := func(buf []byte) {
+		for start := 0; start < len(buf); start += sectorSize {
+			end := start + sectorSize
+			if end > len(buf) {
+				end = len(buf)
+			}
+			xts.crypt(buf[start:end], buf[start:end], sectorTweak(sector), decrypt)
+			sector++
+		}
+	}
+	sw.chunk = crypt
+	sw.final = func(buf []byte) ([]byte, error) {
+		if len(buf)%sectorSize != 0 && len(buf)%sectorSize < xtsBlockSize {
+			return nil, ErrXTSTooShort
+		}
+		crypt(buf)
+		return buf, nil
+	}
+	return sw
+}
+
+// Write buffers p and processes every full buffer, except for the held
+// back bytes
+func (sw *StreamWriter) Write(p []byte) (int, error) {
+	if sw.err != nil {
+		return 0, sw.err
+	}
+	n := 0
+	for len(p) > 0 {
+		m := copy(sw.buf[len(sw.buf):cap(sw.buf)], p)
+		sw.buf = sw.buf[:len(sw.buf)+m]
+		p = p[m:]
+		n += m
+		if len(sw.buf) == cap(sw.buf) {
+			if err := sw.flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// flush processes and writes the full buffer without the held back bytes,
+// which move to its front
+func (sw *StreamWriter) flush() error {
+	done := len(sw.buf) - sw.keep
+	sw.chunk(sw.buf[:done])
+	if _, err := sw.w.Write(sw.buf[:done]); err != nil {
+		sw.err = err
+		return err
+	}
+	sw.buf = sw.buf[:copy(sw.buf, sw.buf[done:])]
+	return nil
+}
+
+// Close processes the buffered rest and writes it to the underlying writer
+func (sw *StreamWriter) Close() error {
+	if sw.err != nil {
+		if sw.err == ErrWriterClosed {
+			return nil
+		}
+		return sw.err
+	}
+	sw.err = ErrWriterClosed
+	out, err := sw.final(sw.buf)
+	if err != nil {
+		return err
+	}
+	_, err = sw.w.Write(out)
+	return err
+}
@@ -0,0 +1,275 @@
+/*
+	stream_test.go
+
+	The writers must produce the one-shot result for every length and every
+	split of the input into writes.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	stream_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"io"
+	mrand "math/rand"
+	"testing"
+)
+
+// streamLengths cross the buffer boundary, where blocks are held back
+var streamLengths = []int{0, 1, 15, 16, 17, 31, 32, 33, 1000,
+	streamBufferSize - 17, streamBufferSize - 16, streamBufferSize, streamBufferSize + 1,
+	streamBufferSize + 16, streamBufferSize + 33, 3*streamBufferSize + 5}
+
+// writeChunks writes src to w in randomly sized writes and closes it
+func writeChunks(t *testing.T, rng *mrand.Rand, w io.WriteCloser, src []byte) error {
+	for len(src) > 0 {
+		n := 1 + rng.Intn(2*streamBufferSize)
+		if rng.Intn(2) == 0 {
+			n = 1 + rng.Intn(40)
+		}
+		if n > len(src) {
+			n = len(src)
+		}
+		if m, err := w.Write(src[:n]); err != nil || m != n {
+			t.Fatal("Write returned ", m, " of ", n, " error ", err)
+		}
+		src = src[n:]
+	}
+	return w.Close()
+}
+
+func TestStreamBlockModes(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(1))
+	for _, length := range streamLengths {
+		block := randomBlock(t, rng)
+		inputVec := randomBytes(aes.BlockSize)
+		plaintext := randomBytes(length)
+
+		ecb, err := NewECB(block)
+		check(err)
+		cbc, err := NewCBC(block, inputVec)
+		check(err)
+		padded, err := PKCS7{}.Pad(plaintext, aes.BlockSize)
+		check(err)
+		ecbExpected, err := ecb.Encrypt(padded)
+		check(err)
+		cbcExpected, err := cbc.Encrypt(padded)
+		check(err)
+
+		newModes := []func() (cipher.BlockMode, cipher.BlockMode, []byte){
+			func() (cipher.BlockMode, cipher.BlockMode, []byte) {
+				enc, err := NewECBEncrypter(block)
+				check(err)
+				dec, err := NewECBDecrypter(block)
+				check(err)
+				return enc, dec, ecbExpected
+			},
+			func() (cipher.BlockMode, cipher.BlockMode, []byte) {
+				enc, err := NewCBCEncrypter(block, inputVec)
+				check(err)
+				dec, err := NewCBCDecrypter(block, inputVec)
+				check(err)
+				return enc, dec, cbcExpected
+			},
+		}
+		for _, newMode := range newModes {
+			enc, dec, expected := newMode()
+			var encrypted, decrypted bytes.Buffer
+			check(writeChunks(t, rng, NewEncryptWriter(enc, PKCS7{}, &encrypted), plaintext))
+			if !bytes.Equal(encrypted.Bytes(), expected) {
+				t.Fatal("Length ", length, " ciphertext differs from the one-shot encryption")
+			}
+			check(writeChunks(t, rng, NewDecryptWriter(dec, PKCS7{}, &decrypted), expected))
+			if !bytes.Equal(decrypted.Bytes(), plaintext) {
+				t.Fatal("Length ", length, " plaintext differs from the input")
+			}
+		}
+	}
+}
+
+func TestStreamDecryptErrors(t *testing.T) {
+	block, err := aes.NewCipher(randomBytes(16))
+	check(err)
+	enc, err := NewECBEncrypter(block)
+	check(err)
+	var encrypted bytes.Buffer
+	w := NewEncryptWriter(enc, PKCS7{}, &encrypted)
+	w.Write(randomBytes(100))
+	check(w.Close())
+	if _, err := w.Write([]byte{0}); err != ErrWriterClosed {
+		t.Error("Expected ErrWriterClosed, got ", err)
+	}
+
+	// A truncated ciphertext does not fill the blocks
+	dec, err := NewECBDecrypter(block)
+	check(err)
+	w = NewDecryptWriter(dec, PKCS7{}, io.Discard)
+	w.Write(encrypted.Bytes()[:encrypted.Len()-1])
+	if err := w.Close(); !errors.Is(err, ErrNotFullBlocks) {
+		t.Error("Expected ErrNotFullBlocks, got ", err)
+	}
+
+	// Corrupting the last block breaks the padding
+	tampered := encrypted.Bytes()
+	tampered[len(tampered)-1] ^= 1
+	w = NewDecryptWriter(dec, PKCS7{}, io.Discard)
+	w.Write(tampered)
+	if err := w.Close(); err != ErrInvalidPadding {
+		t.Error("Expected ErrInvalidPadding, got ", err)
+	}
+
+	// Unpadded encryption of a partial block fails on Close
+	w = NewEncryptWriter(enc, NoPadding{}, io.Discard)
+	w.Write(randomBytes(17))
+	if err := w.Close(); !errors.Is(err, ErrNotFullBlocks) {
+		t.Error("Expected ErrNotFullBlocks, got ", err)
+	}
+}
+
+func TestStreamCBCCS(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(2))
+	for _, variant := range []int{CS1, CS2, CS3} {
+		for _, length := range streamLengths[3:] {
+			cs, err := NewCBCCS(randomBlock(t, rng), randomBytes(aes.BlockSize), variant)
+			check(err)
+			plaintext := randomBytes(length)
+			expected, err := cs.Encrypt(plaintext)
+			check(err)
+
+			var encrypted, decrypted bytes.Buffer
+			check(writeChunks(t, rng, NewCBCCSEncryptWriter(cs, &encrypted), plaintext))
+			if !bytes.Equal(encrypted.Bytes(), expected) {
+				t.Fatal("CS", variant, " length ", length, " ciphertext differs from the one-shot encryption")
+			}
+			check(writeChunks(t, rng, NewCBCCSDecryptWriter(cs, &decrypted), expected))
+			if !bytes.Equal(decrypted.Bytes(), plaintext) {
+				t.Fatal("CS", variant, " length ", length, " plaintext differs from the input")
+			}
+		}
+	}
+
+	cs, err := NewCBCCS(randomBlock(t, rng), randomBytes(aes.BlockSize), CS3)
+	check(err)
+	w := NewCBCCSEncryptWriter(cs, io.Discard)
+	w.Write(randomBytes(15))
+	if err := w.Close(); err != ErrCSTooShort {
+		t.Error("Expected ErrCSTooShort, got ", err)
+	}
+}
+
+func TestStreamXTS(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(3))
+	for _, sectorSize := range []int{16, 512, 4096, 100000} {
+		for _, length := range streamLengths[3:] {
+			if length%sectorSize != 0 && length%sectorSize < xtsBlockSize {
+				continue
+			}
+			xts, err := NewXTS(aes.NewCipher, randomBytes(64))
+			check(err)
+			plaintext := randomBytes(length)
+			expected := make([]byte, length)
+			for sector := 0; sector*sectorSize < length; sector++ {
+				end := (sector + 1) * sectorSize
+				if end > length {
+					end = length
+				}
+				xts.Encrypt(expected[sector*sectorSize:end], plaintext[sector*sectorSize:end], uint64(sector))
+			}
+
+			var encrypted, decrypted bytes.Buffer
+			check(writeChunks(t, rng, NewXTSEncryptWriter(xts, sectorSize, &encrypted), plaintext))
+			if !bytes.Equal(encrypted.Bytes(), expected) {
+				t.Fatal("Sector size ", sectorSize, " length ", length, " ciphertext differs")
+			}
+			check(writeChunks(t, rng, NewXTSDecryptWriter(xts, sectorSize, &decrypted), expected))
+			if !bytes.Equal(decrypted.Bytes(), plaintext) {
+				t.Fatal("Sector size ", sectorSize, " length ", length, " plaintext differs")
+			}
+		}
+	}
+
+	xts, err := NewXTS(aes.NewCipher, randomBytes(32))
+	check(err)
+	w := NewXTSEncryptWriter(xts, 512, io.Discard)
+	w.Write(randomBytes(512 + 15))
+	if err := w.Close(); err != ErrXTSTooShort {
+		t.Error("Expected ErrXTSTooShort, got ", err)
+	}
+}
+
+func TestStreamModes(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(4))
+	block := randomBlock(t, rng)
+	inputVec := randomBytes(aes.BlockSize)
+	plaintext := randomBytes(3*streamBufferSize + 5)
+
+	ctr, err := NewCTR(block, inputVec, CounterFull)
+	check(err)
+	cfb, err := NewCFB(block, inputVec, CFB8)
+	check(err)
+	ofb, err := NewOFB(block, inputVec)
+	check(err)
+	expected := [][]byte{ctr.Encrypt(plaintext), cfb.Encrypt(plaintext), ofb.Encrypt(plaintext)}
+
+	newStreams := func() ([]cipher.Stream, []cipher.Stream) {
+		ctrEnc, err := NewCTR(block, inputVec, CounterFull)
+		check(err)
+		ctrDec, err := NewCTR(block, inputVec, CounterFull)
+		check(err)
+		cfbEnc, err := NewCFBEncrypter(block, inputVec, CFB8)
+		check(err)
+		cfbDec, err := NewCFBDecrypter(block, inputVec, CFB8)
+		check(err)
+		ofbEnc, err := NewOFB(block, inputVec)
+		check(err)
+		ofbDec, err := NewOFB(block, inputVec)
+		check(err)
+		return []cipher.Stream{ctrEnc, cfbEnc, ofbEnc}, []cipher.Stream{ctrDec, cfbDec, ofbDec}
+	}
+
+	encStreams, decStreams := newStreams()
+	for i := range encStreams {
+		// Encrypt through a writer, decrypt through a reader
+		var encrypted bytes.Buffer
+		check(writeChunks(t, rng, cipher.StreamWriter{S: encStreams[i], W: &encrypted}, plaintext))
+		if !bytes.Equal(encrypted.Bytes(), expected[i]) {
+			t.Fatal("Stream ", i, " ciphertext differs from the one-shot encryption")
+		}
+		decrypted, err := io.ReadAll(cipher.StreamReader{S: decStreams[i], R: &encrypted})
+		check(err)
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Fatal("Stream ", i, " plaintext differs from the input")
+		}
+	}
+}
+
+func TestStreamBoundedMemory(t *testing.T) {
+	block, err := aes.NewCipher(randomBytes(16))
+	check(err)
+	enc, err := NewCBCEncrypter(block, randomBytes(aes.BlockSize))
+	check(err)
+	w := NewEncryptWriter(enc, PKCS7{}, io.Discard)
+	chunk := make([]byte, 1<<20)
+	allocs := testing.AllocsPerRun(16, func() {
+		w.Write(chunk)
+	})
+	if allocs != 0 || cap(w.buf) > 2*streamBufferSize {
+		t.Error("Expected a fixed buffer, got ", allocs, " allocations and capacity ", cap(w.buf))
+	}
+}
@@ -1,8 +1,7 @@
 /*
 	utils.go
 
-	Utility script for reading, writing files, hex encoding/decoding,
-	padding, unpadding and element-wise XORing (chaining) of two byte arrays
+	PKCS#7 padding shorthands and element-wise XORing (chaining) of two byte arrays
 
 	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
 	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
@@ -19,66 +18,25 @@
 	utils.go Daniel Havir, 2018
 */
 
-package main
+package goaes
 
 import (
-	"bytes"
-	hex "encoding/hex"
-	"io/ioutil"
+	"errors"
 )
 
-func check(e error) {
-	if e != nil {
-		panic(e)
-	}
-}
-
-func readfile(path string) []byte {
-	dat, err := ioutil.ReadFile(path)
-	check(err)
-	return dat
-}
-
-func writefile(text []byte, path string) {
-	err := ioutil.WriteFile(path, text, 0664)
-	check(err)
-}
-
-func decodehex(src []byte) []byte {
-	dst := make([]byte, hex.DecodedLen(len(src)))
-	hex.Decode(dst, src)
-	return dst
-}
-
-func encodehex(src []byte) []byte {
-	dst := make([]byte, hex.EncodedLen(len(src)))
-	hex.Encode(dst, src)
-	return dst
-}
-
-func readhexfile(path string) []byte {
-	src := readfile(path)
-	dst := decodehex(src)
-	return dst
-}
-
-func writehexfile(src []byte, path string) {
-	text := encodehex(src)
-	writefile(text, path)
-}
+// ErrInvalidPadding is returned when the padding of a decrypted text is malformed
+var ErrInvalidPadding = errors.New("goaes: invalid padding")
 
-func pad(src []byte, blockSize int) []byte {
-	pad := blockSize - len(src)%blockSize
-	fill := bytes.Repeat([]byte{byte(pad)}, pad)
-	// Reference: https://golang.org/ref/spec#Passing_arguments_to_..._parameters
-	src = append(src, fill...)
-	return src
+// Pad returns src with PKCS#7 padding, a shorthand for PKCS7.Pad
+func Pad(src []byte, blockSize int) ([]byte, error) {
+	return PKCS7{}.Pad(src, blockSize)
 }
 
-func unpad(src []byte) []byte {
-	unpad := int(src[len(src)-1])
-	src = src[:(len(src) - unpad)]
-	return src
+// Unpad removes PKCS#7 padding from src in constant time, a shorthand for
+// PKCS7.Unpad that does not know the block size, so it accepts any
+// padding length up to 255 bytes
+func Unpad(src []byte) ([]byte, error) {
+	return unpadPKCS7(src, 255)
 }
 
 // Inplace XOR operation
@@ -0,0 +1,167 @@
+/*
+	xts.go
+
+	Implementation of the XEX-based tweaked-codebook mode with ciphertext
+	stealing (XTS), as specified in IEEE 1619 and NIST SP 800-38E, for
+	encrypting fixed-size data units such as disk sectors.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	xts.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+)
+
+const xtsBlockSize = 16
+
+// ErrXTSKeySize is returned when the XTS key is not 256 or 512 bits long
+var ErrXTSKeySize = errors.New("goaes: XTS key must be 32 or 64 bytes, two AES-128 or AES-256 keys")
+
+// XTS is the class for the XEX-based tweaked-codebook mode with ciphertext stealing
+type XTS struct {
+	// Key1 encrypts the data, Key2 encrypts the tweak
+	aes   cipher.Block
+	tweak cipher.Block
+}
+
+// NewXTS is a constructor for the XTS class
+// The double-length key is split in half, the first half keys the data
+// cipher and the second half the tweak cipher, cipherFunc is usually aes.NewCipher
+func NewXTS(cipherFunc func([]byte) (cipher.Block, error), key []byte) (*XTS, error) {
+	if len(key) != 32 && len(key) != 64 {
+		return nil, ErrXTSKeySize
+	}
+	data, err := cipherFunc(key[:len(key)/2])
+	if err != nil {
+		return nil, err
+	}
+	tweak, err := cipherFunc(key[len(key)/2:])
+	if err != nil {
+		return nil, err
+	}
+	if data.BlockSize() != xtsBlockSize || tweak.BlockSize() != xtsBlockSize {
+		return nil, ErrBlockSize
+	}
+	return &XTS{aes: data, tweak: tweak}, nil
+}
+
+// Encrypt encrypts the data unit src into dst, the tweak is the data
+// unit (sector) number encoded as a 128-bit little-endian integer
+// dst and src may be the same slice, src must be at least one block long
+func (xts *XTS) Encrypt(dst, src []byte, sectorNum uint64) {
+	xts.EncryptTweak(dst, src, sectorTweak(sectorNum))
+}
+
+// Decrypt decrypts the data unit src into dst, see Encrypt
+func (xts *XTS) Decrypt(dst, src []byte, sectorNum uint64) {
+	xts.DecryptTweak(dst, src, sectorTweak(sectorNum))
+}
+
+// EncryptTweak encrypts the data unit src into dst with an explicit
+// 16-byte tweak value
+func (xts *XTS) EncryptTweak(dst, src, tweak []byte) {
+	xts.crypt(dst, src, tweak, false)
+}
+
+// DecryptTweak decrypts the data unit src into dst with an explicit
+// 16-byte tweak value
+func (xts *XTS) DecryptTweak(dst, src, tweak []byte) {
+	xts.crypt(dst, src, tweak, true)
+}
+
+// sectorTweak encodes the data unit number as IEEE 1619 prescribes
+func sectorTweak(sectorNum uint64) []byte {
+	tweak := make([]byte, xtsBlockSize)
+	binary.LittleEndian.PutUint64(tweak, sectorNum)
+	return tweak
+}
+
+func (xts *XTS) crypt(dst, src, tweak []byte, decrypt bool) {
+	if len(tweak) != xtsBlockSize {
+		panic("goaes: XTS tweak must be 16 bytes")
+	}
+	if len(src) < xtsBlockSize {
+		panic("goaes: XTS data unit shorter than one block")
+	}
+	if len(dst) < len(src) {
+		panic("goaes: output smaller than input")
+	}
+	if inexactOverlap(dst[:len(src)], src) {
+		panic("goaes: invalid buffer overlap")
+	}
+
+	var t [xtsBlockSize]byte
+	xts.tweak.Encrypt(t[:], tweak)
+
+	// With a partial final block, the last full block takes part in
+	// the ciphertext stealing and is processed separately
+	full := len(src) / xtsBlockSize
+	tail := len(src) % xtsBlockSize
+	if tail != 0 {
+		full--
+	}
+	for i := 0; i < full; i++ {
+		xts.cryptBlock(dst[i*xtsBlockSize:], src[i*xtsBlockSize:], &t, decrypt)
+		mulAlpha(&t)
+	}
+	if tail == 0 {
+		return
+	}
+
+	// The two tweaks are applied in reverse order on decryption
+	first, second := t, t
+	mulAlpha(&second)
+	if decrypt {
+		first, second = second, first
+	}
+
+	last := full * xtsBlockSize
+	var block [xtsBlockSize]byte
+	xts.cryptBlock(block[:], src[last:], &first, decrypt)
+
+	// The partial block is completed with the stolen tail of the last full
+	// block, whose head becomes the partial output block
+	var stolen [xtsBlockSize]byte
+	copy(stolen[:], src[last+xtsBlockSize:])
+	copy(stolen[tail:], block[tail:])
+	copy(dst[last+xtsBlockSize:], block[:tail])
+	xts.cryptBlock(dst[last:], stolen[:], &second, decrypt)
+}
+
+// cryptBlock processes one block as XEX: t XOR E(K1, src XOR t)
+func (xts *XTS) cryptBlock(dst, src []byte, t *[xtsBlockSize]byte, decrypt bool) {
+	var block [xtsBlockSize]byte
+	xor(block[:], src, t[:])
+	if decrypt {
+		xts.aes.Decrypt(block[:], block[:])
+	} else {
+		xts.aes.Encrypt(block[:], block[:])
+	}
+	xor(dst[:xtsBlockSize], block[:], t[:])
+}
+
+// mulAlpha multiplies the tweak by the primitive element α of GF(2^128)
+// The tweak is a little-endian polynomial reduced by x^128 + x^7 + x^2 + x + 1
+func mulAlpha(t *[xtsBlockSize]byte) {
+	carry := t[xtsBlockSize-1] >> 7
+	for i := xtsBlockSize - 1; i > 0; i-- {
+		t[i] = t[i]<<1 | t[i-1]>>7
+	}
+	t[0] = t[0]<<1 ^ 0x87&-carry
+}
@@ -0,0 +1,229 @@
+/*
+	xts_test.go
+
+	Tests for the XTS mode of operation.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	xts_test.go Daniel Havir, 2018
+*/
+
+package goaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+type xtstest struct {
+	name       string
+	key        string
+	sectorNum  uint64
+	plaintext  string
+	ciphertext string
+}
+
+const (
+	xtsKey1 = "fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0"
+	xtsKey2 = "bfbebdbcbbbab9b8b7b6b5b4b3b2b1b0"
+	// Plaintext of the vectors 15 to 18, each one byte longer than the previous
+	xtsPlaintext = "000102030405060708090a0b0c0d0e0f10111213"
+)
+
+// IEEE 1619 test vectors, 15 to 18 exercise the ciphertext stealing
+var xtstests = []xtstest{
+	{
+		"Vector 1",
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		0,
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"917cf69ebd68b2ec9b9fe9a3eadda692cd43d2f59598ed858c02c2652fbf922e",
+	},
+	{
+		"Vector 2",
+		"1111111111111111111111111111111122222222222222222222222222222222",
+		0x3333333333,
+		"4444444444444444444444444444444444444444444444444444444444444444",
+		"c454185e6a16936e39334038acef838bfb186fff7480adc4289382ecd6d394f0",
+	},
+	{
+		"Vector 3",
+		xtsKey1 + "22222222222222222222222222222222",
+		0x3333333333,
+		"4444444444444444444444444444444444444444444444444444444444444444",
+		"af85336b597afc1a900b2eb21ec949d292df4c047e0b21532186a5971a227a89",
+	},
+	{
+		"Vector 15",
+		xtsKey1 + xtsKey2,
+		0x123456789a,
+		xtsPlaintext[:34],
+		"6c1625db4671522d3d7599601de7ca09ed",
+	},
+	{
+		"Vector 16",
+		xtsKey1 + xtsKey2,
+		0x123456789a,
+		xtsPlaintext[:36],
+		"d069444b7a7e0cab09e24447d24deb1fedbf",
+	},
+	{
+		"Vector 17",
+		xtsKey1 + xtsKey2,
+		0x123456789a,
+		xtsPlaintext[:38],
+		"e5df1351c0544ba1350b3363cd8ef4beedbf9d",
+	},
+	{
+		"Vector 18",
+		xtsKey1 + xtsKey2,
+		0x123456789a,
+		xtsPlaintext,
+		"9d84c813f719aa2c7be3f66171c7c5c2edbf9dac",
+	},
+}
+
+func TestXTSVectors(t *testing.T) {
+	for _, test := range xtstests {
+		xts, err := NewXTS(katCipher, decodehex([]byte(test.key)))
+		check(err)
+		plaintext := decodehex([]byte(test.plaintext))
+		expected := decodehex([]byte(test.ciphertext))
+
+		ciphertext := make([]byte, len(plaintext))
+		xts.Encrypt(ciphertext, plaintext, test.sectorNum)
+		if !bytes.Equal(ciphertext, expected) {
+			t.Error(test.name, " expected ", test.ciphertext,
+				",got ", string(encodehex(ciphertext)))
+		}
+
+		// Decrypt in-place
+		xts.Decrypt(ciphertext, ciphertext, test.sectorNum)
+		if !bytes.Equal(ciphertext, plaintext) {
+			t.Error(test.name, " expected ", test.plaintext,
+				",got ", string(encodehex(ciphertext)))
+		}
+	}
+}
+
+func TestXTSRoundTrip(t *testing.T) {
+	for _, keySize := range []int{32, 64} {
+		xts, err := NewXTS(aes.NewCipher, randomBytes(keySize))
+		check(err)
+		for n := aes.BlockSize; n <= 4*aes.BlockSize; n++ {
+			plaintext := randomBytes(n)
+			ciphertext := make([]byte, n)
+			xts.Encrypt(ciphertext, plaintext, uint64(n))
+
+			// In-place encryption must match
+			inplace := append([]byte(nil), plaintext...)
+			xts.Encrypt(inplace, inplace, uint64(n))
+			if !bytes.Equal(inplace, ciphertext) {
+				t.Error("In-place encryption differs for length ", n)
+			}
+
+			// A different sector must give a different ciphertext
+			other := make([]byte, n)
+			xts.Encrypt(other, plaintext, uint64(n)+1)
+			if bytes.Equal(other, ciphertext) {
+				t.Error("Sector number ignored for length ", n)
+			}
+
+			xts.Decrypt(ciphertext, ciphertext, uint64(n))
+			if !bytes.Equal(ciphertext, plaintext) {
+				t.Error("Expected ", string(encodehex(plaintext)),
+					",got ", string(encodehex(ciphertext)))
+			}
+		}
+	}
+}
+
+func TestXTSParameters(t *testing.T) {
+	for keySize := 0; keySize <= 72; keySize += 8 {
+		_, err := NewXTS(aes.NewCipher, make([]byte, keySize))
+		valid := keySize == 32 || keySize == 64
+		if valid != (err == nil) {
+			t.Error("Unexpected result for key size ", keySize, ": ", err)
+		}
+	}
+
+	xts, err := NewXTS(aes.NewCipher, make([]byte, 32))
+	check(err)
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for a data unit shorter than one block")
+		}
+	}()
+	xts.Encrypt(make([]byte, 15), make([]byte, 15), 0)
+}
+
+func xtsTestrun(t *testing.T, tests []map[string]string) int {
+	numTests := 0
+	for _, test := range tests {
+		// Data units that are not whole bytes are not supported
+		bits, err := strconv.Atoi(test["DataUnitLen"])
+		check(err)
+		if bits%8 != 0 {
+			continue
+		}
+
+		xts, err := NewXTS(katCipher, decodehex([]byte(test["Key"])))
+		check(err)
+
+		// The tweak is either given as a hex value, or as the data unit
+		// sequence number
+		tweak := decodehex([]byte(test["i"]))
+		if seq, found := test["DataUnitSeqNumber"]; found {
+			n, err := strconv.ParseUint(seq, 10, 64)
+			check(err)
+			tweak = make([]byte, aes.BlockSize)
+			binary.LittleEndian.PutUint64(tweak, n)
+		}
+
+		plaintext := decodehex([]byte(test["PT"]))
+		ciphertext := decodehex([]byte(test["CT"]))
+		out := make([]byte, len(plaintext))
+		if test["phase"] == "DECRYPT" {
+			xts.DecryptTweak(out, ciphertext, tweak)
+			if !bytes.Equal(out, plaintext) {
+				t.Error("Count ", test["COUNT"], " expected ", test["PT"],
+					",got ", string(encodehex(out)))
+			}
+		} else {
+			xts.EncryptTweak(out, plaintext, tweak)
+			if !bytes.Equal(out, ciphertext) {
+				t.Error("Count ", test["COUNT"], " expected ", test["CT"],
+					",got ", string(encodehex(out)))
+			}
+		}
+		numTests++
+	}
+	return numTests
+}
+
+func TestXTSCAVP(t *testing.T) {
+	for _, name := range []string{"XTSGenAES128", "XTSGenAES256"} {
+		t.Run(name, func(t *testing.T) {
+			var file cavpfile
+			testJSON := readtestfile(t, "jsontests/"+name+".json")
+			json.Unmarshal(testJSON, &file)
+			numTests := xtsTestrun(t, file.Tests)
+			fmt.Println("Num "+name+" tests: ", numTests)
+		})
+	}
+}
@@ -0,0 +1,365 @@
+/*
+	container.go
+
+	Versioned header of the files written by the command line interfaces. It
+	describes the cipher, the mode of operation and its parameters, so that a
+	file can be decrypted with the key or passphrase alone.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	container.go Daniel Havir, 2018
+*/
+
+package gocontainer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/danielhavir/go-ciphers/gokdf"
+)
+
+// Magic starts every container
+const Magic = "GOCF"
+
+// Version is the version of the header layout written by MarshalBinary
+const Version = 1
+
+// fixedSize is the length of the header up to the optional fields
+const fixedSize = len(Magic) + 12
+
+// ErrMagic is returned when the data does not start with the magic
+var ErrMagic = errors.New("gocontainer: not an encrypted container")
+
+// ErrVersion is returned for a header version this package cannot read
+var ErrVersion = errors.New("gocontainer: unsupported header version")
+
+// ErrHeader is returned when the header is truncated or its fields are inconsistent
+var ErrHeader = errors.New("gocontainer: malformed header")
+
+// Cipher identifies the cipher in the header
+type Cipher byte
+
+// Supported ciphers
+const (
+	AES Cipher = 1
+	RC4 Cipher = 2
+)
+
+// Mode identifies the AES mode of operation in the header
+type Mode byte
+
+// Supported modes of operation, RC4 has none
+const (
+	NoMode Mode = iota
+	ECB
+	CBC
+	CBCCS1
+	CBCCS2
+	CBCCS3
+	CTR
+	CFB1
+	CFB8
+	CFB128
+	OFB
+	GCM
+	XTS
+	GCMStream
+	SIV
+	EAX
+	OCB
+)
+
+var modeNames = []string{"", "ecb", "cbc", "cbc-cs1", "cbc-cs2", "cbc-cs3",
+	"ctr", "cfb1", "cfb8", "cfb128", "ofb", "gcm", "xts", "gcm-stream", "siv", "eax", "ocb"}
+
+// MaxChunkSize bounds the chunk size of GCMStream, which decryption buffers
+const MaxChunkSize = 1 << 24
+
+// MaxRC4Offset bounds the number of discarded RC4 key stream bytes, RFC 4345
+// recommends 1536
+const MaxRC4Offset = 1 << 20
+
+// Padding identifies the padding scheme of ECB and CBC in the header
+type Padding byte
+
+// Supported padding schemes, the other modes are unpadded
+const (
+	Unpadded Padding = iota
+	PKCS7
+	ANSIX923
+	ISO7816
+	ISO10126
+	ZeroPadding
+	NoPadding
+)
+
+var paddingNames = []string{"", "pkcs7", "x923", "iso7816", "iso10126", "zero", "none"}
+
+// ParseMode returns the mode of operation for its name, as used by the
+// -mode flag of the command line interface, in any case
+func ParseMode(name string) (Mode, error) {
+	for i, modeName := range modeNames {
+		if i > 0 && modeName == strings.ToLower(name) {
+			return Mode(i), nil
+		}
+	}
+	return 0, errors.New("gocontainer: unknown mode of operation \"" + name + "\"")
+}
+
+// String returns the name of the mode of operation
+func (mode Mode) String() string {
+	if int(mode) < len(modeNames) {
+		return modeNames[mode]
+	}
+	return "unknown"
+}
+
+// ParsePadding returns the padding scheme for its name, as used by the
+// -padding flag of the command line interface, in any case
+func ParsePadding(name string) (Padding, error) {
+	for i, paddingName := range paddingNames {
+		if i > 0 && paddingName == strings.ToLower(name) {
+			return Padding(i), nil
+		}
+	}
+	return 0, errors.New("gocontainer: unknown padding \"" + name + "\"")
+}
+
+// String returns the name of the padding scheme
+func (padding Padding) String() string {
+	if int(padding) < len(paddingNames) {
+		return paddingNames[padding]
+	}
+	return "unknown"
+}
+
+// Header is the class for the container header
+//
+// Layout, multi-byte integers are big-endian:
+//
+//	magic "GOCF" (4) || version (1) || cipher (1) || mode (1) || padding (1) ||
+//	key size (2) || tag size (1) || parameter (4) || has KDF (1) ||
+//	[KDF parameters, see gokdf] || input vector length (1) || input vector
+type Header struct {
+	Cipher  Cipher
+	Mode    Mode
+	Padding Padding
+	// Key size in bytes, twice the AES key size for XTS and SIV
+	KeySize int
+	// Tag size in bytes of GCM, GCMStream, SIV, EAX and OCB, zero otherwise
+	TagSize int
+	// Counter width in bits for CTR, sector size for XTS, plaintext chunk
+	// size for GCMStream, the number of discarded key stream bytes for RC4,
+	// zero otherwise
+	Param uint32
+	// Key derivation parameters, nil if the key was given directly
+	KDF *gokdf.Params
+	// Input vector, initial counter block, 96-bit GCM or OCB nonce, 56-bit
+	// GCMStream nonce prefix or 128-bit SIV or EAX nonce, empty for ECB,
+	// XTS, RC4 and deterministic SIV
+	InputVec []byte
+}
+
+// MarshalBinary serializes the header, it fails if the fields are inconsistent
+func (header *Header) MarshalBinary() ([]byte, error) {
+	if err := header.validate(); err != nil {
+		return nil, err
+	}
+	out := make([]byte, fixedSize, fixedSize+64)
+	copy(out, Magic)
+	out[4] = Version
+	out[5] = byte(header.Cipher)
+	out[6] = byte(header.Mode)
+	out[7] = byte(header.Padding)
+	binary.BigEndian.PutUint16(out[8:], uint16(header.KeySize))
+	out[10] = byte(header.TagSize)
+	binary.BigEndian.PutUint32(out[11:], header.Param)
+	if header.KDF != nil {
+		out[15] = 1
+		params, err := header.KDF.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, params...)
+	}
+	out = append(out, byte(len(header.InputVec)))
+	return append(out, header.InputVec...), nil
+}
+
+// Parse reads the header from the beginning of data and returns it with the
+// rest of data, the header is validated as MarshalBinary would
+func Parse(data []byte) (*Header, []byte, error) {
+	if !bytes.HasPrefix(data, []byte(Magic)) {
+		return nil, nil, ErrMagic
+	}
+	if len(data) < fixedSize {
+		return nil, nil, ErrHeader
+	}
+	if data[4] != Version {
+		return nil, nil, ErrVersion
+	}
+	header := &Header{
+		Cipher:  Cipher(data[5]),
+		Mode:    Mode(data[6]),
+		Padding: Padding(data[7]),
+		KeySize: int(binary.BigEndian.Uint16(data[8:])),
+		TagSize: int(data[10]),
+		Param:   binary.BigEndian.Uint32(data[11:]),
+	}
+	rest := data[fixedSize:]
+	switch data[15] {
+	case 0:
+	case 1:
+		params, paramsRest, err := gokdf.ParseParams(rest)
+		if err != nil {
+			return nil, nil, ErrHeader
+		}
+		header.KDF, rest = params, paramsRest
+	default:
+		return nil, nil, ErrHeader
+	}
+
+	if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+		return nil, nil, ErrHeader
+	}
+	header.InputVec = append([]byte(nil), rest[1:1+int(rest[0])]...)
+	rest = rest[1+int(rest[0]):]
+
+	if err := header.validate(); err != nil {
+		return nil, nil, err
+	}
+	return header, rest, nil
+}
+
+// ReadHeader reads exactly one header from r, the ciphertext that follows
+// is left unread
+// It also returns the serialized header, which GCM authenticates
+func ReadHeader(r io.Reader) (*Header, []byte, error) {
+	raw := make([]byte, fixedSize, fixedSize+64)
+	n, err := io.ReadFull(r, raw)
+	if n < len(Magic) || string(raw[:len(Magic)]) != Magic {
+		return nil, nil, ErrMagic
+	}
+	if err != nil {
+		return nil, nil, ErrHeader
+	}
+
+	// The optional fields end with a length-prefixed salt and input vector
+	if raw[15] == 1 {
+		if raw, err = readField(r, raw, gokdf.HeaderSize-gokdf.SaltSize-1); err != nil {
+			return nil, nil, err
+		}
+	}
+	if raw, err = readField(r, raw, 0); err != nil {
+		return nil, nil, err
+	}
+
+	header, _, err := Parse(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, raw, nil
+}
+
+// readField appends the next n bytes and a length-prefixed field to raw
+func readField(r io.Reader, raw []byte, n int) ([]byte, error) {
+	start := len(raw)
+	raw = append(raw, make([]byte, n+1)...)
+	if _, err := io.ReadFull(r, raw[start:]); err != nil {
+		return nil, ErrHeader
+	}
+	start = len(raw)
+	raw = append(raw, make([]byte, raw[start-1])...)
+	if _, err := io.ReadFull(r, raw[start:]); err != nil {
+		return nil, ErrHeader
+	}
+	return raw, nil
+}
+
+// validate checks that the fields describe a setting the command line
+// interfaces can decrypt
+func (header *Header) validate() error {
+	if header.Cipher == RC4 {
+		if header.Mode != NoMode || header.Padding != Unpadded || header.TagSize != 0 ||
+			len(header.InputVec) != 0 || header.KeySize < 1 || header.KeySize > 256 ||
+			header.Param > MaxRC4Offset {
+			return ErrHeader
+		}
+		return nil
+	}
+	if header.Cipher != AES || header.Mode == NoMode || int(header.Mode) >= len(modeNames) ||
+		int(header.Padding) >= len(paddingNames) {
+		return ErrHeader
+	}
+
+	// Key size
+	switch {
+	case header.Mode == XTS:
+		if header.KeySize != 32 && header.KeySize != 64 {
+			return ErrHeader
+		}
+	case header.Mode == SIV:
+		if header.KeySize != 32 && header.KeySize != 48 && header.KeySize != 64 {
+			return ErrHeader
+		}
+	case header.KeySize != 16 && header.KeySize != 24 && header.KeySize != 32:
+		return ErrHeader
+	}
+
+	// Only ECB and CBC are padded
+	padded := header.Mode == ECB || header.Mode == CBC
+	if padded != (header.Padding != Unpadded) {
+		return ErrHeader
+	}
+
+	// Input vector length, tag size and mode parameter
+	inputVecSize, tagged, param := 16, false, false
+	switch header.Mode {
+	case ECB, XTS:
+		inputVecSize = 0
+	case GCM, OCB:
+		inputVecSize, tagged = 12, true
+	case EAX:
+		tagged = true
+	case GCMStream:
+		inputVecSize, tagged = 7, true
+	case SIV:
+		// The nonce is optional, the synthetic IV is always 16 bytes
+		if len(header.InputVec) == 0 {
+			inputVecSize = 0
+		}
+		if header.TagSize != 16 {
+			return ErrHeader
+		}
+		tagged = true
+	}
+	switch header.Mode {
+	case CTR:
+		param = header.Param >= 8 && header.Param <= 128 && header.Param%8 == 0
+	case XTS:
+		param = header.Param >= 16
+	case GCMStream:
+		param = header.Param >= 1 && header.Param <= MaxChunkSize
+	default:
+		param = header.Param == 0
+	}
+	if len(header.InputVec) != inputVecSize || !param ||
+		tagged != (header.TagSize != 0) || (tagged && (header.TagSize < 12 || header.TagSize > 16)) {
+		return ErrHeader
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+/*
+	container_test.go
+
+	Golden file and fuzz tests for the container header.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	container_test.go Daniel Havir, 2018
+*/
+
+package gocontainer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/danielhavir/go-ciphers/gokdf"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files")
+
+var goldenSalt = []byte("0123456789abcdef")
+var goldenInputVec = []byte("fedcba9876543210")
+
+// goldenHeaders are serialized into testdata/<name>.golden, any change of
+// the layout shows up as a difference and requires a new Version
+var goldenHeaders = []struct {
+	name   string
+	header Header
+}{
+	{"aes-cbc-pkcs7", Header{Cipher: AES, Mode: CBC, Padding: PKCS7, KeySize: 16, InputVec: goldenInputVec}},
+	{"aes-ecb-iso7816", Header{Cipher: AES, Mode: ECB, Padding: ISO7816, KeySize: 24}},
+	{"aes-ctr-32", Header{Cipher: AES, Mode: CTR, KeySize: 32, Param: 32, InputVec: goldenInputVec}},
+	{"aes-gcm", Header{Cipher: AES, Mode: GCM, KeySize: 32, TagSize: 16, InputVec: goldenInputVec[:12]}},
+	{"aes-gcm-stream", Header{Cipher: AES, Mode: GCMStream, KeySize: 16, TagSize: 16, Param: 65536,
+		InputVec: goldenInputVec[:7]}},
+	{"aes-siv", Header{Cipher: AES, Mode: SIV, KeySize: 64, TagSize: 16, InputVec: goldenInputVec}},
+	{"aes-siv-deterministic", Header{Cipher: AES, Mode: SIV, KeySize: 32, TagSize: 16}},
+	{"aes-eax", Header{Cipher: AES, Mode: EAX, KeySize: 16, TagSize: 16, InputVec: goldenInputVec}},
+	{"aes-ocb", Header{Cipher: AES, Mode: OCB, KeySize: 32, TagSize: 16, InputVec: goldenInputVec[:12]}},
+	{"aes-xts-pbkdf2", Header{Cipher: AES, Mode: XTS, KeySize: 64, Param: 4096,
+		KDF: &gokdf.Params{KDF: gokdf.PBKDF2, Cost: 600000, Salt: goldenSalt}}},
+	{"aes-cfb8-argon2id", Header{Cipher: AES, Mode: CFB8, KeySize: 32, InputVec: goldenInputVec,
+		KDF: &gokdf.Params{KDF: gokdf.Argon2id, Cost: 3, Memory: 64 * 1024, Parallelism: 4, Salt: goldenSalt}}},
+	{"rc4-scrypt", Header{Cipher: RC4, KeySize: 16, Param: 1536,
+		KDF: &gokdf.Params{KDF: gokdf.Scrypt, Cost: 15, Memory: 8, Parallelism: 1, Salt: goldenSalt}}},
+}
+
+func TestGolden(t *testing.T) {
+	for _, test := range goldenHeaders {
+		path := filepath.Join("testdata", test.name+".golden")
+		out, err := test.header.MarshalBinary()
+		if err != nil {
+			t.Fatal(test.name, ": ", err)
+		}
+		if *update {
+			if err := os.WriteFile(path, out, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		golden, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out, golden) {
+			t.Errorf("%s: expected %x,got %x", test.name, golden, out)
+		}
+
+		parsed, rest, err := Parse(append(golden, "ciphertext"...))
+		if err != nil || string(rest) != "ciphertext" {
+			t.Fatal(test.name, ": expected the ciphertext after the header,got ", string(rest), " error ", err)
+		}
+		if !reflect.DeepEqual(*parsed, test.header) {
+			t.Errorf("%s: expected %+v,got %+v", test.name, test.header, *parsed)
+		}
+
+		// Reading from a stream stops right after the header
+		r := bytes.NewReader(append(golden, "ciphertext"...))
+		read, raw, err := ReadHeader(r)
+		if err != nil || !bytes.Equal(raw, golden) || r.Len() != len("ciphertext") {
+			t.Fatal(test.name, ": expected the header to be read,got ", err)
+		}
+		if !reflect.DeepEqual(read, parsed) {
+			t.Errorf("%s: expected %+v,got %+v", test.name, *parsed, *read)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	golden, err := os.ReadFile(filepath.Join("testdata", "aes-cbc-pkcs7.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Parse([]byte("not a container")); err != ErrMagic {
+		t.Error("Expected ", ErrMagic, ",got ", err)
+	}
+
+	version := append([]byte(nil), golden...)
+	version[4] = Version + 1
+	if _, _, err := Parse(version); err != ErrVersion {
+		t.Error("Expected ", ErrVersion, ",got ", err)
+	}
+
+	// An RC4 offset that would discard gigabytes of key stream
+	rc4, err := os.ReadFile(filepath.Join("testdata", "rc4-scrypt.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.BigEndian.PutUint32(rc4[11:], 1<<32-1)
+	if _, _, err := Parse(rc4); err != ErrHeader {
+		t.Error("Expected ", ErrHeader, " for the RC4 offset,got ", err)
+	}
+
+	for n := len(Magic); n < len(golden); n++ {
+		if _, _, err := Parse(golden[:n]); err != ErrHeader {
+			t.Error("Expected ", ErrHeader, " for ", n, " bytes,got ", err)
+		}
+		if _, _, err := ReadHeader(bytes.NewReader(golden[:n])); err != ErrHeader {
+			t.Error("Expected ", ErrHeader, " reading ", n, " bytes,got ", err)
+		}
+	}
+	if _, _, err := ReadHeader(bytes.NewReader(nil)); err != ErrMagic {
+		t.Error("Expected ", ErrMagic, ",got ", err)
+	}
+}
+
+func TestInconsistentHeaders(t *testing.T) {
+	invalid := []Header{
+		{Cipher: 3, KeySize: 16},
+		{Cipher: AES, Mode: NoMode, KeySize: 16},
+		{Cipher: AES, Mode: CBC, KeySize: 16, InputVec: goldenInputVec},
+		{Cipher: AES, Mode: CTR, Padding: PKCS7, KeySize: 16, Param: 128, InputVec: goldenInputVec},
+		{Cipher: AES, Mode: CBC, Padding: PKCS7, KeySize: 20, InputVec: goldenInputVec},
+		{Cipher: AES, Mode: CBC, Padding: PKCS7, KeySize: 16, InputVec: goldenInputVec[:8]},
+		{Cipher: AES, Mode: CTR, KeySize: 16, Param: 12, InputVec: goldenInputVec},
+		{Cipher: AES, Mode: GCM, KeySize: 16, TagSize: 8, InputVec: goldenInputVec[:12]},
+		{Cipher: AES, Mode: GCM, KeySize: 16, TagSize: 16},
+		{Cipher: AES, Mode: XTS, KeySize: 32, Param: 8},
+		{Cipher: AES, Mode: GCMStream, KeySize: 16, TagSize: 16, InputVec: goldenInputVec[:7]},
+		{Cipher: AES, Mode: GCMStream, KeySize: 16, TagSize: 16, Param: MaxChunkSize + 1, InputVec: goldenInputVec[:7]},
+		{Cipher: AES, Mode: GCMStream, KeySize: 16, TagSize: 16, Param: 1024, InputVec: goldenInputVec[:12]},
+		{Cipher: AES, Mode: XTS, KeySize: 16, Param: 512},
+		{Cipher: AES, Mode: SIV, KeySize: 16, TagSize: 16},
+		{Cipher: AES, Mode: SIV, KeySize: 32, TagSize: 12},
+		{Cipher: AES, Mode: SIV, KeySize: 32, TagSize: 16, InputVec: goldenInputVec[:12]},
+		{Cipher: AES, Mode: EAX, KeySize: 16, InputVec: goldenInputVec},
+		{Cipher: AES, Mode: EAX, KeySize: 16, TagSize: 16, InputVec: goldenInputVec[:12]},
+		{Cipher: AES, Mode: OCB, KeySize: 16, TagSize: 16, InputVec: goldenInputVec},
+		{Cipher: AES, Mode: OCB, KeySize: 64, TagSize: 16, InputVec: goldenInputVec[:12]},
+		{Cipher: RC4, Mode: CBC, KeySize: 16},
+		{Cipher: RC4, KeySize: 0},
+		{Cipher: RC4, KeySize: 16, Param: MaxRC4Offset + 1},
+	}
+	for _, header := range invalid {
+		if _, err := header.MarshalBinary(); err != ErrHeader {
+			t.Errorf("Expected %v for %+v,got %v", ErrHeader, header, err)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	for mode := ECB; mode <= OCB; mode++ {
+		if parsed, err := ParseMode(mode.String()); err != nil || parsed != mode {
+			t.Error("Mode ", mode, " does not round trip its name")
+		}
+		if parsed, err := ParseMode(strings.ToUpper(mode.String())); err != nil || parsed != mode {
+			t.Error("Mode ", mode, " is not parsed from its upper-case name")
+		}
+	}
+	for padding := PKCS7; padding <= NoPadding; padding++ {
+		if parsed, err := ParsePadding(padding.String()); err != nil || parsed != padding {
+			t.Error("Padding ", padding, " does not round trip its name")
+		}
+		if parsed, err := ParsePadding(strings.ToUpper(padding.String())); err != nil || parsed != padding {
+			t.Error("Padding ", padding, " is not parsed from its upper-case name")
+		}
+	}
+	if parsed, err := ParseMode("Gcm-Stream"); err != nil || parsed != GCMStream {
+		t.Error("Expected ", GCMStream, " for Gcm-Stream,got ", parsed, " error ", err)
+	}
+	if _, err := ParsePadding("pkcs5"); err == nil {
+		t.Error("Expected an error for an unknown padding")
+	}
+	if _, err := ParseMode(""); err == nil {
+		t.Error("Expected an error for an empty mode")
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, test := range goldenHeaders {
+		golden, err := os.ReadFile(filepath.Join("testdata", test.name+".golden"))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(golden)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		header, rest, err := Parse(data)
+		read, raw, readErr := ReadHeader(bytes.NewReader(data))
+		if err != nil {
+			if readErr == nil {
+				t.Fatal("ReadHeader accepted what Parse rejects: ", err)
+			}
+			return
+		}
+		if readErr != nil || !reflect.DeepEqual(read, header) || len(raw) != len(data)-len(rest) {
+			t.Fatal("ReadHeader does not match Parse: ", readErr)
+		}
+		// A parsed header serializes back to the bytes it was read from
+		out, err := header.MarshalBinary()
+		if err != nil {
+			t.Fatal("Parsed header does not serialize: ", err)
+		}
+		if !bytes.Equal(out, data[:len(data)-len(rest)]) {
+			t.Fatalf("Expected %x,got %x", data[:len(data)-len(rest)], out)
+		}
+	})
+}
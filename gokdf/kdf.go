@@ -0,0 +1,221 @@
+/*
+	kdf.go
+
+	Password-based key derivation for the command line interfaces: PBKDF2 with
+	HMAC-SHA256, scrypt and Argon2id. The parameters and the random salt are
+	serialized into a header that precedes the ciphertext.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	kdf.go Daniel Havir, 2018
+*/
+
+package gokdf
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KDF identifies a key derivation function in the header
+type KDF byte
+
+// Supported key derivation functions
+const (
+	PBKDF2   KDF = 1
+	Scrypt   KDF = 2
+	Argon2id KDF = 3
+)
+
+// Default parameters, used when the cost is not given
+const (
+	DefaultPBKDF2Iterations = 600000
+	DefaultScryptLogN       = 15
+	DefaultScryptR          = 8
+	DefaultScryptP          = 1
+	DefaultArgon2Time       = 3
+	DefaultArgon2Memory     = 64 * 1024
+	DefaultArgon2Threads    = 4
+)
+
+// Maximum parameters, which bound the time and memory that decrypting a
+// file with an untrusted header can take
+const (
+	MaxPBKDF2Iterations = 10000000
+	// Bytes of the scrypt working memory 128*N*r
+	MaxScryptMemory      = 1 << 30
+	MaxScryptParallelism = 16
+	MaxArgon2Time        = 32
+	// KiB, as the Argon2id memory parameter
+	MaxArgon2Memory = 1 << 20
+)
+
+// SaltSize is the length of the random salt
+const SaltSize = 16
+
+// HeaderSize is the length of the serialized parameters with a SaltSize salt
+const HeaderSize = 1 + 3*4 + 1 + SaltSize
+
+// ErrUnknownKDF is returned for an unknown key derivation function
+var ErrUnknownKDF = errors.New("gokdf: unknown key derivation function")
+
+// ErrParams is returned when the parameters are out of the supported range
+var ErrParams = errors.New("gokdf: invalid key derivation parameters")
+
+// ErrHeader is returned when the header is truncated or malformed
+var ErrHeader = errors.New("gokdf: malformed key derivation header")
+
+// Params is the class for the parameters of a key derivation
+type Params struct {
+	KDF KDF
+	// Iterations for PBKDF2, log2(N) for scrypt, passes for Argon2id
+	Cost uint32
+	// Block size r for scrypt, memory in KiB for Argon2id, unused by PBKDF2
+	Memory uint32
+	// Parallelization p for scrypt, threads for Argon2id, unused by PBKDF2
+	Parallelism uint32
+	Salt        []byte
+}
+
+// ParseKDF returns the key derivation function for its name, as used by the
+// -kdf flag of the command line interfaces, in any case
+func ParseKDF(name string) (KDF, error) {
+	switch strings.ToLower(name) {
+	case "pbkdf2":
+		return PBKDF2, nil
+	case "scrypt":
+		return Scrypt, nil
+	case "argon2id":
+		return Argon2id, nil
+	}
+	return 0, ErrUnknownKDF
+}
+
+// NewParams is a constructor for the Params class with a random salt
+// A zero cost selects the default, the other parameters are the defaults
+func NewParams(kdf KDF, cost uint32) (*Params, error) {
+	params := &Params{KDF: kdf, Cost: cost, Salt: make([]byte, SaltSize)}
+	switch kdf {
+	case PBKDF2:
+		if params.Cost == 0 {
+			params.Cost = DefaultPBKDF2Iterations
+		}
+	case Scrypt:
+		if params.Cost == 0 {
+			params.Cost = DefaultScryptLogN
+		}
+		params.Memory, params.Parallelism = DefaultScryptR, DefaultScryptP
+	case Argon2id:
+		if params.Cost == 0 {
+			params.Cost = DefaultArgon2Time
+		}
+		params.Memory, params.Parallelism = DefaultArgon2Memory, DefaultArgon2Threads
+	default:
+		return nil, ErrUnknownKDF
+	}
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(params.Salt); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// validate bounds the parameters, which may come from an untrusted header,
+// to what the key derivation functions accept and to the maximum work
+func (params *Params) validate() error {
+	switch params.KDF {
+	case PBKDF2:
+		if params.Cost == 0 || params.Cost > MaxPBKDF2Iterations {
+			return ErrParams
+		}
+	case Scrypt:
+		// r*p < 2^30 as RFC 7914 requires, 128*N*r within the memory limit
+		r, p := uint64(params.Memory), uint64(params.Parallelism)
+		if params.Cost == 0 || params.Cost > 30 || r == 0 || p == 0 ||
+			p > MaxScryptParallelism || r*p >= 1<<30 || 128*r > MaxScryptMemory>>params.Cost {
+			return ErrParams
+		}
+	case Argon2id:
+		// At least 8 KiB of memory per thread, at most 255 threads
+		if params.Cost == 0 || params.Cost > MaxArgon2Time ||
+			params.Parallelism == 0 || params.Parallelism > 255 ||
+			params.Memory < 8*params.Parallelism || params.Memory > MaxArgon2Memory {
+			return ErrParams
+		}
+	default:
+		return ErrUnknownKDF
+	}
+	return nil
+}
+
+// DeriveKey derives a key of keyLen bytes from the password
+func (params *Params) DeriveKey(password []byte, keyLen int) ([]byte, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	switch params.KDF {
+	case PBKDF2:
+		return pbkdf2.Key(sha256.New, string(password), params.Salt, int(params.Cost), keyLen)
+	case Scrypt:
+		return scrypt.Key(password, params.Salt, 1<<params.Cost,
+			int(params.Memory), int(params.Parallelism), keyLen)
+	default:
+		return argon2.IDKey(password, params.Salt, params.Cost, params.Memory,
+			uint8(params.Parallelism), uint32(keyLen)), nil
+	}
+}
+
+// MarshalBinary serializes the parameters into the header
+// kdf (1 byte) || cost || memory || parallelism (4 bytes each, big-endian) ||
+// salt length (1 byte) || salt
+func (params *Params) MarshalBinary() ([]byte, error) {
+	if len(params.Salt) > 255 {
+		return nil, ErrParams
+	}
+	header := make([]byte, 13, 14+len(params.Salt))
+	header[0] = byte(params.KDF)
+	binary.BigEndian.PutUint32(header[1:], params.Cost)
+	binary.BigEndian.PutUint32(header[5:], params.Memory)
+	binary.BigEndian.PutUint32(header[9:], params.Parallelism)
+	header = append(header, byte(len(params.Salt)))
+	return append(header, params.Salt...), nil
+}
+
+// ParseParams reads the header from the beginning of data and returns the
+// parameters and the rest of data
+func ParseParams(data []byte) (*Params, []byte, error) {
+	if len(data) < 14 || len(data) < 14+int(data[13]) {
+		return nil, nil, ErrHeader
+	}
+	params := &Params{
+		KDF:         KDF(data[0]),
+		Cost:        binary.BigEndian.Uint32(data[1:]),
+		Memory:      binary.BigEndian.Uint32(data[5:]),
+		Parallelism: binary.BigEndian.Uint32(data[9:]),
+	}
+	end := 14 + int(data[13])
+	params.Salt = append([]byte(nil), data[14:end]...)
+	if err := params.validate(); err != nil {
+		return nil, nil, err
+	}
+	return params, data[end:], nil
+}
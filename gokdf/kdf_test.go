@@ -0,0 +1,187 @@
+/*
+	kdf_test.go
+
+	Tests for the password-based key derivation.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	kdf_test.go Daniel Havir, 2018
+*/
+
+package gokdf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+type kdftest struct {
+	name     string
+	params   Params
+	password string
+	key      string
+}
+
+// PBKDF2-HMAC-SHA256 and scrypt vectors of RFC 7914, the Argon2id vector of
+// the reference implementation (password "password", salt "somesalt")
+var kdftests = []kdftest{
+	{
+		"PBKDF2 c=1",
+		Params{KDF: PBKDF2, Cost: 1, Salt: []byte("salt")},
+		"passwd",
+		"55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+			"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783",
+	},
+	{
+		"PBKDF2 c=80000",
+		Params{KDF: PBKDF2, Cost: 80000, Salt: []byte("NaCl")},
+		"Password",
+		"4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56" +
+			"a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d",
+	},
+	{
+		"scrypt N=16",
+		Params{KDF: Scrypt, Cost: 4, Memory: 1, Parallelism: 1, Salt: []byte{}},
+		"",
+		"77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442" +
+			"fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906",
+	},
+	{
+		"scrypt N=1024",
+		Params{KDF: Scrypt, Cost: 10, Memory: 8, Parallelism: 16, Salt: []byte("NaCl")},
+		"password",
+		"fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b373162" +
+			"2eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640",
+	},
+	{
+		"Argon2id t=2 m=64 p=2",
+		Params{KDF: Argon2id, Cost: 2, Memory: 64, Parallelism: 2, Salt: []byte("somesalt")},
+		"password",
+		"350ac37222f436ccb5c0972f1ebd3bf6b958bf2071841362",
+	},
+}
+
+func TestDeriveKeyVectors(t *testing.T) {
+	for _, test := range kdftests {
+		expected, _ := hex.DecodeString(test.key)
+		key, err := test.params.DeriveKey([]byte(test.password), len(expected))
+		if err != nil || !bytes.Equal(key, expected) {
+			t.Error(test.name, " expected ", test.key,
+				",got ", hex.EncodeToString(key), " error ", err)
+		}
+	}
+}
+
+func TestHeaderRoundTrip(t *testing.T) {
+	for _, name := range []string{"pbkdf2", "scrypt", "argon2id"} {
+		kdf, err := ParseKDF(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		params, err := NewParams(kdf, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, err := params.MarshalBinary()
+		if err != nil || len(header) != HeaderSize {
+			t.Fatal("Expected a ", HeaderSize, "-byte header,got ", len(header), " error ", err)
+		}
+
+		parsed, rest, err := ParseParams(append(header, "ciphertext"...))
+		if err != nil || string(rest) != "ciphertext" {
+			t.Fatal("Expected the ciphertext after the header,got ", string(rest), " error ", err)
+		}
+		key, err := params.DeriveKey([]byte("password"), 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsedKey, err := parsed.DeriveKey([]byte("password"), 32)
+		if err != nil || !bytes.Equal(parsedKey, key) {
+			t.Error(name, " derived a different key from the parsed header")
+		}
+
+		// Each header has its own salt
+		other, _ := NewParams(kdf, 1)
+		if bytes.Equal(other.Salt, params.Salt) {
+			t.Error(name, " reused the salt")
+		}
+	}
+}
+
+func TestParseKDF(t *testing.T) {
+	names := map[string]KDF{
+		"pbkdf2": PBKDF2, "PBKDF2": PBKDF2,
+		"scrypt": Scrypt, "Scrypt": Scrypt,
+		"argon2id": Argon2id, "Argon2id": Argon2id, "ARGON2ID": Argon2id,
+	}
+	for name, expected := range names {
+		if kdf, err := ParseKDF(name); err != nil || kdf != expected {
+			t.Error("Expected ", expected, " for ", name, ",got ", kdf, " error ", err)
+		}
+	}
+	if _, err := ParseKDF("bcrypt"); err != ErrUnknownKDF {
+		t.Error("Expected ", ErrUnknownKDF, ",got ", err)
+	}
+}
+
+func TestInvalidParams(t *testing.T) {
+	invalid := []Params{
+		{KDF: 0, Cost: 1},
+		{KDF: PBKDF2, Cost: 0},
+		{KDF: Scrypt, Cost: 31, Memory: 8, Parallelism: 1},
+		{KDF: Scrypt, Cost: 10, Memory: 1 << 15, Parallelism: 1 << 15},
+		{KDF: Argon2id, Cost: 1, Memory: 8, Parallelism: 2},
+		{KDF: Argon2id, Cost: 1, Memory: 1 << 16, Parallelism: 256},
+		// Over the maximum work
+		{KDF: PBKDF2, Cost: MaxPBKDF2Iterations + 1},
+		{KDF: PBKDF2, Cost: 1<<32 - 1},
+		{KDF: Scrypt, Cost: 20, Memory: 16, Parallelism: 1},
+		{KDF: Scrypt, Cost: 30, Memory: 1, Parallelism: 1},
+		{KDF: Scrypt, Cost: 10, Memory: 8, Parallelism: MaxScryptParallelism + 1},
+		{KDF: Argon2id, Cost: MaxArgon2Time + 1, Memory: 64, Parallelism: 1},
+		{KDF: Argon2id, Cost: 1, Memory: MaxArgon2Memory + 1, Parallelism: 1},
+		{KDF: Argon2id, Cost: 1, Memory: 1<<32 - 1, Parallelism: 4},
+	}
+	for _, params := range invalid {
+		if _, err := params.DeriveKey([]byte("password"), 32); err == nil {
+			t.Error("Expected an error for ", params)
+		}
+		header, _ := params.MarshalBinary()
+		if _, _, err := ParseParams(header); err == nil {
+			t.Error("Expected an error parsing ", params)
+		}
+	}
+
+	// The maximums themselves are accepted
+	maximal := []Params{
+		{KDF: PBKDF2, Cost: MaxPBKDF2Iterations},
+		{KDF: Scrypt, Cost: 20, Memory: 8, Parallelism: MaxScryptParallelism},
+		{KDF: Argon2id, Cost: MaxArgon2Time, Memory: MaxArgon2Memory, Parallelism: 4},
+	}
+	for _, params := range maximal {
+		header, _ := params.MarshalBinary()
+		if _, _, err := ParseParams(header); err != nil {
+			t.Error("Unexpected error parsing ", params, ": ", err)
+		}
+	}
+
+	// Truncated headers
+	params, _ := NewParams(PBKDF2, 1)
+	header, _ := params.MarshalBinary()
+	for n := 0; n < len(header); n++ {
+		if _, _, err := ParseParams(header[:n]); err != ErrHeader {
+			t.Error("Expected ", ErrHeader, " for ", n, " bytes,got ", err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+/*
+	bench_test.go
+
+	Benchmarks of the key schedule at every key size and of the key stream
+	at input sizes from 16B to the 100MB of bigfile.txt, next to crypto/rc4.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	bench_test.go Daniel Havir, 2018
+*/
+
+package gorc4
+
+import (
+	"crypto/rc4"
+	"fmt"
+	"testing"
+)
+
+var benchKeySizes = []int{5, 16, 32}
+
+// benchSizes run from 16B to the size of bigfile.txt
+var benchSizes = []struct {
+	name string
+	size int
+}{
+	{"16B", 16},
+	{"1KB", 1 << 10},
+	{"64KB", 64 << 10},
+	{"1MB", 1 << 20},
+	{"100MB", 104857600},
+}
+
+func benchmarkKeySizes(b *testing.B, schedule func(key []byte)) {
+	for _, keySize := range benchKeySizes {
+		b.Run(fmt.Sprintf("key=%dB", keySize), func(b *testing.B) {
+			key := make([]byte, keySize)
+			for i := 0; i < b.N; i++ {
+				schedule(key)
+			}
+		})
+	}
+}
+
+func benchmarkSizes(b *testing.B, generate func(in []byte)) {
+	for _, size := range benchSizes {
+		b.Run(size.name, func(b *testing.B) {
+			in := make([]byte, size.size)
+			b.SetBytes(int64(size.size))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				generate(in)
+			}
+		})
+	}
+}
+
+func BenchmarkKSA(b *testing.B) {
+	benchmarkKeySizes(b, func(key []byte) {
+		_, err := KSA(key)
+		if err != nil {
+			b.Fatal(err)
+		}
+	})
+}
+
+func BenchmarkStdlibKSA(b *testing.B) {
+	benchmarkKeySizes(b, func(key []byte) {
+		_, err := rc4.NewCipher(key)
+		if err != nil {
+			b.Fatal(err)
+		}
+	})
+}
+
+func BenchmarkPRGA(b *testing.B) {
+	rc4, err := KSA([]byte("0123456789abcdef"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkSizes(b, func(in []byte) {
+		rc4.PRGA(in)
+	})
+}
+
+// The stdlib benchmark allocates its output like PRGA
+func BenchmarkStdlibPRGA(b *testing.B) {
+	cipher, err := rc4.NewCipher([]byte("0123456789abcdef"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkSizes(b, func(in []byte) {
+		out := make([]byte, len(in))
+		cipher.XORKeyStream(out, in)
+	})
+}
+
+func BenchmarkXORKeyStream(b *testing.B) {
+	rc4, err := KSA([]byte("0123456789abcdef"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkSizes(b, func(in []byte) {
+		rc4.XORKeyStream(in, in)
+	})
+}
+
+func BenchmarkStdlibXORKeyStream(b *testing.B) {
+	cipher, err := rc4.NewCipher([]byte("0123456789abcdef"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkSizes(b, func(in []byte) {
+		cipher.XORKeyStream(in, in)
+	})
+}
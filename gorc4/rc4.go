@@ -19,12 +19,19 @@
 	rc4.go Daniel Havir, 2018
 */
 
-package main
+package gorc4
 
 import (
+	"crypto/cipher"
 	"errors"
+	"unsafe"
 )
 
+var _ cipher.Stream = (*RC4)(nil)
+
+// ErrKeySize is returned by KSA when the key length is out of range
+var ErrKeySize = errors.New("gorc4: key should be between 5 and 32 bytes, i.e. between 40-bits and 256-bits")
+
 // RC4 is a class
 type RC4 struct {
 	s    [256]uint8
@@ -33,11 +40,11 @@ type RC4 struct {
 
 // KSA is the Key-scheduling algorithm
 // KSA serves as an RC4 constructor
-func KSA(key []byte) *RC4 {
+func KSA(key []byte) (*RC4, error) {
 	keyLength := len(key)
 
 	if keyLength < 5 || keyLength > 32 {
-		panic(errors.New("Key should be between 5 and 32 characters, i.e. between 40-bits and 256-bits"))
+		return nil, ErrKeySize
 	}
 	var rc4 RC4
 
@@ -54,26 +61,62 @@ func KSA(key []byte) *RC4 {
 	}
 
 	// Return pointer to the object
-	return &rc4
+	return &rc4, nil
 }
 
-// PRGA is the pseudo-random generation algorithm
-// PRGA is an RC4 method
-func (rc4 *RC4) PRGA(in []byte) []byte {
-	out := make([]byte, len(in))
+// XORKeyStream is the pseudo-random generation algorithm
+// XORKeyStream implements cipher.Stream, dst and src may be the same slice
+func (rc4 *RC4) XORKeyStream(dst, src []byte) {
+	if len(src) == 0 {
+		return
+	}
+	if len(dst) < len(src) {
+		panic("gorc4: output smaller than input")
+	}
+	if inexactOverlap(dst[:len(src)], src) {
+		panic("gorc4: invalid buffer overlap")
+	}
 
 	i := rc4.x
 	j := rc4.y
 
-	for idx := 0; idx < len(in); idx++ {
+	// Bounds check hint to the compiler
+	_ = dst[len(src)-1]
+
+	for idx, b := range src {
 		i++
 		j += rc4.s[i]
 		rc4.s[i], rc4.s[j] = rc4.s[j], rc4.s[i]
 
-		out[idx] = in[idx] ^ rc4.s[rc4.s[i]+rc4.s[j]]
+		dst[idx] = b ^ rc4.s[rc4.s[i]+rc4.s[j]]
 	}
 
 	rc4.x, rc4.y = i, j
+}
 
+// PRGA is the pseudo-random generation algorithm
+// PRGA is an RC4 method that allocates its output, see XORKeyStream
+func (rc4 *RC4) PRGA(in []byte) []byte {
+	out := make([]byte, len(in))
+	rc4.XORKeyStream(out, in)
 	return out
 }
+
+// Reset zeros the key data and counters so that the key no longer
+// remains in memory, the RC4 object must not be used afterwards
+func (rc4 *RC4) Reset() {
+	for i := range rc4.s {
+		rc4.s[i] = 0
+	}
+	rc4.x, rc4.y = 0, 0
+}
+
+// inexactOverlap reports whether x and y share memory at any non-corresponding
+// index, in which case in-place processing would corrupt the input
+func inexactOverlap(x, y []byte) bool {
+	if len(x) == 0 || len(y) == 0 || &x[0] == &y[0] {
+		return false
+	}
+	return uintptr(unsafe.Pointer(&x[0])) <= uintptr(unsafe.Pointer(&y[len(y)-1])) &&
+		uintptr(unsafe.Pointer(&y[0])) <= uintptr(unsafe.Pointer(&x[len(x)-1]))
+}
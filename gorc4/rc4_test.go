@@ -19,10 +19,14 @@
 	rc4_test.go Daniel Havir, 2018
 */
 
-package main
+package gorc4
 
 import (
 	"bytes"
+	"crypto/cipher"
+	"crypto/rc4"
+	hex "encoding/hex"
+	"io"
 	"testing"
 )
 
@@ -33,9 +37,24 @@ type testpair struct {
 
 var plain = make([]byte, 16)
 
+func decodehex(src []byte) []byte {
+	dst := make([]byte, hex.DecodedLen(len(src)))
+	hex.Decode(dst, src)
+	return dst
+}
+
+func encodehex(src []byte) []byte {
+	dst := make([]byte, hex.EncodedLen(len(src)))
+	hex.Encode(dst, src)
+	return dst
+}
+
 func testrun(t *testing.T, key []byte, testpairs []testpair) {
 	for _, pair := range testpairs {
-		rc4 := KSA(key)
+		rc4, err := KSA(key)
+		if err != nil {
+			t.Fatal(err)
+		}
 		if pair.offset > 0 {
 			rc4.PRGA(make([]byte, pair.offset))
 		}
@@ -45,6 +64,17 @@ func testrun(t *testing.T, key []byte, testpairs []testpair) {
 			t.Error("Expected ", string(encodehex(expected)),
 				",got ", string(encodehex(encrypted)))
 		}
+
+		stream, err := KSA(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inplace := make([]byte, int(pair.offset)+len(plain))
+		stream.XORKeyStream(inplace, inplace)
+		if !(bytes.Equal(inplace[pair.offset:], expected)) {
+			t.Error("XORKeyStream expected ", string(encodehex(expected)),
+				",got ", string(encodehex(inplace[pair.offset:])))
+		}
 	}
 }
 
@@ -439,3 +469,117 @@ func Test256BitsKey2(t *testing.T) {
 
 	testrun(t, key, testpairs)
 }
+
+func TestKeySize(t *testing.T) {
+	for _, size := range []int{0, 4, 33} {
+		if _, err := KSA(make([]byte, size)); err != ErrKeySize {
+			t.Error("Expected ErrKeySize for key of ", size, " bytes, got ", err)
+		}
+	}
+}
+
+func TestXORKeyStreamAllocs(t *testing.T) {
+	rc4, err := KSA([]byte("\x01\x02\x03\x04\x05"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4096)
+	allocs := testing.AllocsPerRun(100, func() {
+		rc4.XORKeyStream(buf, buf)
+	})
+	if allocs != 0 {
+		t.Error("Expected 0 allocations, got ", allocs)
+	}
+}
+
+func TestMatchesStdlib(t *testing.T) {
+	key := []byte("0102030405060708090a0b0c0d0e0f10")
+	ours, err := KSA(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	theirs, err := rc4.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := make([]byte, 1000)
+	for i := range src {
+		src[i] = byte(i)
+	}
+	// Uneven chunk sizes check that the state carries over between calls
+	for _, n := range []int{1, 7, 16, 255, 721} {
+		got := make([]byte, n)
+		expected := make([]byte, n)
+		ours.XORKeyStream(got, src[:n])
+		theirs.XORKeyStream(expected, src[:n])
+		if !bytes.Equal(got, expected) {
+			t.Fatal("Expected ", string(encodehex(expected)),
+				",got ", string(encodehex(got)))
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	rc4, err := KSA([]byte("\x01\x02\x03\x04\x05"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc4.XORKeyStream(make([]byte, 10), make([]byte, 10))
+	rc4.Reset()
+
+	var zero RC4
+	if *rc4 != zero {
+		t.Error("Expected Reset to wipe the state and counters")
+	}
+}
+
+func TestStreamReaderWriter(t *testing.T) {
+	key := []byte("secret key")
+	plaintext := bytes.Repeat([]byte("RC4 stream "), 1000)
+
+	enc, err := KSA(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ciphertext bytes.Buffer
+	w := cipher.StreamWriter{S: enc, W: &ciphertext}
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatal(err)
+	}
+
+	dec, err := KSA(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := cipher.StreamReader{S: dec, R: &ciphertext}
+	decrypted, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Error("Round trip through StreamWriter and StreamReader failed")
+	}
+}
+
+func TestXORKeyStreamPanics(t *testing.T) {
+	rc4, err := KSA([]byte("\x01\x02\x03\x04\x05"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 32)
+	cases := map[string]func(){
+		"short output":    func() { rc4.XORKeyStream(buf[:8], buf) },
+		"inexact overlap": func() { rc4.XORKeyStream(buf[1:], buf[:16]) },
+	}
+	for name, run := range cases {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected panic for ", name)
+				}
+			}()
+			run()
+		}()
+	}
+}
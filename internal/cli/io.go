@@ -0,0 +1,119 @@
+/*
+	io.go
+
+	Reading and writing files and hex encoding/decoding for the command line
+	interfaces
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	io.go Daniel Havir, 2018
+*/
+
+// Package cli holds the helpers shared by the AES and RC4 command line
+// interfaces
+package cli
+
+import (
+	"bufio"
+	hex "encoding/hex"
+	"io"
+	"os"
+)
+
+// BufferSize is the size of the input and output buffers
+const BufferSize = 64 * 1024
+
+// Check panics on an error, the command line interfaces report failures
+// this way
+func Check(e error) {
+	if e != nil {
+		panic(e)
+	}
+}
+
+// OpenInput returns a reader of the file with a buffer of size bytes, but
+// no less than BufferSize, "-" reads the standard input, hex input is
+// decoded while reading
+func OpenInput(path string, useHex bool, size int) io.Reader {
+	file := os.Stdin
+	if path != "-" {
+		var err error
+		file, err = os.Open(path)
+		Check(err)
+	}
+	if size < BufferSize {
+		size = BufferSize
+	}
+	var r io.Reader = bufio.NewReaderSize(file, size)
+	if useHex {
+		r = hex.NewDecoder(r)
+	}
+	return r
+}
+
+// Output is a buffered writer of the output file, "-" writes to the
+// standard output
+// A regular file is written under a temporary name and only renamed by
+// Commit, so that a failure leaves no partial output behind
+type Output struct {
+	w    io.Writer
+	buf  *bufio.Writer
+	file *os.File
+	// Final path of the temporary file, empty if written directly
+	path string
+}
+
+// CreateOutput opens the output, hex output is encoded while writing
+func CreateOutput(path string, useHex bool) *Output {
+	out := &Output{file: os.Stdout}
+	if info, err := os.Stat(path); path != "-" && err == nil && !info.Mode().IsRegular() {
+		// Devices and pipes such as /dev/null cannot be renamed into place
+		out.file, err = os.OpenFile(path, os.O_WRONLY, 0)
+		Check(err)
+	} else if path != "-" {
+		out.path = path
+		out.file, err = os.OpenFile(path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0664)
+		Check(err)
+	}
+	out.buf = bufio.NewWriterSize(out.file, BufferSize)
+	out.w = out.buf
+	if useHex {
+		out.w = hex.NewEncoder(out.buf)
+	}
+	return out
+}
+
+func (out *Output) Write(p []byte) (int, error) {
+	return out.w.Write(p)
+}
+
+// Commit flushes the output and moves the file into place
+func (out *Output) Commit() {
+	Check(out.buf.Flush())
+	if out.file != os.Stdout {
+		Check(out.file.Close())
+	}
+	if out.path != "" {
+		Check(os.Rename(out.file.Name(), out.path))
+	}
+	out.file = nil
+}
+
+// Abort removes the partial output file, it does nothing after Commit
+func (out *Output) Abort() {
+	if out.path != "" && out.file != nil {
+		out.file.Close()
+		os.Remove(out.file.Name())
+	}
+	out.file = nil
+}
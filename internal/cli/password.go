@@ -0,0 +1,64 @@
+/*
+	password.go
+
+	Password-based key derivation for the command line interfaces.
+
+	THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+	"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+	LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+	A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+	OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+	SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+	LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+	DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+	THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+	(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+	OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+	password.go Daniel Havir, 2018
+*/
+
+package cli
+
+import (
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/danielhavir/go-ciphers/gokdf"
+)
+
+// ParseKDF returns the key derivation function named by the -kdf flag, an
+// unknown name is a usage error that lists the valid ones
+func ParseKDF(name string) gokdf.KDF {
+	kdf, err := gokdf.ParseKDF(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Unknown key derivation function \""+name+"\", use pbkdf2, scrypt or argon2id")
+		os.Exit(2)
+	}
+	return kdf
+}
+
+// NewPasswordKey derives a key of keyLen bytes from the password with new
+// parameters, which are recorded in the header
+func NewPasswordKey(password string, kdf gokdf.KDF, cost uint, keyLen int) (*gokdf.Params, []byte) {
+	if cost > math.MaxUint32 {
+		panic("Key derivation cost must fit 32 bits")
+	}
+	params, err := gokdf.NewParams(kdf, uint32(cost))
+	Check(err)
+	key, err := params.DeriveKey([]byte(password), keyLen)
+	Check(err)
+	return params, key
+}
+
+// PasswordKey derives a key of keyLen bytes from the password with the
+// parameters read from the header
+func PasswordKey(params *gokdf.Params, password string, keyLen int) []byte {
+	if password == "" {
+		panic("The input was encrypted with a passphrase, use -pass")
+	}
+	key, err := params.DeriveKey([]byte(password), keyLen)
+	Check(err)
+	return key
+}
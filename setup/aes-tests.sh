@@ -14,20 +14,28 @@ then
     cd goaes/jsontests
     echo `pwd`
 
-    # *.zip to account for both KAT_AES.zip and kat_aes.zip
-    unzip *.zip
+    # *.zip to account for both KAT_AES.zip and kat_aes.zip, as well as
+    # further CAVP archives such as ccmtestvectors.zip or kwtestvectors.zip
+    for zip_file in *.zip; do
+        unzip -o -j "$zip_file"
+    done
     echo "####"
 
     go build parse_rsp.go
 
-    echo "Parsing .rsp files into .json files:"
+    echo "Parsing .rsp and .txt files into .json files:"
     for rsp_file in *.rsp; do
         ./parse_rsp -in=$rsp_file
     done
+    # The KW and KWP tests, e.g. KW_AE_128.txt, are distributed as .txt
+    for txt_file in *.txt; do
+        [ -e "$txt_file" ] || continue
+        ./parse_rsp -in=$txt_file
+    done
 
     echo "####"
     echo "${green}Tests successfully downloaded and extracted${normal}"
-    echo "Navigate to ${bold}/goaes${normal} and Run ${yellow}${bold}go test${normal}"
+    echo "Run ${yellow}${bold}go test ./goaes${normal}"
 else
     echo "Please ${bold}download${normal} tests in a .zip file first from: ${yellow}http://csrc.nist.gov/groups/STM/cavp/documents/aes/KAT_AES.zip${normal}"
     echo "Also, make sure that the .zip file is in the right directory ${bold}/goaes/jsontests${normal}"